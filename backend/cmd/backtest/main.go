@@ -0,0 +1,114 @@
+// Command backtest runs a built-in strategy over provider candles and prints
+// the summary, or the full result as JSON.
+//
+//	go run ./cmd/backtest -symbol AAPL -strategy ma_crossover -params fast:10,slow:30
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/backtest"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+func main() {
+	_ = config.LoadEnv()
+
+	defaults := backtest.DefaultConfig()
+	var (
+		req        backtest.Request
+		params     string
+		jsonOutput bool
+	)
+	flag.StringVar(&req.Symbol, "symbol", "", "symbol to backtest (required)")
+	flag.StringVar(&req.Interval, "interval", "daily", "candle interval")
+	flag.IntVar(&req.Limit, "limit", 500, "number of candles")
+	flag.StringVar(&req.Strategy, "strategy", "ma_crossover", "one of: "+strings.Join(backtest.Strategies(), ", "))
+	flag.StringVar(&params, "params", "", "strategy params, e.g. fast:10,slow:30")
+	flag.Float64Var(&req.Config.InitialCash, "cash", defaults.InitialCash, "starting cash")
+	flag.Float64Var(&req.Config.SlippageBps, "slippage", defaults.SlippageBps, "slippage in basis points")
+	flag.Float64Var(&req.Config.Commission, "commission", defaults.Commission, "fixed commission per fill")
+	flag.Float64Var(&req.Config.CommissionPct, "commission-pct", defaults.CommissionPct, "commission as a fraction of notional")
+	flag.BoolVar(&jsonOutput, "json", false, "print the full result as JSON")
+	flag.Parse()
+
+	var err error
+	if req.Params, err = parseParams(params); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res, err := backtest.Execute(ctx, provider(), req)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	st := res.Stats
+	fmt.Printf("%s on %s (%d bars)\n", res.Strategy, strings.ToUpper(req.Symbol), len(res.Equity))
+	fmt.Printf("  final equity     %12.2f\n", st.FinalEquity)
+	fmt.Printf("  total return     %11.2f%%\n", st.TotalReturn*100)
+	fmt.Printf("  buy and hold     %11.2f%%\n", st.BuyAndHold*100)
+	fmt.Printf("  max drawdown     %11.2f%%\n", st.MaxDrawdown*100)
+	fmt.Printf("  sharpe           %12.2f\n", st.Sharpe)
+	fmt.Printf("  trades           %12d\n", st.Trades)
+	fmt.Printf("  win rate         %11.2f%%\n", st.WinRate*100)
+	fmt.Printf("  exposure         %11.2f%%\n", st.Exposure*100)
+	fmt.Printf("  commission       %12.2f\n", st.TotalCommission)
+	for _, t := range res.Trades {
+		fmt.Printf("  %s -> %s  %6.0f @ %8.2f -> %8.2f  %+9.2f (%+.2f%%)\n",
+			t.EntryTime.Format("2006-01-02"), t.ExitTime.Format("2006-01-02"),
+			t.Quantity, t.EntryPrice, t.ExitPrice, t.PnL, t.Return*100)
+	}
+}
+
+// provider picks the same upstream as the server, without the database
+// cache: candles are not cached there anyway.
+func provider() stocks.Provider {
+	alphaKey := os.Getenv("ALPHAVANTAGE_API_KEY")
+	finnhubKey := os.Getenv("FINNHUB_API_KEY")
+	switch {
+	case alphaKey != "" && finnhubKey != "":
+		return stocks.NewFallback(stocks.NewAlphaVantage(alphaKey, nil), stocks.NewFinnhub(finnhubKey, nil))
+	case alphaKey != "":
+		return stocks.NewAlphaVantage(alphaKey, nil)
+	case finnhubKey != "":
+		return stocks.NewFinnhub(finnhubKey, nil)
+	}
+	log.Println("Using Mock provider (set ALPHAVANTAGE_API_KEY and/or FINNHUB_API_KEY to use real data)")
+	return stocks.NewMock()
+}
+
+func parseParams(raw string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, ":")
+		f, err := strconv.ParseFloat(v, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid param %q, want name:value", pair)
+		}
+		params[strings.TrimSpace(k)] = f
+	}
+	return params, nil
+}
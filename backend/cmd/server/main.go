@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/jamesfulreader/gostocks/internal/auth"
 	"github.com/jamesfulreader/gostocks/internal/database"
 	"github.com/jamesfulreader/gostocks/internal/httpserver"
 	"github.com/jamesfulreader/gostocks/internal/stocks"
@@ -16,6 +18,23 @@ func main() {
 	// Load environment from .env if present
 	_ = config.LoadEnv()
 
+	// Load JWT signing keys; without them tokens die with the process
+	keys, err := auth.KeySetFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load JWT keys: %v", err)
+	}
+	if keys != nil {
+		auth.SetKeys(keys)
+		log.Printf("Signing tokens with %s key %q", keys.Active().Algorithm, keys.Active().ID)
+		if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+			go auth.WatchKeyFile(context.Background(), path, config.GetenvDuration("JWT_KEYS_RELOAD_INTERVAL", time.Minute))
+		}
+	} else {
+		log.Println("JWT_SECRET and JWT_KEYS_FILE unset: using a random signing key, tokens won't survive a restart")
+	}
+
+	auth.AccessTokenTTL = config.GetenvDuration("ACCESS_TOKEN_TTL", auth.AccessTokenTTL)
+
 	// Initialize Database
 	db := database.New()
 	defer db.Close()
@@ -31,24 +50,26 @@ func main() {
 	var provider stocks.Provider
 
 	if alphaKey != "" && finnhubKey != "" {
-		alpha := stocks.NewAlphaVantage(alphaKey, nil)
-		finnhub := stocks.NewFinnhub(finnhubKey, nil)
+		alpha := stocks.NewMonitored("alphavantage", stocks.NewAlphaVantage(alphaKey, nil))
+		finnhub := stocks.NewMonitored("finnhub", stocks.NewFinnhub(finnhubKey, nil))
 		provider = stocks.NewFallback(alpha, finnhub)
 		log.Println("Using Fallback provider (Primary: Alpha Vantage, Secondary: Finnhub)")
 	} else if alphaKey != "" {
-		provider = stocks.NewAlphaVantage(alphaKey, nil)
+		provider = stocks.NewMonitored("alphavantage", stocks.NewAlphaVantage(alphaKey, nil))
 		log.Println("Using Alpha Vantage provider")
 	} else if finnhubKey != "" {
-		provider = stocks.NewFinnhub(finnhubKey, nil)
+		provider = stocks.NewMonitored("finnhub", stocks.NewFinnhub(finnhubKey, nil))
 		log.Println("Using Finnhub provider")
 	} else {
-		provider = stocks.NewMock()
+		provider = stocks.NewMonitored("mock", stocks.NewMock())
 		log.Println("Using Mock provider (set ALPHAVANTAGE_API_KEY and/or FINNHUB_API_KEY to use real data)")
 	}
 
 	// Wrap with Caching Provider
-	// Use a 5-minute TTL
-	provider = stocks.NewCachedProvider(provider, db, 5*time.Minute)
+	// Use a 5-minute TTL while the market is open and a longer one otherwise
+	cached := stocks.NewCachedProvider(provider, db, 5*time.Minute)
+	cached.ClosedTTL = config.GetenvDuration("QUOTE_CACHE_TTL_CLOSED", time.Hour)
+	provider = cached
 	log.Println("Enabled Database Caching for Stock Provider")
 
 	addr := ":" + config.GetenvDefault("PORT", "8080")
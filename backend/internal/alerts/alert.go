@@ -0,0 +1,53 @@
+package alerts
+
+import (
+	"context"
+	"time"
+)
+
+// Rule kinds.
+const (
+	KindAbove       = "above"        // price at or above Threshold
+	KindBelow       = "below"        // price at or below Threshold
+	KindPercentMove = "percent_move" // intraday move of at least Threshold percent, either way
+	KindCrossSMA    = "cross_sma"    // price crosses its Window-day simple moving average
+)
+
+type Rule struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Symbol    string    `json:"symbol"`
+	Kind      string    `json:"kind"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Window    int       `json:"window,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Evaluation state, persisted so restarts do not re-fire rules.
+	Armed       bool       `json:"armed"`
+	LastSide    int        `json:"-"`
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+}
+
+// Firing is one triggered rule, as stored in the alert history.
+type Firing struct {
+	ID      int       `json:"id"`
+	RuleID  int       `json:"rule_id"`
+	UserID  int       `json:"user_id"`
+	Symbol  string    `json:"symbol"`
+	Kind    string    `json:"kind"`
+	Price   float64   `json:"price"`
+	Message string    `json:"message"`
+	FiredAt time.Time `json:"fired_at"`
+}
+
+type Repository interface {
+	CreateRule(ctx context.Context, rule *Rule) (*Rule, error)
+	ListRules(ctx context.Context, userID int) ([]Rule, error)
+	DeleteRule(ctx context.Context, userID, ruleID int) error
+	ActiveRules(ctx context.Context, symbols []string) ([]Rule, error)
+	ActiveSymbols(ctx context.Context) ([]string, error)
+	SaveRuleState(ctx context.Context, rule *Rule) error
+	RecordFiring(ctx context.Context, firing *Firing) error
+	ListHistory(ctx context.Context, userID, limit int) ([]Firing, error)
+}
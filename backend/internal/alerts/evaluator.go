@@ -0,0 +1,251 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// Evaluate updates the rule's state for a new quote and reports whether it
+// fired, with a human-readable message. sma is only used by cross_sma rules.
+//
+// Threshold rules fire once when their condition becomes true and re-arm
+// when it turns false again. Percent-move rules fire at most once per New
+// York trading day. Crossing rules fire whenever the price moves to the
+// other side of the average; the first observation only records the side.
+func (r *Rule) Evaluate(q *stocks.Quote, sma float64, now time.Time) (string, bool) {
+	switch r.Kind {
+	case KindAbove, KindBelow:
+		met := q.Price >= r.Threshold
+		if r.Kind == KindBelow {
+			met = q.Price <= r.Threshold
+		}
+		if !met {
+			r.Armed = true
+			return "", false
+		}
+		if !r.Armed {
+			return "", false
+		}
+		r.Armed = false
+		r.LastFiredAt = &now
+		return fmt.Sprintf("%s is at %.2f, %s %.2f", r.Symbol, q.Price, r.Kind, r.Threshold), true
+
+	case KindPercentMove:
+		pct := q.ChangePercent
+		if pct == 0 && q.PreviousClose > 0 {
+			pct = (q.Price - q.PreviousClose) / q.PreviousClose * 100
+		}
+		if math.Abs(pct) < r.Threshold {
+			return "", false
+		}
+		if r.LastFiredAt != nil && sameDay(*r.LastFiredAt, now) {
+			return "", false
+		}
+		r.LastFiredAt = &now
+		return fmt.Sprintf("%s moved %+.2f%% today (threshold %.2f%%)", r.Symbol, pct, r.Threshold), true
+
+	case KindCrossSMA:
+		if sma <= 0 {
+			return "", false
+		}
+		side := 0
+		switch {
+		case q.Price > sma:
+			side = 1
+		case q.Price < sma:
+			side = -1
+		}
+		prev := r.LastSide
+		if side == 0 {
+			return "", false
+		}
+		r.LastSide = side
+		if prev == 0 || prev == side {
+			return "", false
+		}
+		r.LastFiredAt = &now
+		direction := "above"
+		if side < 0 {
+			direction = "below"
+		}
+		return fmt.Sprintf("%s crossed %s its %d-day average (%.2f) at %.2f", r.Symbol, direction, r.Window, sma, q.Price), true
+	}
+	return "", false
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.In(market.NewYork).Date()
+	by, bm, bd := b.In(market.NewYork).Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// FireFunc is called for every rule that fires, after it has been recorded
+// in the alert history.
+type FireFunc func(ctx context.Context, f Firing)
+
+type smaEntry struct {
+	value float64
+	day   string
+}
+
+// Evaluator checks active rules against each batch of quotes polled by the
+// ticker. It runs in its own goroutine so slow rule lookups never hold up
+// quote delivery.
+type Evaluator struct {
+	repo     Repository
+	provider stocks.Provider
+	quotes   chan map[string]*stocks.Quote
+	onFire   []FireFunc
+
+	mu  sync.Mutex
+	sma map[string]smaEntry
+}
+
+func NewEvaluator(repo Repository, provider stocks.Provider) *Evaluator {
+	return &Evaluator{
+		repo:     repo,
+		provider: provider,
+		quotes:   make(chan map[string]*stocks.Quote, 1),
+		sma:      make(map[string]smaEntry),
+	}
+}
+
+// OnFire registers fn to be told about every firing. It must be called
+// before Run.
+func (e *Evaluator) OnFire(fn FireFunc) {
+	e.onFire = append(e.onFire, fn)
+}
+
+// Symbols returns every symbol with at least one active rule, so the ticker
+// polls them even when nobody is subscribed.
+func (e *Evaluator) Symbols(ctx context.Context) ([]string, error) {
+	return e.repo.ActiveSymbols(ctx)
+}
+
+// Feed hands a batch of quotes to the evaluator without blocking. If the
+// previous batch is still queued it is replaced, since only the latest
+// prices matter.
+func (e *Evaluator) Feed(quotes map[string]*stocks.Quote) {
+	for {
+		select {
+		case e.quotes <- quotes:
+			return
+		default:
+		}
+		select {
+		case <-e.quotes:
+		default:
+		}
+	}
+}
+
+// Run evaluates fed quotes until ctx is cancelled.
+func (e *Evaluator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case quotes := <-e.quotes:
+			e.Evaluate(ctx, quotes, time.Now())
+		}
+	}
+}
+
+// Evaluate checks every active rule for the quoted symbols.
+func (e *Evaluator) Evaluate(ctx context.Context, quotes map[string]*stocks.Quote, now time.Time) {
+	if len(quotes) == 0 {
+		return
+	}
+	symbols := make([]string, 0, len(quotes))
+	for sym := range quotes {
+		symbols = append(symbols, sym)
+	}
+	rules, err := e.repo.ActiveRules(ctx, symbols)
+	if err != nil {
+		log.Printf("alerts: %v", err)
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		q := quotes[rule.Symbol]
+		if q == nil {
+			continue
+		}
+
+		var sma float64
+		if rule.Kind == KindCrossSMA {
+			sma, err = e.movingAverage(ctx, rule.Symbol, rule.Window, now)
+			if err != nil {
+				log.Printf("alerts: %d-day average for %s: %v", rule.Window, rule.Symbol, err)
+				continue
+			}
+		}
+
+		before := *rule
+		message, fired := rule.Evaluate(q, sma, now)
+		if rule.Armed != before.Armed || rule.LastSide != before.LastSide || rule.LastFiredAt != before.LastFiredAt {
+			if err := e.repo.SaveRuleState(ctx, rule); err != nil {
+				log.Printf("alerts: %v", err)
+			}
+		}
+		if !fired {
+			continue
+		}
+
+		firing := Firing{
+			RuleID:  rule.ID,
+			UserID:  rule.UserID,
+			Symbol:  rule.Symbol,
+			Kind:    rule.Kind,
+			Price:   q.Price,
+			Message: message,
+			FiredAt: now,
+		}
+		if err := e.repo.RecordFiring(ctx, &firing); err != nil {
+			log.Printf("alerts: %v", err)
+		}
+		for _, fn := range e.onFire {
+			fn(ctx, firing)
+		}
+	}
+}
+
+// movingAverage returns the simple average of the last window daily closes,
+// cached for the rest of the New York day.
+func (e *Evaluator) movingAverage(ctx context.Context, symbol string, window int, now time.Time) (float64, error) {
+	key := fmt.Sprintf("%s/%d", symbol, window)
+	day := now.In(market.NewYork).Format("2006-01-02")
+
+	e.mu.Lock()
+	entry, ok := e.sma[key]
+	e.mu.Unlock()
+	if ok && entry.day == day {
+		return entry.value, nil
+	}
+
+	candles, err := e.provider.Intraday(ctx, symbol, "daily", window)
+	if err != nil {
+		return 0, err
+	}
+	if len(candles) < window {
+		return 0, fmt.Errorf("only %d candles available", len(candles))
+	}
+	var sum float64
+	for _, c := range candles[len(candles)-window:] {
+		sum += c.Close
+	}
+	value := sum / float64(window)
+
+	e.mu.Lock()
+	e.sma[key] = smaEntry{value: value, day: day}
+	e.mu.Unlock()
+	return value, nil
+}
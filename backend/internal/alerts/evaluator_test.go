@@ -0,0 +1,167 @@
+package alerts_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/alerts"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+func TestThresholdRuleFiresOnceUntilRearmed(t *testing.T) {
+	rule := &alerts.Rule{Symbol: "AAPL", Kind: alerts.KindAbove, Threshold: 200, Armed: true}
+	now := time.Now()
+
+	steps := []struct {
+		price float64
+		fire  bool
+	}{
+		{190, false},
+		{201, true},
+		{205, false}, // still above, already fired
+		{199, false}, // re-arms
+		{200, true},
+	}
+	for i, step := range steps {
+		_, fired := rule.Evaluate(&stocks.Quote{Price: step.price}, 0, now)
+		if fired != step.fire {
+			t.Errorf("step %d (price %.2f): expected fired=%v, got %v", i, step.price, step.fire, fired)
+		}
+	}
+}
+
+func TestPercentMoveFiresOncePerDay(t *testing.T) {
+	rule := &alerts.Rule{Symbol: "TSLA", Kind: alerts.KindPercentMove, Threshold: 5}
+	day1 := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+
+	if _, fired := rule.Evaluate(&stocks.Quote{ChangePercent: 3}, 0, day1); fired {
+		t.Error("3% move should not fire a 5% rule")
+	}
+	if _, fired := rule.Evaluate(&stocks.Quote{ChangePercent: -5.5}, 0, day1); !fired {
+		t.Error("-5.5% move should fire")
+	}
+	if _, fired := rule.Evaluate(&stocks.Quote{ChangePercent: -7}, 0, day1.Add(time.Hour)); fired {
+		t.Error("rule fired twice on the same day")
+	}
+	// Falls back to PreviousClose when the provider leaves ChangePercent empty.
+	if _, fired := rule.Evaluate(&stocks.Quote{Price: 106, PreviousClose: 100}, 0, day1.AddDate(0, 0, 1)); !fired {
+		t.Error("6% move on the next day should fire")
+	}
+}
+
+func TestCrossSMARuleFiresOnSideChange(t *testing.T) {
+	rule := &alerts.Rule{Symbol: "MSFT", Kind: alerts.KindCrossSMA, Window: 50}
+	now := time.Now()
+
+	if _, fired := rule.Evaluate(&stocks.Quote{Price: 90}, 100, now); fired {
+		t.Error("first observation should only record the side")
+	}
+	if _, fired := rule.Evaluate(&stocks.Quote{Price: 95}, 100, now); fired {
+		t.Error("staying below should not fire")
+	}
+	msg, fired := rule.Evaluate(&stocks.Quote{Price: 101}, 100, now)
+	if !fired {
+		t.Fatal("crossing above should fire")
+	}
+	if msg == "" {
+		t.Error("expected a message")
+	}
+}
+
+type fakeRepo struct {
+	rules   []alerts.Rule
+	fired   []alerts.Firing
+	updated int
+}
+
+func (f *fakeRepo) CreateRule(ctx context.Context, rule *alerts.Rule) (*alerts.Rule, error) {
+	return rule, nil
+}
+func (f *fakeRepo) ListRules(ctx context.Context, userID int) ([]alerts.Rule, error) {
+	return f.rules, nil
+}
+func (f *fakeRepo) DeleteRule(ctx context.Context, userID, ruleID int) error { return nil }
+func (f *fakeRepo) ActiveRules(ctx context.Context, symbols []string) ([]alerts.Rule, error) {
+	out := make([]alerts.Rule, len(f.rules))
+	copy(out, f.rules)
+	return out, nil
+}
+func (f *fakeRepo) ActiveSymbols(ctx context.Context) ([]string, error) { return nil, nil }
+func (f *fakeRepo) SaveRuleState(ctx context.Context, rule *alerts.Rule) error {
+	for i := range f.rules {
+		if f.rules[i].ID == rule.ID {
+			f.rules[i] = *rule
+		}
+	}
+	f.updated++
+	return nil
+}
+func (f *fakeRepo) RecordFiring(ctx context.Context, firing *alerts.Firing) error {
+	firing.ID = len(f.fired) + 1
+	f.fired = append(f.fired, *firing)
+	return nil
+}
+func (f *fakeRepo) ListHistory(ctx context.Context, userID, limit int) ([]alerts.Firing, error) {
+	return f.fired, nil
+}
+
+func TestEvaluatorRecordsAndNotifies(t *testing.T) {
+	repo := &fakeRepo{rules: []alerts.Rule{
+		{ID: 1, UserID: 7, Symbol: "MSFT", Kind: alerts.KindCrossSMA, Window: 5, LastSide: -1},
+		{ID: 2, UserID: 8, Symbol: "AAPL", Kind: alerts.KindBelow, Threshold: 100, Armed: true},
+	}}
+	// The mock provider's closes average 122.5, so 130 is above the average.
+	evaluator := alerts.NewEvaluator(repo, stocks.NewMock())
+
+	var notified []alerts.Firing
+	evaluator.OnFire(func(ctx context.Context, f alerts.Firing) {
+		notified = append(notified, f)
+	})
+
+	evaluator.Evaluate(context.Background(), map[string]*stocks.Quote{
+		"MSFT": {Symbol: "MSFT", Price: 130},
+		"AAPL": {Symbol: "AAPL", Price: 150},
+	}, time.Now())
+
+	if len(repo.fired) != 1 || repo.fired[0].RuleID != 1 || repo.fired[0].UserID != 7 {
+		t.Fatalf("expected rule 1 to be recorded, got %+v", repo.fired)
+	}
+	if len(notified) != 1 || notified[0].ID != 1 {
+		t.Errorf("expected one notification for the recorded firing, got %+v", notified)
+	}
+	if repo.rules[0].LastSide != 1 {
+		t.Errorf("expected rule state to be saved, got side %d", repo.rules[0].LastSide)
+	}
+}
+
+func TestServiceValidatesRules(t *testing.T) {
+	svc := alerts.NewService(&fakeRepo{})
+	ctx := context.Background()
+
+	bad := []alerts.Rule{
+		{Symbol: "", Kind: alerts.KindAbove, Threshold: 1},
+		{Symbol: "NOT A TICKER", Kind: alerts.KindAbove, Threshold: 1},
+		{Symbol: "AAPL", Kind: "sideways", Threshold: 1},
+		{Symbol: "AAPL", Kind: alerts.KindAbove},
+		{Symbol: "AAPL", Kind: alerts.KindCrossSMA, Window: 1},
+	}
+	for _, rule := range bad {
+		if _, err := svc.CreateRule(ctx, 1, rule); err != alerts.ErrInvalidRule {
+			t.Errorf("expected ErrInvalidRule for %+v, got %v", rule, err)
+		}
+	}
+
+	rule, err := svc.CreateRule(ctx, 1, alerts.Rule{Symbol: " msft ", Kind: alerts.KindCrossSMA, Window: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Symbol != "MSFT" || rule.UserID != 1 {
+		t.Errorf("expected normalised rule, got %+v", rule)
+	}
+
+	full := alerts.NewService(&fakeRepo{rules: make([]alerts.Rule, 50)})
+	if _, err := full.CreateRule(ctx, 1, alerts.Rule{Symbol: "MSFT", Kind: alerts.KindAbove, Threshold: 1}); err != alerts.ErrTooManyRules {
+		t.Errorf("expected ErrTooManyRules at the cap, got %v", err)
+	}
+}
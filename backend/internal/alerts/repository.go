@@ -0,0 +1,137 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+const ruleColumns = "id, user_id, symbol, kind, threshold, window_days, active, created_at, armed, last_side, last_fired_at"
+
+func scanRule(row pgx.Row) (*Rule, error) {
+	var r Rule
+	err := row.Scan(&r.ID, &r.UserID, &r.Symbol, &r.Kind, &r.Threshold, &r.Window, &r.Active, &r.CreatedAt, &r.Armed, &r.LastSide, &r.LastFiredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func collectRules(rows pgx.Rows) ([]Rule, error) {
+	defer rows.Close()
+	var rules []Rule
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+func (r *PostgresRepository) CreateRule(ctx context.Context, rule *Rule) (*Rule, error) {
+	row := r.db.QueryRow(ctx,
+		"INSERT INTO alert_rules (user_id, symbol, kind, threshold, window_days, armed) VALUES ($1, $2, $3, $4, $5, TRUE) RETURNING "+ruleColumns,
+		rule.UserID, rule.Symbol, rule.Kind, rule.Threshold, rule.Window)
+	created, err := scanRule(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PostgresRepository) ListRules(ctx context.Context, userID int) ([]Rule, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+ruleColumns+" FROM alert_rules WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	return collectRules(rows)
+}
+
+func (r *PostgresRepository) DeleteRule(ctx context.Context, userID, ruleID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM alert_rules WHERE id = $1 AND user_id = $2", ruleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ActiveRules(ctx context.Context, symbols []string) ([]Rule, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+ruleColumns+" FROM alert_rules WHERE active AND symbol = ANY($1)", symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active alert rules: %w", err)
+	}
+	return collectRules(rows)
+}
+
+func (r *PostgresRepository) ActiveSymbols(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, "SELECT DISTINCT symbol FROM alert_rules WHERE active")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
+
+func (r *PostgresRepository) SaveRuleState(ctx context.Context, rule *Rule) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE alert_rules SET armed = $2, last_side = $3, last_fired_at = $4 WHERE id = $1",
+		rule.ID, rule.Armed, rule.LastSide, rule.LastFiredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule state: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RecordFiring(ctx context.Context, f *Firing) error {
+	err := r.db.QueryRow(ctx,
+		"INSERT INTO alert_history (rule_id, user_id, symbol, kind, price, message, fired_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		f.RuleID, f.UserID, f.Symbol, f.Kind, f.Price, f.Message, f.FiredAt).Scan(&f.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record alert: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListHistory(ctx context.Context, userID, limit int) ([]Firing, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT id, COALESCE(rule_id, 0), user_id, symbol, kind, price, message, fired_at FROM alert_history WHERE user_id = $1 ORDER BY fired_at DESC LIMIT $2",
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []Firing
+	for rows.Next() {
+		var f Firing
+		if err := rows.Scan(&f.ID, &f.RuleID, &f.UserID, &f.Symbol, &f.Kind, &f.Price, &f.Message, &f.FiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		history = append(history, f)
+	}
+	return history, rows.Err()
+}
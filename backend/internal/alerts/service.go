@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+var (
+	ErrInvalidRule  = errors.New("invalid alert rule")
+	ErrRuleNotFound = errors.New("alert rule not found")
+	ErrTooManyRules = errors.New("too many alert rules")
+)
+
+// maxRulesPerUser caps how many rules one user may hold. Every rule's
+// symbol is polled upstream, so this also bounds what a user can add to
+// the poll set.
+const maxRulesPerUser = 50
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// CreateRule validates and stores a new rule for userID.
+func (s *Service) CreateRule(ctx context.Context, userID int, rule Rule) (*Rule, error) {
+	rule.UserID = userID
+	symbol, ok := stocks.NormalizeSymbol(rule.Symbol)
+	if !ok {
+		return nil, ErrInvalidRule
+	}
+	rule.Symbol = symbol
+	switch rule.Kind {
+	case KindAbove, KindBelow, KindPercentMove:
+		if rule.Threshold <= 0 {
+			return nil, ErrInvalidRule
+		}
+		rule.Window = 0
+	case KindCrossSMA:
+		if rule.Window < 2 || rule.Window > 200 {
+			return nil, ErrInvalidRule
+		}
+		rule.Threshold = 0
+	default:
+		return nil, ErrInvalidRule
+	}
+
+	existing, err := s.repo.ListRules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxRulesPerUser {
+		return nil, ErrTooManyRules
+	}
+	return s.repo.CreateRule(ctx, &rule)
+}
+
+func (s *Service) ListRules(ctx context.Context, userID int) ([]Rule, error) {
+	return s.repo.ListRules(ctx, userID)
+}
+
+func (s *Service) DeleteRule(ctx context.Context, userID, ruleID int) error {
+	return s.repo.DeleteRule(ctx, userID, ruleID)
+}
+
+func (s *Service) History(ctx context.Context, userID, limit int) ([]Firing, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.ListHistory(ctx, userID, limit)
+}
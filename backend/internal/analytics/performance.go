@@ -0,0 +1,269 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+var ErrInvalidRange = errors.New("invalid date range")
+
+// Performance is a portfolio's value history and returns over a date range.
+//
+// Adding a symbol counts as buying one share at that day's close, and
+// removing it as selling at the close; those are the cash flows. TWR
+// (time-weighted return) removes their effect, MWR (money-weighted, the
+// internal rate of return) includes it. MWR is null when it has no solution.
+type Performance struct {
+	From            time.Time          `json:"from"`
+	To              time.Time          `json:"to"`
+	Benchmark       string             `json:"benchmark"`
+	StartValue      float64            `json:"start_value"`
+	EndValue        float64            `json:"end_value"`
+	NetCashFlow     float64            `json:"net_cash_flow"`
+	TWR             float64            `json:"twr"`
+	MWR             *float64           `json:"mwr"`
+	BenchmarkReturn float64            `json:"benchmark_return"`
+	Series          []PerformancePoint `json:"series"`
+	CashFlows       []CashFlow         `json:"cash_flows"`
+	Missing         []string           `json:"missing,omitempty"`
+}
+
+// PerformancePoint is one trading day. The indexes start at 100 so the
+// portfolio and benchmark lines can be drawn on the same axis.
+type PerformancePoint struct {
+	Date           time.Time `json:"date"`
+	Value          float64   `json:"value"`
+	CashFlow       float64   `json:"cash_flow"`
+	TWRIndex       float64   `json:"twr_index"`
+	BenchmarkIndex float64   `json:"benchmark_index"`
+}
+
+// CashFlow is money moved into (positive) or out of the portfolio.
+type CashFlow struct {
+	Date   time.Time `json:"date"`
+	Symbol string    `json:"symbol"`
+	Amount float64   `json:"amount"`
+}
+
+// effectiveDay is the trading day whose close an event is priced at:
+// changes after the close, or on a closed day, apply at the next close.
+func effectiveDay(at time.Time) time.Time {
+	if market.IsTradingDay(at) && at.Before(market.Close(at)) {
+		return market.Date(at)
+	}
+	return market.NextTradingDay(at)
+}
+
+// closesByDay indexes daily candles by their calendar date.
+func closesByDay(candles []stocks.Candle) map[time.Time]float64 {
+	out := make(map[time.Time]float64, len(candles))
+	for _, c := range candles {
+		y, m, d := c.Time.Date()
+		out[time.Date(y, m, d, 0, 0, 0, 0, market.NewYork)] = c.Close
+	}
+	return out
+}
+
+// ComputePerformance replays events over the benchmark's trading days
+// between from and to. candles must hold daily bars for the benchmark and
+// every symbol in events. Days where a held symbol has no bar use its last
+// known close.
+func ComputePerformance(events []users.PortfolioEvent, benchmark string, candles map[string][]stocks.Candle, from, to time.Time) (*Performance, error) {
+	from, to = market.Date(from), market.Date(to)
+	if to.Before(from) {
+		return nil, ErrInvalidRange
+	}
+
+	bench := closesByDay(candles[benchmark])
+	var days []time.Time
+	for day := range bench {
+		if !day.Before(from) && !day.After(to) {
+			days = append(days, day)
+		}
+	}
+	if len(days) < 2 {
+		return nil, ErrInsufficientData
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	prices := make(map[string]map[time.Time]float64)
+	last := make(map[string]float64)
+	for _, e := range events {
+		if _, ok := prices[e.Symbol]; !ok {
+			prices[e.Symbol] = closesByDay(candles[e.Symbol])
+		}
+	}
+	// Seed last-known closes from bars before the range.
+	for sym, byDay := range prices {
+		var latest time.Time
+		for day, c := range byDay {
+			if day.Before(days[0]) && day.After(latest) {
+				latest, last[sym] = day, c
+			}
+		}
+	}
+
+	p := &Performance{From: days[0], To: days[len(days)-1], Benchmark: benchmark}
+	held := make(map[string]bool)
+	missing := make(map[string]bool)
+	next := 0
+	twr := 1.0
+	prevValue := 0.0
+
+	for i, day := range days {
+		for sym, byDay := range prices {
+			if c, ok := byDay[day]; ok {
+				last[sym] = c
+			}
+		}
+
+		// Apply every event priced at or before this close.
+		flow := 0.0
+		for ; next < len(events) && !effectiveDay(events[next].At).After(day); next++ {
+			e := events[next]
+			adding := e.Action == users.ActionAdd
+			if held[e.Symbol] == adding {
+				continue // duplicate add or remove of an unheld symbol
+			}
+			held[e.Symbol] = adding
+			if i == 0 {
+				continue // folded into the starting value
+			}
+			amount := last[e.Symbol]
+			if !adding {
+				amount = -amount
+			}
+			flow += amount
+			p.CashFlows = append(p.CashFlows, CashFlow{Date: day, Symbol: e.Symbol, Amount: amount})
+		}
+
+		value := 0.0
+		for sym, ok := range held {
+			if !ok {
+				continue
+			}
+			if c, priced := last[sym]; priced {
+				value += c
+			} else {
+				missing[sym] = true
+			}
+		}
+
+		if i > 0 && prevValue > 0 {
+			twr *= (value - flow) / prevValue
+		}
+		p.Series = append(p.Series, PerformancePoint{
+			Date:           day,
+			Value:          value,
+			CashFlow:       flow,
+			TWRIndex:       100 * twr,
+			BenchmarkIndex: 100 * bench[day] / bench[days[0]],
+		})
+		p.NetCashFlow += flow
+		prevValue = value
+	}
+
+	p.StartValue = p.Series[0].Value
+	p.EndValue = p.Series[len(p.Series)-1].Value
+	p.TWR = twr - 1
+	p.BenchmarkReturn = bench[days[len(days)-1]]/bench[days[0]] - 1
+	for sym := range missing {
+		p.Missing = append(p.Missing, sym)
+	}
+	sort.Strings(p.Missing)
+	if r, ok := irr(p.Series); ok {
+		p.MWR = &r
+	}
+	return p, nil
+}
+
+// irr solves for the rate at which the starting value and cash flows (paid
+// in) grow to the ending value, compounding by calendar day. Like GIPS, it
+// annualises only ranges of at least a year; shorter ranges report the
+// return over the range.
+func irr(series []PerformancePoint) (float64, bool) {
+	if series[0].Value == 0 && slices.IndexFunc(series, func(pt PerformancePoint) bool { return pt.CashFlow != 0 }) < 0 {
+		return 0, false
+	}
+	days := func(t time.Time) float64 {
+		y, m, d := t.Date()
+		y0, m0, d0 := series[0].Date.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(time.Date(y0, m0, d0, 0, 0, 0, 0, time.UTC)).Hours() / 24
+	}
+	end := series[len(series)-1]
+	span := days(end.Date)
+
+	// npv discounts at rate over the whole span; flows part-way through
+	// are discounted by the fraction of the span elapsed.
+	npv := func(rate float64) float64 {
+		sum := -series[0].Value
+		for _, pt := range series[1:] {
+			sum -= pt.CashFlow / math.Pow(1+rate, days(pt.Date)/span)
+		}
+		return sum + end.Value/(1+rate)
+	}
+
+	lo, hi := -0.9999, 1000.0
+	if npv(lo)*npv(hi) > 0 {
+		return 0, false
+	}
+	for i := 0; i < 200 && hi-lo > 1e-12; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	rate := (lo + hi) / 2
+	if years := span / 365; years >= 1 {
+		rate = math.Pow(1+rate, 1/years) - 1
+	}
+	return rate, true
+}
+
+// Performance computes userID's portfolio performance between from and to
+// against benchmark (or the service default).
+func (s *Service) Performance(ctx context.Context, userID int, benchmark string, from, to time.Time) (*Performance, error) {
+	if benchmark = strings.ToUpper(strings.TrimSpace(benchmark)); benchmark == "" {
+		benchmark = s.Benchmark
+	}
+	if to.Before(from) || time.Since(from) > 5*365*24*time.Hour {
+		return nil, ErrInvalidRange
+	}
+	events, err := s.portfolios.PortfolioHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrEmptyPortfolio
+	}
+
+	symbols := []string{benchmark}
+	for _, e := range events {
+		if !slices.Contains(symbols, e.Symbol) {
+			symbols = append(symbols, e.Symbol)
+		}
+	}
+	// Enough bars to reach back to from, plus a few to seed prior closes.
+	limit := 5
+	for day := market.Date(from); !day.After(time.Now()); day = day.AddDate(0, 0, 1) {
+		if market.IsTradingDay(day) {
+			limit++
+		}
+	}
+	candles, err := s.dailyCandles(ctx, symbols, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ComputePerformance(events, benchmark, candles, from, to)
+}
@@ -0,0 +1,97 @@
+package analytics_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/analytics"
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// tradingBars returns bars for the trading days from Monday 2026-03-02,
+// skipping the weekend.
+func tradingBars(closes ...float64) []stocks.Candle {
+	var out []stocks.Candle
+	day := start
+	for _, c := range closes {
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		out = append(out, stocks.Candle{Time: day, Close: c})
+		day = day.AddDate(0, 0, 1)
+	}
+	return out
+}
+
+func nyAt(day, hour int) time.Time {
+	return time.Date(2026, time.March, day, hour, 0, 0, 0, market.NewYork)
+}
+
+func TestComputePerformance(t *testing.T) {
+	candles := map[string][]stocks.Candle{
+		//                  Mar 2  3    4    5    6    9
+		"SPY": tradingBars(100, 101, 102, 103, 104, 105),
+		"AAA": tradingBars(10, 11, 12, 12, 12, 12),
+		"BBB": tradingBars(20, 20, 20, 22, 22, 22),
+	}
+	events := []users.PortfolioEvent{
+		{Symbol: "AAA", Action: users.ActionAdd, At: nyAt(1, 12)},    // Sunday: held from the start
+		{Symbol: "BBB", Action: users.ActionAdd, At: nyAt(3, 17)},    // after the close: bought on the 4th
+		{Symbol: "AAA", Action: users.ActionRemove, At: nyAt(6, 10)}, // sold at the close on the 6th
+		{Symbol: "BBB", Action: users.ActionAdd, At: nyAt(6, 11)},    // duplicate, ignored
+	}
+
+	p, err := analytics.ComputePerformance(events, "SPY", candles, nyAt(2, 0), nyAt(9, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantValues := []float64{10, 11, 32, 34, 22, 22}
+	wantFlows := []float64{0, 0, 20, 0, -12, 0}
+	if len(p.Series) != len(wantValues) {
+		t.Fatalf("expected %d points, got %d", len(wantValues), len(p.Series))
+	}
+	for i, pt := range p.Series {
+		if pt.Value != wantValues[i] || pt.CashFlow != wantFlows[i] {
+			t.Errorf("day %d: got value %.2f flow %.2f, want %.2f / %.2f", i, pt.Value, pt.CashFlow, wantValues[i], wantFlows[i])
+		}
+	}
+	if len(p.CashFlows) != 2 || p.CashFlows[0].Symbol != "BBB" || p.CashFlows[1].Amount != -12 {
+		t.Errorf("unexpected cash flows %+v", p.CashFlows)
+	}
+
+	// Daily returns net of flows: 11/10, 12/11, 34/32, 22+12 / 34, 22/22.
+	wantTWR := 1.1*(12.0/11)*(34.0/32)*1*1 - 1
+	if math.Abs(p.TWR-wantTWR) > 1e-12 {
+		t.Errorf("twr: got %.6f, want %.6f", p.TWR, wantTWR)
+	}
+	if math.Abs(p.BenchmarkReturn-0.05) > 1e-12 || p.Series[5].BenchmarkIndex != 105 {
+		t.Errorf("unexpected benchmark line %.4f / %.2f", p.BenchmarkReturn, p.Series[5].BenchmarkIndex)
+	}
+	if p.MWR == nil || *p.MWR <= 0 {
+		t.Errorf("expected a positive money-weighted return, got %v", p.MWR)
+	}
+}
+
+func TestMWRWithoutFlowsIsPeriodGrowth(t *testing.T) {
+	candles := map[string][]stocks.Candle{
+		"SPY": tradingBars(100, 100, 100, 100, 100, 100),
+		"AAA": tradingBars(100, 101, 102, 103, 104, 110),
+	}
+	events := []users.PortfolioEvent{{Symbol: "AAA", Action: users.ActionAdd, At: nyAt(1, 12)}}
+
+	p, err := analytics.ComputePerformance(events, "SPY", candles, nyAt(2, 0), nyAt(9, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0.1 // under a year, so not annualised
+	if p.MWR == nil || math.Abs(*p.MWR-want)/want > 1e-6 {
+		t.Errorf("mwr: got %v, want %.4f", *p.MWR, want)
+	}
+	if math.Abs(p.TWR-0.1) > 1e-12 {
+		t.Errorf("twr: got %.6f, want 0.1", p.TWR)
+	}
+}
@@ -0,0 +1,196 @@
+package analytics
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// Risk summarises a portfolio's risk over a lookback window. Ratios are
+// annualised; volatility and drawdown are fractions (0.2 is 20%).
+type Risk struct {
+	Benchmark    string                        `json:"benchmark"`
+	From         time.Time                     `json:"from"`
+	To           time.Time                     `json:"to"`
+	Observations int                           `json:"observations"`
+	RiskFreeRate float64                       `json:"risk_free_rate"`
+	Volatility   float64                       `json:"volatility"`
+	Beta         float64                       `json:"beta"`
+	Sharpe       float64                       `json:"sharpe"`
+	Sortino      float64                       `json:"sortino"`
+	MaxDrawdown  Drawdown                      `json:"max_drawdown"`
+	Holdings     []HoldingRisk                 `json:"holdings"`
+	Correlation  map[string]map[string]float64 `json:"correlation"`
+}
+
+// HoldingRisk is the volatility and beta of one symbol.
+type HoldingRisk struct {
+	Symbol     string  `json:"symbol"`
+	Volatility float64 `json:"volatility"`
+	Beta       float64 `json:"beta"`
+}
+
+// Drawdown is the largest peak-to-trough fall in value.
+type Drawdown struct {
+	Depth  float64   `json:"depth"`
+	Peak   time.Time `json:"peak"`
+	Trough time.Time `json:"trough"`
+}
+
+// ComputeRisk evaluates symbols against benchmark using daily candles keyed
+// by symbol. riskFree is the annual risk-free rate, e.g. 0.04.
+func ComputeRisk(symbols []string, benchmark string, candles map[string][]stocks.Candle, riskFree float64) (*Risk, error) {
+	if len(symbols) == 0 {
+		return nil, ErrEmptyPortfolio
+	}
+	all := symbols
+	if !slices.Contains(symbols, benchmark) {
+		all = append(slices.Clone(symbols), benchmark)
+	}
+	a := align(candles, all)
+	if len(a.dates) < 3 {
+		return nil, ErrInsufficientData
+	}
+
+	value := a.total(symbols)
+	portfolio := returns(value)
+	bench := returns(a.closes[benchmark])
+	rfDaily := riskFree / TradingDaysPerYear
+
+	r := &Risk{
+		Benchmark:    benchmark,
+		From:         a.dates[0],
+		To:           a.dates[len(a.dates)-1],
+		Observations: len(portfolio),
+		RiskFreeRate: riskFree,
+		Volatility:   annualVolatility(portfolio),
+		Beta:         beta(portfolio, bench),
+		Sharpe:       sharpe(portfolio, rfDaily),
+		Sortino:      sortino(portfolio, rfDaily),
+		MaxDrawdown:  maxDrawdown(a.dates, value),
+		Correlation:  make(map[string]map[string]float64, len(symbols)),
+	}
+
+	perSymbol := make(map[string][]float64, len(symbols))
+	for _, sym := range symbols {
+		perSymbol[sym] = returns(a.closes[sym])
+		r.Holdings = append(r.Holdings, HoldingRisk{
+			Symbol:     sym,
+			Volatility: annualVolatility(perSymbol[sym]),
+			Beta:       beta(perSymbol[sym], bench),
+		})
+	}
+	for _, x := range symbols {
+		r.Correlation[x] = make(map[string]float64, len(symbols))
+		for _, y := range symbols {
+			r.Correlation[x][y] = correlation(perSymbol[x], perSymbol[y])
+		}
+	}
+	return r, nil
+}
+
+func annualVolatility(rets []float64) float64 {
+	return stdev(rets) * math.Sqrt(TradingDaysPerYear)
+}
+
+func beta(rets, bench []float64) float64 {
+	v := covariance(bench, bench)
+	if v == 0 {
+		return 0
+	}
+	return covariance(rets, bench) / v
+}
+
+func sharpe(rets []float64, rfDaily float64) float64 {
+	sd := stdev(rets)
+	if sd == 0 {
+		return 0
+	}
+	return (mean(rets) - rfDaily) / sd * math.Sqrt(TradingDaysPerYear)
+}
+
+// sortino divides excess return by the downside deviation: the root mean
+// square of returns below the risk-free rate, counting the others as zero.
+func sortino(rets []float64, rfDaily float64) float64 {
+	if len(rets) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, r := range rets {
+		if d := math.Min(r-rfDaily, 0); d < 0 {
+			sum += d * d
+		}
+	}
+	dd := math.Sqrt(sum / float64(len(rets)))
+	if dd == 0 {
+		return 0
+	}
+	return (mean(rets) - rfDaily) / dd * math.Sqrt(TradingDaysPerYear)
+}
+
+func maxDrawdown(dates []time.Time, values []float64) Drawdown {
+	var dd Drawdown
+	peak := 0
+	for i, v := range values {
+		if v > values[peak] {
+			peak = i
+		}
+		if depth := 1 - v/values[peak]; depth > dd.Depth {
+			dd = Drawdown{Depth: depth, Peak: dates[peak], Trough: dates[i]}
+		}
+	}
+	return dd
+}
+
+// Service computes analytics for users' portfolios from provider candles.
+type Service struct {
+	provider   stocks.Provider
+	portfolios PortfolioSource
+
+	Benchmark    string
+	RiskFreeRate float64
+}
+
+func NewService(provider stocks.Provider, portfolios PortfolioSource) *Service {
+	return &Service{provider: provider, portfolios: portfolios, Benchmark: "SPY"}
+}
+
+// Risk computes userID's portfolio risk over the last days trading days.
+// An empty benchmark uses the service default.
+func (s *Service) Risk(ctx context.Context, userID int, benchmark string, days int) (*Risk, error) {
+	if benchmark = strings.ToUpper(strings.TrimSpace(benchmark)); benchmark == "" {
+		benchmark = s.Benchmark
+	}
+	if days <= 0 || days > 5*TradingDaysPerYear {
+		days = TradingDaysPerYear
+	}
+	symbols, err := s.portfolios.GetPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := s.dailyCandles(ctx, append(slices.Clone(symbols), benchmark), days+1)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeRisk(symbols, benchmark, candles, s.RiskFreeRate)
+}
+
+// dailyCandles fetches the last limit daily bars for each symbol.
+func (s *Service) dailyCandles(ctx context.Context, symbols []string, limit int) (map[string][]stocks.Candle, error) {
+	out := make(map[string][]stocks.Candle, len(symbols))
+	for _, sym := range symbols {
+		if _, ok := out[sym]; ok {
+			continue
+		}
+		candles, err := s.provider.Intraday(ctx, sym, "daily", limit)
+		if err != nil {
+			return nil, err
+		}
+		out[sym] = candles
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package analytics_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/analytics"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+var start = time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+// daily returns one candle per day from start with the given closes.
+func daily(closes ...float64) []stocks.Candle {
+	out := make([]stocks.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = stocks.Candle{Time: start.AddDate(0, 0, i), Close: c}
+	}
+	return out
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestComputeRisk(t *testing.T) {
+	candles := map[string][]stocks.Candle{
+		"SPY":  daily(100, 102, 99, 103, 101, 104),
+		"AAA":  daily(50, 51, 49.5, 51.5, 50.5, 52),  // SPY / 2
+		"BBB":  daily(10, 9.8, 10.3, 9.7, 10.1, 9.6), // mostly moves against SPY
+		"GONE": daily(1, 1, 1)[:0],
+	}
+
+	r, err := analytics.ComputeRisk([]string{"AAA"}, "SPY", candles, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(r.Beta, 1) || !near(r.Correlation["AAA"]["AAA"], 1) {
+		t.Errorf("a half-scale copy of the benchmark should have beta 1, got %.6f", r.Beta)
+	}
+	// Hand-computed from the daily returns of AAA.
+	rets := []float64{0.02, 49.5/51 - 1, 51.5/49.5 - 1, 50.5/51.5 - 1, 52/50.5 - 1}
+	m := 0.0
+	for _, x := range rets {
+		m += x / 5
+	}
+	v := 0.0
+	for _, x := range rets {
+		v += (x - m) * (x - m) / 4
+	}
+	if want := math.Sqrt(v) * math.Sqrt(252); !near(r.Volatility, want) {
+		t.Errorf("volatility: got %.6f, want %.6f", r.Volatility, want)
+	}
+	if want := m / math.Sqrt(v) * math.Sqrt(252); !near(r.Sharpe, want) {
+		t.Errorf("sharpe: got %.6f, want %.6f", r.Sharpe, want)
+	}
+	if r.Sortino <= r.Sharpe {
+		t.Errorf("with positive drift sortino should exceed sharpe: %.4f vs %.4f", r.Sortino, r.Sharpe)
+	}
+	if want := 1 - 49.5/51; !near(r.MaxDrawdown.Depth, want) || !r.MaxDrawdown.Trough.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("unexpected drawdown %+v", r.MaxDrawdown)
+	}
+
+	r, err = analytics.ComputeRisk([]string{"AAA", "BBB"}, "SPY", candles, 0.04)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := r.Correlation["AAA"]["BBB"]; c >= 0 || !near(c, r.Correlation["BBB"]["AAA"]) {
+		t.Errorf("expected a symmetric negative correlation, got %.4f", c)
+	}
+	if len(r.Holdings) != 2 || r.Holdings[1].Beta >= 0 {
+		t.Errorf("expected BBB to have a negative beta, got %+v", r.Holdings)
+	}
+
+	if _, err := analytics.ComputeRisk(nil, "SPY", candles, 0); !errors.Is(err, analytics.ErrEmptyPortfolio) {
+		t.Errorf("expected ErrEmptyPortfolio, got %v", err)
+	}
+	if _, err := analytics.ComputeRisk([]string{"GONE"}, "SPY", candles, 0); !errors.Is(err, analytics.ErrInsufficientData) {
+		t.Errorf("expected ErrInsufficientData, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package analytics computes portfolio statistics from daily candles.
+//
+// Portfolios hold one share of each symbol, as everywhere else in the API,
+// so the portfolio value on a day is the sum of its symbols' closes.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// TradingDaysPerYear annualises daily statistics.
+const TradingDaysPerYear = 252
+
+var (
+	ErrEmptyPortfolio   = errors.New("portfolio is empty")
+	ErrInsufficientData = errors.New("not enough price history")
+)
+
+// PortfolioSource lists a user's symbols and their add/remove history;
+// *users.Service satisfies it.
+type PortfolioSource interface {
+	GetPortfolio(ctx context.Context, userID int) ([]string, error)
+	PortfolioHistory(ctx context.Context, userID int) ([]users.PortfolioEvent, error)
+}
+
+// aligned holds closes for several symbols on the dates they all traded.
+type aligned struct {
+	dates  []time.Time
+	closes map[string][]float64
+}
+
+// align keeps the dates present in every symbol's series, in ascending
+// order. Daily bars are matched on their calendar date.
+func align(candles map[string][]stocks.Candle, symbols []string) aligned {
+	counts := make(map[string]int)
+	byDate := make(map[string]map[string]float64)
+	stamps := make(map[string]time.Time)
+	for _, sym := range symbols {
+		for _, c := range candles[sym] {
+			key := c.Time.Format("2006-01-02")
+			if byDate[key] == nil {
+				byDate[key] = make(map[string]float64)
+				stamps[key] = c.Time
+			}
+			if _, dup := byDate[key][sym]; !dup {
+				counts[key]++
+			}
+			byDate[key][sym] = c.Close
+		}
+	}
+
+	var keys []string
+	for key, n := range counts {
+		if n == len(symbols) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	a := aligned{closes: make(map[string][]float64, len(symbols))}
+	for _, key := range keys {
+		a.dates = append(a.dates, stamps[key])
+		for _, sym := range symbols {
+			a.closes[sym] = append(a.closes[sym], byDate[key][sym])
+		}
+	}
+	return a
+}
+
+// total sums the aligned closes of symbols per date.
+func (a aligned) total(symbols []string) []float64 {
+	out := make([]float64, len(a.dates))
+	for _, sym := range symbols {
+		for i, v := range a.closes[sym] {
+			out[i] += v
+		}
+	}
+	return out
+}
+
+// returns converts a value series to simple period returns.
+func returns(values []float64) []float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	out := make([]float64, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		out[i-1] = values[i]/values[i-1] - 1
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// covariance is the sample covariance of two equal-length series.
+func covariance(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	mx, my := mean(xs), mean(ys)
+	sum := 0.0
+	for i := range xs {
+		sum += (xs[i] - mx) * (ys[i] - my)
+	}
+	return sum / float64(len(xs)-1)
+}
+
+func stdev(xs []float64) float64 {
+	return math.Sqrt(covariance(xs, xs))
+}
+
+func correlation(xs, ys []float64) float64 {
+	sx, sy := stdev(xs), stdev(ys)
+	if sx == 0 || sy == 0 {
+		return 0
+	}
+	return covariance(xs, ys) / (sx * sy)
+}
@@ -0,0 +1,58 @@
+// Package apikeys manages long-lived, scoped keys for scripts that can't
+// log in interactively.
+package apikeys
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidKey  = errors.New("invalid api key request")
+	ErrKeyNotFound = errors.New("api key not found")
+)
+
+// keyPrefix starts every key so leaked keys are easy to recognise.
+const keyPrefix = "gsk_"
+
+// Key is an API key as stored. The secret itself is only ever returned
+// once, from Service.Create.
+type Key struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       []byte     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CreatedKey is returned once on creation and includes the secret.
+type CreatedKey struct {
+	Key
+	Secret string `json:"key"`
+}
+
+func hashKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+type Repository interface {
+	CreateKey(ctx context.Context, k *Key) error
+	ListKeys(ctx context.Context, userID int) ([]Key, error)
+	DeleteKey(ctx context.Context, userID, keyID int) error
+	// DeleteUserKeys deletes every key userID holds.
+	DeleteUserKeys(ctx context.Context, userID int) error
+	GetKeyByHash(ctx context.Context, hash []byte) (*Key, error)
+	// TouchKey records that the key was used at.
+	TouchKey(ctx context.Context, keyID int, at time.Time) error
+}
@@ -0,0 +1,187 @@
+package apikeys_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/apikeys"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+type memRepo struct {
+	keys    []*apikeys.Key
+	touches int
+}
+
+func (m *memRepo) CreateKey(ctx context.Context, k *apikeys.Key) error {
+	k.ID = len(m.keys) + 1
+	stored := *k
+	m.keys = append(m.keys, &stored)
+	return nil
+}
+
+func (m *memRepo) ListKeys(ctx context.Context, userID int) ([]apikeys.Key, error) {
+	var out []apikeys.Key
+	for _, k := range m.keys {
+		if k.UserID == userID {
+			out = append(out, *k)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) DeleteKey(ctx context.Context, userID, keyID int) error {
+	for i, k := range m.keys {
+		if k.ID == keyID && k.UserID == userID {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			return nil
+		}
+	}
+	return apikeys.ErrKeyNotFound
+}
+
+func (m *memRepo) DeleteUserKeys(ctx context.Context, userID int) error {
+	kept := m.keys[:0]
+	for _, k := range m.keys {
+		if k.UserID != userID {
+			kept = append(kept, k)
+		}
+	}
+	m.keys = kept
+	return nil
+}
+
+func (m *memRepo) GetKeyByHash(ctx context.Context, hash []byte) (*apikeys.Key, error) {
+	for _, k := range m.keys {
+		if bytes.Equal(k.Hash, hash) {
+			found := *k
+			return &found, nil
+		}
+	}
+	return nil, apikeys.ErrKeyNotFound
+}
+
+func (m *memRepo) TouchKey(ctx context.Context, keyID int, at time.Time) error {
+	m.touches++
+	for _, k := range m.keys {
+		if k.ID == keyID {
+			k.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+type userTable map[int]*users.User
+
+func (u userTable) GetUser(ctx context.Context, userID int) (*users.User, error) {
+	if user, ok := u[userID]; ok {
+		return user, nil
+	}
+	return nil, users.ErrUserNotFound
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	ctx := context.Background()
+	repo := &memRepo{}
+	table := userTable{1: {ID: 1}}
+	svc := apikeys.NewService(repo, table)
+
+	created, err := svc.Create(ctx, 1, " nightly export ", []string{auth.ScopeReadPortfolio, auth.ScopeReadPortfolio}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Secret, "gsk_") || !strings.HasPrefix(created.Secret, created.Prefix) {
+		t.Errorf("unexpected key %q with prefix %q", created.Secret, created.Prefix)
+	}
+	if created.Name != "nightly export" || len(created.Scopes) != 1 {
+		t.Errorf("expected a trimmed name and deduplicated scopes, got %+v", created.Key)
+	}
+	if bytes.Contains(repo.keys[0].Hash, []byte(created.Secret)) || len(repo.keys[0].Hash) != 32 {
+		t.Error("expected only a SHA-256 hash to be stored")
+	}
+
+	principal, err := svc.VerifyAPIKey(ctx, created.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.UserID != 1 || !principal.HasScope(auth.ScopeReadPortfolio) || principal.HasScope(auth.ScopeWritePortfolio) {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+	svc.VerifyAPIKey(ctx, created.Secret)
+	if repo.touches != 1 {
+		t.Errorf("expected last-used to be written once per minute, got %d writes", repo.touches)
+	}
+
+	for _, bad := range []string{"", "gsk_nope", created.Secret[:len(created.Secret)-1]} {
+		if _, err := svc.VerifyAPIKey(ctx, bad); !errors.Is(err, auth.ErrInvalidAPIKey) {
+			t.Errorf("%q: expected ErrInvalidAPIKey, got %v", bad, err)
+		}
+	}
+
+	now := time.Now()
+	table[1].DisabledAt = &now
+	if _, err := svc.VerifyAPIKey(ctx, created.Secret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("expected a disabled user's key to be rejected, got %v", err)
+	}
+}
+
+func TestExpiredKeyIsRejected(t *testing.T) {
+	ctx := context.Background()
+	repo := &memRepo{}
+	svc := apikeys.NewService(repo, userTable{1: {ID: 1}})
+
+	expires := time.Now().Add(time.Hour)
+	created, err := svc.Create(ctx, 1, "cron", []string{auth.ScopeReadQuotes}, &expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Second)
+	repo.keys[0].ExpiresAt = &past
+	if _, err := svc.VerifyAPIKey(ctx, created.Secret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("expected ErrInvalidAPIKey, got %v", err)
+	}
+}
+
+func TestRevokeAll(t *testing.T) {
+	ctx := context.Background()
+	svc := apikeys.NewService(&memRepo{}, userTable{1: {ID: 1}, 2: {ID: 2}})
+
+	revoked, _ := svc.Create(ctx, 1, "cron", []string{auth.ScopeReadQuotes}, nil)
+	kept, _ := svc.Create(ctx, 2, "cron", []string{auth.ScopeReadQuotes}, nil)
+	if err := svc.RevokeAll(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.VerifyAPIKey(ctx, revoked.Secret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("revoked key: expected ErrInvalidAPIKey, got %v", err)
+	}
+	if _, err := svc.VerifyAPIKey(ctx, kept.Secret); err != nil {
+		t.Errorf("other user's key: %v", err)
+	}
+}
+
+func TestCreateValidates(t *testing.T) {
+	ctx := context.Background()
+	svc := apikeys.NewService(&memRepo{}, userTable{})
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name    string
+		scopes  []string
+		expires *time.Time
+	}{
+		{"", []string{auth.ScopeReadQuotes}, nil},
+		{"no scopes", nil, nil},
+		{"bad scope", []string{"admin:everything"}, nil},
+		{"expired", []string{auth.ScopeReadQuotes}, &past},
+	}
+	for _, c := range cases {
+		if _, err := svc.Create(ctx, 1, c.name, c.scopes, c.expires); !errors.Is(err, apikeys.ErrInvalidKey) {
+			t.Errorf("%q: expected ErrInvalidKey, got %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) CreateKey(ctx context.Context, k *Key) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		k.UserID, k.Name, k.Prefix, k.Hash, k.Scopes, k.ExpiresAt).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListKeys(ctx context.Context, userID int) ([]Key, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		 FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *PostgresRepository) DeleteKey(ctx context.Context, userID, keyID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteUserKeys(ctx context.Context, userID int) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM api_keys WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete api keys: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetKeyByHash(ctx context.Context, hash []byte) (*Key, error) {
+	var k Key
+	err := r.db.QueryRow(ctx,
+		`SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		 FROM api_keys WHERE key_hash = $1`, hash).
+		Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return &k, nil
+}
+
+func (r *PostgresRepository) TouchKey(ctx context.Context, keyID int, at time.Time) error {
+	if _, err := r.db.Exec(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", keyID, at); err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// maxKeysPerUser caps how many keys one user may hold.
+const maxKeysPerUser = 25
+
+// touchInterval limits last-used writes to one per key per interval.
+const touchInterval = time.Minute
+
+// UserSource looks up the owner of a key.
+type UserSource interface {
+	GetUser(ctx context.Context, userID int) (*users.User, error)
+}
+
+type Service struct {
+	repo  Repository
+	users UserSource
+}
+
+func NewService(repo Repository, users UserSource) *Service {
+	return &Service{repo: repo, users: users}
+}
+
+// Create issues a key for userID. expiresAt may be nil for a key that
+// doesn't expire.
+func (s *Service) Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*CreatedKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidKey)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required (%s)", ErrInvalidKey, strings.Join(auth.Scopes(), ", "))
+	}
+	seen := make(map[string]bool, len(scopes))
+	var unique []string
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidKey, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidKey)
+	}
+
+	existing, err := s.repo.ListKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxKeysPerUser {
+		return nil, fmt.Errorf("%w: at most %d keys per user", ErrInvalidKey, maxKeysPerUser)
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k := Key{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(keyPrefix)+6],
+		Hash:      hashKey(secret),
+		Scopes:    unique,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.CreateKey(ctx, &k); err != nil {
+		return nil, err
+	}
+	return &CreatedKey{Key: k, Secret: secret}, nil
+}
+
+func (s *Service) List(ctx context.Context, userID int) ([]Key, error) {
+	return s.repo.ListKeys(ctx, userID)
+}
+
+func (s *Service) Delete(ctx context.Context, userID, keyID int) error {
+	return s.repo.DeleteKey(ctx, userID, keyID)
+}
+
+// RevokeAll deletes all of userID's keys, e.g. after a password reset.
+func (s *Service) RevokeAll(ctx context.Context, userID int) error {
+	return s.repo.DeleteUserKeys(ctx, userID)
+}
+
+// VerifyAPIKey implements auth.APIKeyVerifier. Unknown, expired and
+// disabled users' keys all fail with auth.ErrInvalidAPIKey.
+func (s *Service) VerifyAPIKey(ctx context.Context, key string) (*auth.APIKeyPrincipal, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	k, err := s.repo.GetKeyByHash(ctx, hashKey(key))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if k.Expired(now) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	user, err := s.users.GetUser(ctx, k.UserID)
+	if errors.Is(err, users.ErrUserNotFound) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= touchInterval {
+		if err := s.repo.TouchKey(ctx, k.ID, now); err != nil {
+			log.Printf("apikeys: %v", err)
+		}
+	}
+	return &auth.APIKeyPrincipal{KeyID: k.ID, UserID: k.UserID, Scopes: k.Scopes}, nil
+}
@@ -0,0 +1,69 @@
+// Package audit keeps an append-only record of the changes made through
+// the API: who made them, from which IP, when, and how they turned out.
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Event is one audited request.
+type Event struct {
+	ID int `json:"id"`
+	// ActorID is the user who made the request, if known. It is kept
+	// after the user is deleted.
+	ActorID   *int           `json:"actor_id,omitempty"`
+	Action    string         `json:"action"`
+	Method    string         `json:"method"`
+	Path      string         `json:"path"`
+	Status    int            `json:"status"`
+	IP        string         `json:"ip"`
+	UserAgent string         `json:"user_agent"`
+	Details   map[string]any `json:"details,omitempty"`
+	At        time.Time      `json:"at"`
+}
+
+// Filter narrows a listing of events. Zero fields match everything.
+type Filter struct {
+	ActorID int
+	// Action matches exactly, or as a prefix if it ends in ".", so
+	// "portfolio." matches every portfolio action.
+	Action string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+type Repository interface {
+	Append(ctx context.Context, e *Event) error
+	// List returns matching events, newest first.
+	List(ctx context.Context, f Filter) ([]Event, error)
+}
+
+// Log records and lists audit events.
+type Log struct {
+	repo Repository
+	now  func() time.Time
+}
+
+func NewLog(repo Repository) *Log {
+	return &Log{repo: repo, now: time.Now}
+}
+
+// Record appends e, stamping it with the current time.
+func (l *Log) Record(ctx context.Context, e *Event) error {
+	e.At = l.now()
+	return l.repo.Append(ctx, e)
+}
+
+func (l *Log) List(ctx context.Context, f Filter) ([]Event, error) {
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return l.repo.List(ctx, f)
+}
@@ -1,49 +1,227 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jamesfulreader/gostocks/pkg/config"
 )
 
-var jwtKey = []byte("super_secret_key") // In production, this should be an environment variable
+// keys is the key set GenerateToken and ValidateToken use. Until SetKeys is
+// called it holds a random HS256 key, so tokens only work within this
+// process.
+var keys atomic.Pointer[KeySet]
+
+func init() {
+	k, err := GenerateHMACKey("ephemeral")
+	if err != nil {
+		panic(err)
+	}
+	ks, _ := NewKeySet(k.ID, k)
+	keys.Store(ks)
+}
+
+// SetKeys replaces the key set used to sign and verify tokens.
+func SetKeys(ks *KeySet) {
+	keys.Store(ks)
+}
+
+// Keys returns the key set in use.
+func Keys() *KeySet {
+	return keys.Load()
+}
+
+// KeySetFromEnv loads signing keys from JWT_KEYS_FILE (see LoadKeyFile) or,
+// failing that, a single HS256 JWT_SECRET named by JWT_KEY_ID. It returns a
+// nil set when neither is configured.
+func KeySetFromEnv() (*KeySet, error) {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		return LoadKeyFile(path)
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		k, err := NewHMACKey(config.GetenvDefault("JWT_KEY_ID", "default"), []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(k.ID, k)
+	}
+	return nil, nil
+}
+
+// WatchKeyFile reloads path whenever its modification time changes, so keys
+// can be rotated without a restart. A file that fails to load is logged and
+// the current keys kept.
+func WatchKeyFile(ctx context.Context, path string, interval time.Duration) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+		ks, err := LoadKeyFile(path)
+		if err != nil {
+			log.Printf("auth: keeping current keys: %v", err)
+			continue
+		}
+		SetKeys(ks)
+		log.Printf("auth: reloaded signing keys, active kid %q", ks.active)
+	}
+}
+
+// AccessTokenTTL is how long access tokens are valid. They are kept short
+// because, once issued, only the denylist can take them back.
+var AccessTokenTTL = 15 * time.Minute
 
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID int    `json:"user_id"`
+	Role   string `json:"role,omitempty"`
+	// SessionID names the refresh token family the token was issued under,
+	// so revoking a session also rejects its outstanding access tokens.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenIDs returns the identifiers a denylist may hold for the token: its
+// jti and, if issued for a session, the session ID.
+func (c *Claims) TokenIDs() []string {
+	ids := []string{c.ID}
+	if c.SessionID != "" {
+		ids = append(ids, c.SessionID)
+	}
+	return ids
+}
+
 func GenerateToken(userID int) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+	token, _, err := IssueAccessToken(userID, RoleUser, "")
+	return token, err
+}
+
+// IssueAccessToken signs an AccessTokenTTL token with a fresh jti for
+// userID's session.
+func IssueAccessToken(userID int, role, sessionID string) (string, *Claims, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 		},
 	}
+	token, err := Keys().Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+// NewTokenID returns a random URL-safe identifier for jti and session IDs.
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Sign signs claims with the active key and stamps its kid in the header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	k := ks.Active()
+	token := jwt.NewWithClaims(k.method(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.signingKey())
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
+	if err := Keys().Parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
+// Parse verifies tokenString with the key named by its kid header and
+// decodes it into claims. The token's alg must match the key's, so an RS256
+// public key can never be used as an HMAC secret.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims) error {
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		k, err := ks.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != k.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return k.verifyKey(), nil
+	}, jwt.WithValidMethods([]string{HS256, RS256, EdDSA}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrSignatureInvalid) {
-			return nil, errors.New("invalid signature")
+			return errors.New("invalid signature")
 		}
-		return nil, err
+		return err
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return errors.New("invalid token")
 	}
+	return nil
+}
 
+// Denylist reports whether any of a token's IDs has been revoked.
+type Denylist interface {
+	IsRevoked(ctx context.Context, ids []string) (bool, error)
+}
+
+var (
+	ErrRevoked             = errors.New("token revoked")
+	ErrDenylistUnavailable = errors.New("revocation check failed")
+)
+
+var denylist atomic.Pointer[Denylist]
+
+// SetDenylist makes Authenticate reject tokens revoked in d.
+func SetDenylist(d Denylist) {
+	denylist.Store(&d)
+}
+
+// Authenticate validates tokenString and checks it against the denylist.
+// A denylist that can't be reached fails closed.
+func Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if d := denylist.Load(); d != nil && *d != nil {
+		revoked, err := (*d).IsRevoked(ctx, claims.TokenIDs())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDenylistUnavailable, err)
+		}
+		if revoked {
+			return nil, ErrRevoked
+		}
+	}
 	return claims, nil
 }
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, matching the
+// SHA-256 block of entropy RFC 7518 asks for.
+const minSecretLength = 32
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing or verification key, identified in tokens by the kid
+// header. Keys without private material (public-only RSA or Ed25519 keys)
+// verify tokens but can't be made active.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *Key) signingKey() interface{} {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k *Key) verifyKey() interface{} {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.public
+}
+
+func (k *Key) canSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+// KeySet holds every key tokens may be verified with and names the one new
+// tokens are signed with. To rotate, add the new key, make it active, and
+// drop the old one once tokens it signed have expired.
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+}
+
+// NewKeySet builds a key set that signs with the key whose ID is active.
+func NewKeySet(active string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{active: active, keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New("key without a kid")
+		}
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate kid %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+	k, ok := ks.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("%w: active kid %q", ErrUnknownKey, active)
+	}
+	if !k.canSign() {
+		return nil, fmt.Errorf("active key %q has no private key", active)
+	}
+	return ks, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() *Key {
+	return ks.keys[ks.active]
+}
+
+// Lookup returns the key with the given kid.
+func (ks *KeySet) Lookup(kid string) (*Key, error) {
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return k, nil
+}
+
+// NewHMACKey returns an HS256 key. secret must be at least 32 bytes.
+func NewHMACKey(kid string, secret []byte) (*Key, error) {
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("key %q: HS256 secret must be at least %d bytes", kid, minSecretLength)
+	}
+	return &Key{ID: kid, Algorithm: HS256, secret: secret}, nil
+}
+
+// GenerateHMACKey returns an HS256 key with a random secret.
+func GenerateHMACKey(kid string) (*Key, error) {
+	secret := make([]byte, minSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewHMACKey(kid, secret)
+}
+
+// NewSignerKey wraps an RSA or Ed25519 private key.
+func NewSignerKey(kid string, private crypto.Signer) (*Key, error) {
+	k := &Key{ID: kid, private: private, public: private.Public()}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		k.Algorithm = RS256
+	case ed25519.PrivateKey:
+		k.Algorithm = EdDSA
+	default:
+		return nil, fmt.Errorf("key %q: unsupported private key type %T", kid, private)
+	}
+	return k, nil
+}
+
+// NewPublicKey wraps an RSA or Ed25519 public key for verification only.
+func NewPublicKey(kid string, public crypto.PublicKey) (*Key, error) {
+	k := &Key{ID: kid, public: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		k.Algorithm = RS256
+	case ed25519.PublicKey:
+		k.Algorithm = EdDSA
+	default:
+		return nil, fmt.Errorf("key %q: unsupported public key type %T", kid, public)
+	}
+	return k, nil
+}
+
+// keyFile is the JSON layout of JWT_KEYS_FILE. PEM material may be inline
+// or in a file; relative paths are resolved against the key file.
+//
+//	{
+//	  "active": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-10", "alg": "EdDSA", "private_key_file": "ed25519.pem"},
+//	    {"kid": "2026-07", "alg": "RS256", "public_key_file": "rsa.pub.pem"},
+//	    {"kid": "legacy", "alg": "HS256", "secret": "..."}
+//	  ]
+//	}
+type keyFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID             string `json:"kid"`
+		Algorithm      string `json:"alg"`
+		Secret         string `json:"secret"`
+		PrivateKey     string `json:"private_key"`
+		PrivateKeyFile string `json:"private_key_file"`
+		PublicKey      string `json:"public_key"`
+		PublicKeyFile  string `json:"public_key_file"`
+	} `json:"keys"`
+}
+
+// LoadKeyFile reads a key set from a JSON key file.
+func LoadKeyFile(path string) (*KeySet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	var f keyFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	pemData := func(inline, file string) ([]byte, error) {
+		if inline != "" || file == "" {
+			return []byte(inline), nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return os.ReadFile(file)
+	}
+
+	keys := make([]*Key, 0, len(f.Keys))
+	for _, spec := range f.Keys {
+		var k *Key
+		switch {
+		case spec.Algorithm == HS256:
+			k, err = NewHMACKey(spec.ID, []byte(spec.Secret))
+		case spec.PrivateKey != "" || spec.PrivateKeyFile != "":
+			var data []byte
+			if data, err = pemData(spec.PrivateKey, spec.PrivateKeyFile); err == nil {
+				k, err = parsePrivateKey(spec.ID, data)
+			}
+		default:
+			var data []byte
+			if data, err = pemData(spec.PublicKey, spec.PublicKeyFile); err == nil {
+				k, err = parsePublicKey(spec.ID, data)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", spec.ID, err)
+		}
+		if spec.Algorithm != "" && spec.Algorithm != k.Algorithm {
+			return nil, fmt.Errorf("key %q: alg %s does not match a %s key", spec.ID, spec.Algorithm, k.Algorithm)
+		}
+		keys = append(keys, k)
+	}
+	return NewKeySet(f.Active, keys...)
+}
+
+func parsePrivateKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewSignerKey(kid, rsaKey)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	return NewSignerKey(kid, signer)
+}
+
+func parsePublicKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return NewPublicKey(kid, rsaKey)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return NewPublicKey(kid, parsed)
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// PublicKey decodes an RSA, P-256 or Ed25519 JWK, as published by identity
+// providers.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch j.KeyType {
+	case "RSA":
+		n, err := dec(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: bad modulus: %w", j.ID, err)
+		}
+		e, err := dec(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwk %s: bad exponent", j.ID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Curve != "P-256" {
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", j.ID, j.Curve)
+		}
+		x, errX := dec(j.X)
+		y, errY := dec(j.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("jwk %s: bad coordinates", j.ID)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("jwk %s: point not on curve", j.ID)
+		}
+		return pub, nil
+	case "OKP":
+		x, err := dec(j.X)
+		if j.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: unsupported OKP key", j.ID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("jwk %s: unsupported key type %q", j.ID, j.KeyType)
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the set's asymmetric public keys. HS256 secrets are never
+// published; services verifying those tokens need the shared secret.
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		enc := base64.RawURLEncoding.EncodeToString
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			doc.Keys = append(doc.Keys, JWK{KeyType: "RSA", ID: k.ID, Algorithm: RS256, Use: "sig",
+				N: enc(pub.N.Bytes()), E: enc(big.NewInt(int64(pub.E)).Bytes())})
+		case ed25519.PublicKey:
+			doc.Keys = append(doc.Keys, JWK{KeyType: "OKP", ID: k.ID, Algorithm: EdDSA, Use: "sig",
+				Curve: "Ed25519", X: enc(pub)})
+		}
+	}
+	sort.Slice(doc.Keys, func(i, j int) bool { return doc.Keys[i].ID < doc.Keys[j].ID })
+	return doc
+}
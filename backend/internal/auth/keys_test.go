@@ -0,0 +1,238 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+func claimsFor(userID int) *auth.Claims {
+	return &auth.Claims{UserID: userID, RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+}
+
+// mustKey returns a helper that fails t if a key constructor errors.
+func mustKey(t *testing.T) func(*auth.Key, error) *auth.Key {
+	return func(k *auth.Key, err error) *auth.Key {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+}
+
+func TestSignAndParseEachAlgorithm(t *testing.T) {
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	for _, k := range []*auth.Key{
+		mustKey(t)(auth.GenerateHMACKey("hs")),
+		mustKey(t)(auth.NewSignerKey("rs", rsaPriv)),
+		mustKey(t)(auth.NewSignerKey("ed", edPriv)),
+	} {
+		ks, err := auth.NewKeySet(k.ID, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := ks.Sign(claimsFor(42))
+		if err != nil {
+			t.Fatalf("%s: %v", k.Algorithm, err)
+		}
+		var got auth.Claims
+		if err := ks.Parse(token, &got); err != nil {
+			t.Fatalf("%s: %v", k.Algorithm, err)
+		}
+		if got.UserID != 42 {
+			t.Errorf("%s: expected user 42, got %d", k.Algorithm, got.UserID)
+		}
+	}
+}
+
+func TestRotationKeepsOldTokensValid(t *testing.T) {
+	old := mustKey(t)(auth.GenerateHMACKey("2026-07"))
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	next := mustKey(t)(auth.NewSignerKey("2026-10", edPriv))
+
+	before, _ := auth.NewKeySet(old.ID, old)
+	token, _ := before.Sign(claimsFor(1))
+
+	during, _ := auth.NewKeySet(next.ID, next, old)
+	if err := during.Parse(token, &auth.Claims{}); err != nil {
+		t.Errorf("token signed by the previous key should still verify: %v", err)
+	}
+	fresh, _ := during.Sign(claimsFor(1))
+	if err := during.Parse(fresh, &auth.Claims{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.Parse(fresh, &auth.Claims{}); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("expected the old set not to know the new kid, got %v", err)
+	}
+
+	after, _ := auth.NewKeySet(next.ID, next)
+	if err := after.Parse(token, &auth.Claims{}); err == nil {
+		t.Error("token signed by a retired key should be rejected")
+	}
+}
+
+func TestParseRejectsAlgorithmConfusion(t *testing.T) {
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k := mustKey(t)(auth.NewSignerKey("rs", rsaPriv))
+	ks, _ := auth.NewKeySet(k.ID, k)
+
+	// An attacker signs an HS256 token using the published public key as
+	// the HMAC secret.
+	pub, _ := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsFor(1))
+	forged.Header["kid"] = "rs"
+	token, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+
+	if err := ks.Parse(token, &auth.Claims{}); err == nil {
+		t.Error("HS256 token for an RS256 key should be rejected")
+	}
+}
+
+func TestJWKSPublishesOnlyPublicKeys(t *testing.T) {
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ks, err := auth.NewKeySet("ed",
+		mustKey(t)(auth.NewSignerKey("ed", edPriv)),
+		mustKey(t)(auth.NewPublicKey("rs", &rsaPriv.PublicKey)),
+		mustKey(t)(auth.GenerateHMACKey("hs")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := ks.JWKS()
+	if len(doc.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %+v", doc.Keys)
+	}
+	if k := doc.Keys[0]; k.ID != "ed" || k.KeyType != "OKP" || k.Curve != "Ed25519" || k.X == "" {
+		t.Errorf("unexpected Ed25519 JWK %+v", k)
+	}
+	if k := doc.Keys[1]; k.ID != "rs" || k.KeyType != "RSA" || k.Algorithm != "RS256" || k.E != "AQAB" {
+		t.Errorf("unexpected RSA JWK %+v", k)
+	}
+}
+
+func TestJWKPublicKeyRoundTrip(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ks, err := auth.NewKeySet("ed",
+		mustKey(t)(auth.NewSignerKey("ed", edPriv)),
+		mustKey(t)(auth.NewPublicKey("rsa", &rsaPriv.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := ks.JWKS()
+	for i, want := range []crypto.PublicKey{edPub, &rsaPriv.PublicKey} {
+		got, err := doc.Keys[i].PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey(%s): %v", doc.Keys[i].ID, err)
+		}
+		if !want.(interface{ Equal(crypto.PublicKey) bool }).Equal(got) {
+			t.Errorf("%s round trip mismatch", doc.Keys[i].ID)
+		}
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	ec := auth.JWK{KeyType: "EC", ID: "ec", Curve: "P-256", X: enc(ecPriv.X.Bytes()), Y: enc(ecPriv.Y.Bytes())}
+	if got, err := ec.PublicKey(); err != nil || !ecPriv.PublicKey.Equal(got) {
+		t.Errorf("EC PublicKey = %v, %v", got, err)
+	}
+	ec.Y = enc([]byte{1})
+	if _, err := ec.PublicKey(); err == nil {
+		t.Error("accepted a point off the curve")
+	}
+	if _, err := (auth.JWK{KeyType: "oct", ID: "hmac"}).PublicKey(); err == nil {
+		t.Error("accepted a symmetric key")
+	}
+}
+
+func TestNewKeySetValidates(t *testing.T) {
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	public := mustKey(t)(auth.NewPublicKey("pub", &rsaPriv.PublicKey))
+	if _, err := auth.NewKeySet("pub", public); err == nil {
+		t.Error("a public-only key should not be allowed to sign")
+	}
+	if _, err := auth.NewKeySet("missing", public); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if _, err := auth.NewHMACKey("short", []byte("super_secret_key")); err == nil {
+		t.Error("expected a short HS256 secret to be rejected")
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edPriv)
+	os.WriteFile(filepath.Join(dir, "ed25519.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPriv)})
+
+	path := filepath.Join(dir, "keys.json")
+	os.WriteFile(path, []byte(`{
+		"active": "new",
+		"keys": [
+			{"kid": "new", "alg": "EdDSA", "private_key_file": "ed25519.pem"},
+			{"kid": "rsa", "alg": "RS256", "private_key": `+strings.ReplaceAll(`"`+string(rsaPEM)+`"`, "\n", `\n`)+`},
+			{"kid": "old", "alg": "HS256", "secret": "0123456789abcdef0123456789abcdef"}
+		]
+	}`), 0o600)
+
+	ks, err := auth.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := ks.Active(); k.ID != "new" || k.Algorithm != auth.EdDSA {
+		t.Errorf("unexpected active key %s/%s", k.ID, k.Algorithm)
+	}
+	if k, err := ks.Lookup("rsa"); err != nil || k.Algorithm != auth.RS256 {
+		t.Errorf("expected an RS256 key, got %v, %v", k, err)
+	}
+
+	os.WriteFile(path, []byte(`{"active": "x", "keys": [{"kid": "x", "alg": "RS256", "private_key_file": "ed25519.pem"}]}`), 0o600)
+	if _, err := auth.LoadKeyFile(path); err == nil {
+		t.Error("expected an alg mismatch to be rejected")
+	}
+}
+
+func TestGenerateTokenUsesConfiguredKeys(t *testing.T) {
+	defer auth.SetKeys(auth.Keys())
+	k := mustKey(t)(auth.NewHMACKey("configured", []byte("0123456789abcdef0123456789abcdef")))
+	ks, _ := auth.NewKeySet(k.ID, k)
+	auth.SetKeys(ks)
+
+	token, err := auth.GenerateToken(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if parsed.Header["kid"] != "configured" {
+		t.Errorf("expected kid header, got %v", parsed.Header)
+	}
+	claims, err := auth.ValidateToken(token)
+	if err != nil || claims.UserID != 9 {
+		t.Errorf("expected user 9, got %v, %v", claims, err)
+	}
+}
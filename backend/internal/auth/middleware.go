@@ -1,14 +1,22 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware authenticates a bearer JWT or, on routes listed in scopes,
+// an X-API-Key carrying the route's scope.
+func AuthMiddleware(scopes ScopePolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, key, scopes)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header is required"})
@@ -23,7 +31,12 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := ValidateToken(bearerToken[1])
+		claims, err := Authenticate(c.Request.Context(), bearerToken[1])
+		if errors.Is(err, ErrDenylistUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify token"})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
@@ -31,6 +44,21 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// OptionalAPIKey is for public routes scripts also call. Anonymous
+// requests pass through, but an X-API-Key is checked as AuthMiddleware
+// would, so a key only works on routes its scopes cover.
+func OptionalAPIKey(scopes ScopePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, key, scopes)
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles, from least to most privileged. Each role has every permission of
+// the roles before it.
+const (
+	RoleUser    = "user"
+	RoleAnalyst = "analyst"
+	RoleAdmin   = "admin"
+)
+
+var roleRank = map[string]int{RoleUser: 1, RoleAnalyst: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is one of the defined roles.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether a holder of role may act as need. An empty role,
+// as carried by tokens issued before roles existed, counts as RoleUser.
+func HasRole(role, need string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+	return roleRank[role] >= roleRank[need]
+}
+
+// RequireRole rejects requests whose token doesn't carry at least role. It
+// must run after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c.GetString("role"), role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+func TestHasRole(t *testing.T) {
+	cases := []struct {
+		role, need string
+		want       bool
+	}{
+		{auth.RoleAdmin, auth.RoleAdmin, true},
+		{auth.RoleAdmin, auth.RoleAnalyst, true},
+		{auth.RoleAnalyst, auth.RoleAdmin, false},
+		{auth.RoleUser, auth.RoleAnalyst, false},
+		{"", auth.RoleUser, true},
+		{"", auth.RoleAnalyst, false},
+		{"root", auth.RoleUser, false},
+	}
+	for _, c := range cases {
+		if got := auth.HasRole(c.role, c.need); got != c.want {
+			t.Errorf("HasRole(%q, %q) = %v, want %v", c.role, c.need, got, c.want)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", auth.AuthMiddleware(nil), auth.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for role, want := range map[string]int{auth.RoleAdmin: http.StatusOK, auth.RoleAnalyst: http.StatusForbidden} {
+		token, _, err := auth.IssueAccessToken(1, role, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", role, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API key scopes.
+const (
+	ScopeReadQuotes     = "read:quotes"
+	ScopeReadPortfolio  = "read:portfolio"
+	ScopeWritePortfolio = "write:portfolio"
+)
+
+// Scopes lists every scope an API key may be granted.
+func Scopes() []string {
+	return []string{ScopeReadQuotes, ScopeReadPortfolio, ScopeWritePortfolio}
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes(), scope)
+}
+
+// APIKeyHeader carries an API key in place of a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyPrincipal is who an API key acts for and what it may do. API keys
+// never carry more than RoleUser.
+type APIKeyPrincipal struct {
+	KeyID  int
+	UserID int
+	Scopes []string
+}
+
+func (p *APIKeyPrincipal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// APIKeyVerifier resolves a presented API key.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error)
+}
+
+var apiKeys atomic.Pointer[APIKeyVerifier]
+
+// SetAPIKeyVerifier enables X-API-Key authentication.
+func SetAPIKeyVerifier(v APIKeyVerifier) {
+	apiKeys.Store(&v)
+}
+
+// AuthenticateAPIKey resolves key with the configured verifier.
+func AuthenticateAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error) {
+	v := apiKeys.Load()
+	if v == nil || *v == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return (*v).VerifyAPIKey(ctx, key)
+}
+
+// ScopePolicy names the scope an API key needs for each route, keyed by
+// method and gin route pattern, e.g. "GET /api/portfolio". Routes missing
+// from the policy accept JWTs only.
+type ScopePolicy map[string]string
+
+// required returns the scope c's route needs, if API keys may use it.
+func (p ScopePolicy) required(c *gin.Context) (string, bool) {
+	scope, ok := p[c.Request.Method+" "+c.FullPath()]
+	return scope, ok
+}
+
+// authenticateAPIKey handles an X-API-Key request for AuthMiddleware.
+func authenticateAPIKey(c *gin.Context, key string, policy ScopePolicy) {
+	scope, ok := policy.required(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this endpoint"})
+		c.Abort()
+		return
+	}
+	principal, err := AuthenticateAPIKey(c.Request.Context(), key)
+	if errors.Is(err, ErrInvalidAPIKey) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify API key"})
+		c.Abort()
+		return
+	}
+	if !principal.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", principal.UserID)
+	c.Set("apiKey", principal)
+	c.Next()
+}
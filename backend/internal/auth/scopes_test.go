@@ -0,0 +1,82 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+type staticKeys map[string]*auth.APIKeyPrincipal
+
+func (s staticKeys) VerifyAPIKey(ctx context.Context, key string) (*auth.APIKeyPrincipal, error) {
+	if p, ok := s[key]; ok {
+		return p, nil
+	}
+	return nil, auth.ErrInvalidAPIKey
+}
+
+func TestAuthMiddlewareEnforcesScopes(t *testing.T) {
+	auth.SetAPIKeyVerifier(staticKeys{
+		"gsk_reader": {UserID: 4, Scopes: []string{auth.ScopeReadPortfolio}},
+	})
+	t.Cleanup(func() { auth.SetAPIKeyVerifier(nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	protected := router.Group("/api", auth.AuthMiddleware(auth.ScopePolicy{
+		"GET /api/portfolio":  auth.ScopeReadPortfolio,
+		"POST /api/portfolio": auth.ScopeWritePortfolio,
+	}))
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"user": c.GetInt("userID")}) }
+	protected.GET("/portfolio", ok)
+	protected.POST("/portfolio", ok)
+	protected.GET("/alerts", ok)
+
+	cases := []struct {
+		method, path, key string
+		want              int
+	}{
+		{http.MethodGet, "/api/portfolio", "gsk_reader", http.StatusOK},
+		{http.MethodPost, "/api/portfolio", "gsk_reader", http.StatusForbidden},
+		{http.MethodGet, "/api/alerts", "gsk_reader", http.StatusForbidden},
+		{http.MethodGet, "/api/portfolio", "gsk_unknown", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set(auth.APIKeyHeader, c.key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s %s with %s: expected %d, got %d", c.method, c.path, c.key, c.want, rec.Code)
+		}
+	}
+
+	// Public routes take keys with the right scope, or none at all
+	public := router.Group("/public", auth.OptionalAPIKey(auth.ScopePolicy{
+		"GET /public/quote": auth.ScopeReadQuotes,
+	}))
+	public.GET("/quote", ok)
+	for key, want := range map[string]int{"": http.StatusOK, "gsk_reader": http.StatusForbidden, "gsk_unknown": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/public/quote", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET /public/quote with %q: expected %d, got %d", key, want, rec.Code)
+		}
+	}
+
+	// Bearer tokens are unaffected by the policy
+	token, _ := auth.GenerateToken(4)
+	req := httptest.NewRequest(http.MethodGet, "/api/alerts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected a JWT to reach /api/alerts, got %d", rec.Code)
+	}
+}
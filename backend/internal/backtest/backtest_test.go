@@ -0,0 +1,225 @@
+package backtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/backtest"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+func bars(prices ...float64) []stocks.Candle {
+	start := time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+	out := make([]stocks.Candle, len(prices))
+	for i, p := range prices {
+		out[i] = stocks.Candle{Time: start.AddDate(0, 0, i), Open: p, High: p, Low: p, Close: p}
+	}
+	return out
+}
+
+// scripted buys qty after bar buyAt and sells everything after bar sellAt.
+type scripted struct {
+	buyAt, sellAt int
+	qty           float64
+}
+
+func (s *scripted) Name() string { return "scripted" }
+func (s *scripted) OnBar(b *backtest.Broker) {
+	switch len(b.Bars()) - 1 {
+	case s.buyAt:
+		b.Buy(s.qty)
+	case s.sellAt:
+		b.Sell(b.Position())
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestFillsApplySlippageAndCommission(t *testing.T) {
+	cfg := backtest.Config{InitialCash: 1000, SlippageBps: 100, Commission: 1, CommissionPct: 0.001}
+	res, err := backtest.Run(&scripted{buyAt: 0, sellAt: 2, qty: 5}, bars(100, 100, 120, 110, 110), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Fills) != 2 {
+		t.Fatalf("expected 2 fills, got %+v", res.Fills)
+	}
+	buy, sell := res.Fills[0], res.Fills[1]
+	if !near(buy.Price, 101) || !near(buy.Commission, 1+0.001*5*101) {
+		t.Errorf("buy filled at next open plus 1%%: %+v", buy)
+	}
+	if !near(sell.Price, 110*0.99) || !buy.Time.Before(sell.Time) {
+		t.Errorf("sell filled at next open less 1%%: %+v", sell)
+	}
+
+	cash := 1000 - 5*101 - buy.Commission + 5*108.9 - sell.Commission
+	if got := res.Stats.FinalEquity; !near(got, cash) {
+		t.Errorf("final equity: got %.4f, want %.4f", got, cash)
+	}
+	if len(res.Trades) != 1 {
+		t.Fatalf("expected one round trip, got %+v", res.Trades)
+	}
+	if tr := res.Trades[0]; !near(tr.PnL, cash-1000) || !near(tr.EntryPrice, 101) {
+		t.Errorf("unexpected trade %+v", tr)
+	}
+	// Marked at 120 on bar 2 while holding 5 shares.
+	if eq := res.Equity[2]; eq.Position != 5 || !near(eq.Equity, eq.Cash+600) {
+		t.Errorf("unexpected equity point %+v", eq)
+	}
+}
+
+func TestBuysAreCappedByCash(t *testing.T) {
+	res, err := backtest.Run(&scripted{buyAt: 0, sellAt: -1, qty: 1000}, bars(10, 10, 10), backtest.Config{InitialCash: 105, Commission: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Fills[0].Quantity != 10 || res.Equity[2].Cash != 0 {
+		t.Errorf("expected 10 shares with nothing left over, got %+v", res.Equity[2])
+	}
+}
+
+func TestMACrossoverTradesTheTrend(t *testing.T) {
+	prices := []float64{}
+	for i := 0; i < 20; i++ {
+		prices = append(prices, 100-float64(i)) // falling
+	}
+	for i := 0; i < 20; i++ {
+		prices = append(prices, 80+3*float64(i)) // rising
+	}
+	for i := 0; i < 20; i++ {
+		prices = append(prices, 140-3*float64(i)) // falling again
+	}
+
+	s, err := backtest.NewStrategy("ma_crossover", map[string]float64{"fast": 3, "slow": 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := backtest.Run(s, bars(prices...), backtest.Config{InitialCash: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trades) != 1 || res.Trades[0].PnL <= 0 {
+		t.Errorf("expected one profitable trade, got %+v", res.Trades)
+	}
+	if res.Stats.Exposure <= 0 || res.Stats.Exposure >= 1 {
+		t.Errorf("unexpected exposure %.2f", res.Stats.Exposure)
+	}
+}
+
+func TestNewStrategyValidates(t *testing.T) {
+	if _, err := backtest.NewStrategy("ma_crossover", map[string]float64{"fast": 50, "slow": 20}); !errors.Is(err, backtest.ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest, got %v", err)
+	}
+	if _, err := backtest.NewStrategy("rsi_reversion", map[string]float64{"window": 3}); !errors.Is(err, backtest.ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest for unknown param, got %v", err)
+	}
+	if _, err := backtest.NewStrategy("buy_the_dip", nil); !errors.Is(err, backtest.ErrUnknownStrategy) {
+		t.Errorf("expected ErrUnknownStrategy, got %v", err)
+	}
+}
+
+// memRepo is an in-memory backtest.Repository.
+type memRepo struct {
+	jobs   []backtest.Job
+	claims int
+}
+
+func (m *memRepo) CreateJob(ctx context.Context, job *backtest.Job) error {
+	job.ID = len(m.jobs) + 1
+	m.jobs = append(m.jobs, *job)
+	return nil
+}
+func (m *memRepo) GetJob(ctx context.Context, userID, jobID int) (*backtest.Job, error) {
+	if jobID < 1 || jobID > len(m.jobs) || m.jobs[jobID-1].UserID != userID {
+		return nil, backtest.ErrJobNotFound
+	}
+	job := m.jobs[jobID-1]
+	return &job, nil
+}
+func (m *memRepo) ListJobs(ctx context.Context, userID, limit int) ([]backtest.Job, error) {
+	return m.jobs, nil
+}
+func (m *memRepo) CountPending(ctx context.Context, userID int) (int, error) {
+	n := 0
+	for _, job := range m.jobs {
+		if job.UserID == userID && (job.Status == backtest.StatusQueued || job.Status == backtest.StatusRunning) {
+			n++
+		}
+	}
+	return n, nil
+}
+func (m *memRepo) ClaimJob(ctx context.Context, lease time.Duration) (*backtest.Job, error) {
+	for i := range m.jobs {
+		if m.jobs[i].Status == backtest.StatusQueued {
+			m.claims++
+			m.jobs[i].Status = backtest.StatusRunning
+			m.jobs[i].ClaimToken = fmt.Sprint(m.claims)
+			job := m.jobs[i]
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+func (m *memRepo) FinishJob(ctx context.Context, job *backtest.Job) error {
+	stored := &m.jobs[job.ID-1]
+	if stored.Status != backtest.StatusRunning || stored.ClaimToken != job.ClaimToken {
+		return backtest.ErrLeaseLost
+	}
+	*stored = *job
+	return nil
+}
+
+func TestRunnerCompletesQueuedJobs(t *testing.T) {
+	repo := &memRepo{}
+	svc := backtest.NewService(repo)
+	ctx := context.Background()
+
+	job, err := svc.Submit(ctx, 7, backtest.Request{Symbol: "aapl", Strategy: "rsi_reversion", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != backtest.StatusQueued || job.Request.Symbol != "AAPL" || job.Request.Config.InitialCash != 10000 {
+		t.Errorf("expected a normalised queued job, got %+v", job)
+	}
+	if _, err := svc.Submit(ctx, 7, backtest.Request{Strategy: "rsi_reversion"}); !errors.Is(err, backtest.ErrInvalidRequest) {
+		t.Errorf("expected missing symbol to be rejected, got %v", err)
+	}
+
+	runner := backtest.NewRunner(repo, stocks.NewMock())
+	if !runner.RunOnce(ctx) || runner.RunOnce(ctx) {
+		t.Fatal("expected exactly one job to run")
+	}
+	done, err := svc.Get(ctx, 7, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Status != backtest.StatusDone || done.Result == nil || len(done.Result.Equity) != 100 {
+		t.Errorf("expected a finished job with 100 equity points, got status %s err %q", done.Status, done.Error)
+	}
+	if _, err := svc.Get(ctx, 8, job.ID); !errors.Is(err, backtest.ErrJobNotFound) {
+		t.Errorf("other users must not see the job, got %v", err)
+	}
+}
+
+func TestSubmitCapsPendingJobs(t *testing.T) {
+	svc := backtest.NewService(&memRepo{})
+	ctx := context.Background()
+	req := backtest.Request{Symbol: "AAPL", Strategy: "rsi_reversion"}
+
+	for i := 0; i < 5; i++ {
+		if _, err := svc.Submit(ctx, 7, req); err != nil {
+			t.Fatalf("job %d: %v", i+1, err)
+		}
+	}
+	if _, err := svc.Submit(ctx, 7, req); !errors.Is(err, backtest.ErrInvalidRequest) {
+		t.Errorf("expected the pending cap to apply, got %v", err)
+	}
+	if _, err := svc.Submit(ctx, 8, req); err != nil {
+		t.Errorf("other users are not capped: %v", err)
+	}
+}
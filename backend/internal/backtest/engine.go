@@ -0,0 +1,224 @@
+// Package backtest replays candle history through trading strategies.
+//
+// Strategies see one bar at a time and place market orders, which fill at
+// the next bar's open after slippage and commission. The engine trades a
+// single symbol, long only.
+package backtest
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+var ErrNoData = errors.New("no candles to backtest")
+
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Config holds account and cost assumptions.
+type Config struct {
+	InitialCash float64 `json:"initial_cash"`
+	// SlippageBps moves every fill against the trader, in basis points.
+	SlippageBps float64 `json:"slippage_bps"`
+	// Commission is charged per fill: a fixed amount plus a fraction of
+	// the notional.
+	Commission    float64 `json:"commission"`
+	CommissionPct float64 `json:"commission_pct"`
+}
+
+// DefaultConfig is 10,000 in cash, 5 bps slippage and $1 per fill.
+func DefaultConfig() Config {
+	return Config{InitialCash: 10000, SlippageBps: 5, Commission: 1}
+}
+
+// Fill is an executed order.
+type Fill struct {
+	Time       time.Time `json:"time"`
+	Side       Side      `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	Commission float64   `json:"commission"`
+}
+
+// Trade is a round trip from flat to flat.
+type Trade struct {
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	Quantity   float64   `json:"quantity"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	PnL        float64   `json:"pnl"`
+	Return     float64   `json:"return"`
+}
+
+// EquityPoint is the account value at a bar's close.
+type EquityPoint struct {
+	Time     time.Time `json:"time"`
+	Equity   float64   `json:"equity"`
+	Cash     float64   `json:"cash"`
+	Position float64   `json:"position"`
+}
+
+// Result is everything a run produced.
+type Result struct {
+	Strategy string        `json:"strategy"`
+	Config   Config        `json:"config"`
+	Equity   []EquityPoint `json:"equity"`
+	Fills    []Fill        `json:"fills"`
+	Trades   []Trade       `json:"trades"`
+	Stats    Stats         `json:"stats"`
+}
+
+// Broker is what a strategy sees on each bar.
+type Broker struct {
+	bars     []stocks.Candle
+	cash     float64
+	position float64
+	pending  []order
+}
+
+type order struct {
+	side     Side
+	quantity float64
+}
+
+// Bars returns the history up to and including the current bar.
+func (b *Broker) Bars() []stocks.Candle { return b.bars }
+
+// Closes returns the closing prices of Bars.
+func (b *Broker) Closes() []float64 {
+	out := make([]float64, len(b.bars))
+	for i, c := range b.bars {
+		out[i] = c.Close
+	}
+	return out
+}
+
+func (b *Broker) Cash() float64     { return b.cash }
+func (b *Broker) Position() float64 { return b.position }
+
+// Equity is cash plus the position marked at the current close.
+func (b *Broker) Equity() float64 {
+	return b.cash + b.position*b.bars[len(b.bars)-1].Close
+}
+
+// Buy queues a market buy for the next bar's open. Quantities that cannot
+// be afforded at the fill are reduced.
+func (b *Broker) Buy(quantity float64) {
+	if quantity > 0 {
+		b.pending = append(b.pending, order{Buy, quantity})
+	}
+}
+
+// Sell queues a market sell for the next bar's open, up to the position.
+func (b *Broker) Sell(quantity float64) {
+	if quantity > 0 {
+		b.pending = append(b.pending, order{Sell, quantity})
+	}
+}
+
+// Strategy decides what to trade after each bar closes.
+type Strategy interface {
+	Name() string
+	OnBar(b *Broker)
+}
+
+// Run replays candles, which must be in ascending time order.
+func Run(s Strategy, candles []stocks.Candle, cfg Config) (*Result, error) {
+	if len(candles) == 0 {
+		return nil, ErrNoData
+	}
+	b := &Broker{cash: cfg.InitialCash}
+	res := &Result{Strategy: s.Name(), Config: cfg}
+
+	// The open round trip: it starts on the first buy from flat and closes
+	// when the position is flat again.
+	var (
+		open                     *Trade
+		entryNotional, exitValue float64
+		fees                     float64
+	)
+
+	for i, bar := range candles {
+		b.bars = candles[:i+1]
+
+		for _, o := range b.pending {
+			fill, ok := execute(b, o, bar, cfg)
+			if !ok {
+				continue
+			}
+			res.Fills = append(res.Fills, fill)
+			fees += fill.Commission
+
+			if fill.Side == Buy {
+				if open == nil {
+					open = &Trade{EntryTime: fill.Time}
+					entryNotional, exitValue, fees = 0, 0, fill.Commission
+				}
+				entryNotional += fill.Quantity * fill.Price
+				open.Quantity += fill.Quantity
+				continue
+			}
+			exitValue += fill.Quantity * fill.Price
+			if open != nil && b.position == 0 {
+				open.ExitTime = fill.Time
+				open.EntryPrice = entryNotional / open.Quantity
+				open.ExitPrice = exitValue / open.Quantity
+				open.PnL = exitValue - entryNotional - fees
+				open.Return = open.PnL / entryNotional
+				res.Trades = append(res.Trades, *open)
+				open = nil
+			}
+		}
+		b.pending = b.pending[:0]
+
+		s.OnBar(b)
+		res.Equity = append(res.Equity, EquityPoint{Time: bar.Time, Equity: b.Equity(), Cash: b.cash, Position: b.position})
+	}
+
+	res.Stats = computeStats(res, cfg.InitialCash)
+	if first := candles[0].Open; first > 0 {
+		res.Stats.BuyAndHold = candles[len(candles)-1].Close/first - 1
+	}
+	return res, nil
+}
+
+// execute fills o at bar's open, adjusting b. It reports false if nothing
+// could be filled.
+func execute(b *Broker, o order, bar stocks.Candle, cfg Config) (Fill, bool) {
+	slip := cfg.SlippageBps / 10000
+	fill := Fill{Time: bar.Time, Side: o.side}
+	qty := o.quantity
+
+	if o.side == Buy {
+		fill.Price = bar.Open * (1 + slip)
+		perShare := fill.Price * (1 + cfg.CommissionPct)
+		if affordable := math.Floor((b.cash - cfg.Commission) / perShare); qty > affordable {
+			qty = affordable
+		}
+	} else {
+		fill.Price = bar.Open * (1 - slip)
+		qty = math.Min(qty, b.position)
+	}
+	if qty <= 0 || fill.Price <= 0 {
+		return Fill{}, false
+	}
+
+	fill.Quantity = qty
+	fill.Commission = cfg.Commission + cfg.CommissionPct*qty*fill.Price
+	if o.side == Buy {
+		b.cash -= qty*fill.Price + fill.Commission
+		b.position += qty
+	} else {
+		b.cash += qty*fill.Price - fill.Commission
+		b.position -= qty
+	}
+	return fill, true
+}
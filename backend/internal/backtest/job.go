@@ -0,0 +1,207 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid backtest request")
+	ErrJobNotFound    = errors.New("backtest not found")
+	// ErrLeaseLost means another runner claimed the job after this one's
+	// lease ran out, so this runner's result is dropped.
+	ErrLeaseLost = errors.New("backtest lease lost")
+)
+
+// maxPendingJobs caps how many jobs one user may have queued or running.
+const maxPendingJobs = 5
+
+// Job statuses.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Request describes a backtest over provider candles.
+type Request struct {
+	Symbol   string             `json:"symbol"`
+	Interval string             `json:"interval"`
+	Limit    int                `json:"limit"`
+	Strategy string             `json:"strategy"`
+	Params   map[string]float64 `json:"params,omitempty"`
+	Config   Config             `json:"config"`
+}
+
+// Normalize fills defaults and validates r.
+func (r *Request) Normalize() error {
+	r.Symbol = strings.ToUpper(strings.TrimSpace(r.Symbol))
+	if r.Symbol == "" {
+		return fmt.Errorf("%w: symbol is required", ErrInvalidRequest)
+	}
+	if r.Interval == "" {
+		r.Interval = "daily"
+	}
+	if r.Limit <= 0 || r.Limit > 5000 {
+		r.Limit = 500
+	}
+	if r.Config == (Config{}) {
+		r.Config = DefaultConfig()
+	}
+	if r.Config.InitialCash <= 0 || r.Config.SlippageBps < 0 || r.Config.Commission < 0 || r.Config.CommissionPct < 0 {
+		return fmt.Errorf("%w: initial_cash must be positive and costs non-negative", ErrInvalidRequest)
+	}
+	_, err := NewStrategy(r.Strategy, r.Params)
+	return err
+}
+
+// Execute fetches candles for r and runs its strategy over them.
+func Execute(ctx context.Context, provider stocks.Provider, r Request) (*Result, error) {
+	if err := r.Normalize(); err != nil {
+		return nil, err
+	}
+	strategy, err := NewStrategy(r.Strategy, r.Params)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := provider.Intraday(ctx, r.Symbol, r.Interval, r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return Run(strategy, candles, r.Config)
+}
+
+// Job is a queued or finished backtest run on behalf of a user.
+type Job struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Status     string     `json:"status"`
+	Request    Request    `json:"request"`
+	Result     *Result    `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// ClaimToken identifies the runner's current lease on the job.
+	ClaimToken string `json:"-"`
+}
+
+type Repository interface {
+	CreateJob(ctx context.Context, job *Job) error
+	GetJob(ctx context.Context, userID, jobID int) (*Job, error)
+	// ListJobs returns userID's jobs without their results.
+	ListJobs(ctx context.Context, userID, limit int) ([]Job, error)
+	// CountPending returns how many of userID's jobs are queued or running.
+	CountPending(ctx context.Context, userID int) (int, error)
+	// ClaimJob leases the oldest queued job, or one whose previous runner
+	// died, for lease under a fresh ClaimToken. It returns nil when there
+	// is nothing to do. A job whose request can't be decoded is marked
+	// failed instead of claimed.
+	ClaimJob(ctx context.Context, lease time.Duration) (*Job, error)
+	// FinishJob stores job's outcome, returning ErrLeaseLost unless job's
+	// ClaimToken still holds the lease.
+	FinishJob(ctx context.Context, job *Job) error
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Submit validates r and queues it for userID.
+func (s *Service) Submit(ctx context.Context, userID int, r Request) (*Job, error) {
+	if err := r.Normalize(); err != nil {
+		return nil, err
+	}
+	pending, err := s.repo.CountPending(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pending >= maxPendingJobs {
+		return nil, fmt.Errorf("%w: at most %d backtests may be pending", ErrInvalidRequest, maxPendingJobs)
+	}
+	job := &Job{UserID: userID, Status: StatusQueued, Request: r}
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *Service) Get(ctx context.Context, userID, jobID int) (*Job, error) {
+	return s.repo.GetJob(ctx, userID, jobID)
+}
+
+func (s *Service) List(ctx context.Context, userID, limit int) ([]Job, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return s.repo.ListJobs(ctx, userID, limit)
+}
+
+// Runner executes queued jobs. Several replicas can run one each; jobs are
+// leased so each runs once.
+type Runner struct {
+	repo     Repository
+	provider stocks.Provider
+
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+func NewRunner(repo Repository, provider stocks.Provider) *Runner {
+	return &Runner{repo: repo, provider: provider, PollInterval: 2 * time.Second, Timeout: 2 * time.Minute}
+}
+
+// Run processes jobs until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		for r.RunOnce(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and runs one job, reporting whether there was one.
+func (r *Runner) RunOnce(ctx context.Context) bool {
+	job, err := r.repo.ClaimJob(ctx, r.Timeout+30*time.Second)
+	if err != nil {
+		log.Printf("backtest: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+	result, err := Execute(runCtx, r.provider, job.Request)
+	cancel()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status, job.Error = StatusFailed, err.Error()
+	} else {
+		job.Status, job.Result = StatusDone, result
+	}
+	if err := r.repo.FinishJob(ctx, job); errors.Is(err, ErrLeaseLost) {
+		log.Printf("backtest: job %d outlived its lease, dropping result", job.ID)
+	} else if err != nil {
+		log.Printf("backtest: %v", err)
+	}
+	return true
+}
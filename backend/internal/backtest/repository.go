@@ -0,0 +1,158 @@
+package backtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) CreateJob(ctx context.Context, job *Job) error {
+	req, err := json.Marshal(job.Request)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRow(ctx,
+		"INSERT INTO backtest_jobs (user_id, status, request) VALUES ($1, $2, $3) RETURNING id, created_at",
+		job.UserID, job.Status, req).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create backtest: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetJob(ctx context.Context, userID, jobID int) (*Job, error) {
+	var (
+		job         Job
+		req, result []byte
+	)
+	err := r.db.QueryRow(ctx,
+		`SELECT id, user_id, status, request, result, error, created_at, finished_at
+		 FROM backtest_jobs WHERE id = $1 AND user_id = $2`, jobID, userID).
+		Scan(&job.ID, &job.UserID, &job.Status, &req, &result, &job.Error, &job.CreatedAt, &job.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backtest: %w", err)
+	}
+	if err := json.Unmarshal(req, &job.Request); err != nil {
+		return nil, fmt.Errorf("failed to decode backtest request: %w", err)
+	}
+	if result != nil {
+		if err := json.Unmarshal(result, &job.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode backtest result: %w", err)
+		}
+	}
+	return &job, nil
+}
+
+func (r *PostgresRepository) ListJobs(ctx context.Context, userID, limit int) ([]Job, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, user_id, status, request, error, created_at, finished_at
+		 FROM backtest_jobs WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backtests: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var (
+			job Job
+			req []byte
+		)
+		if err := rows.Scan(&job.ID, &job.UserID, &job.Status, &req, &job.Error, &job.CreatedAt, &job.FinishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan backtest: %w", err)
+		}
+		if err := json.Unmarshal(req, &job.Request); err != nil {
+			return nil, fmt.Errorf("failed to decode backtest request: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r *PostgresRepository) CountPending(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx,
+		"SELECT COUNT(*) FROM backtest_jobs WHERE user_id = $1 AND status IN ('queued', 'running')", userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending backtests: %w", err)
+	}
+	return n, nil
+}
+
+func (r *PostgresRepository) ClaimJob(ctx context.Context, lease time.Duration) (*Job, error) {
+	query := `
+		WITH next AS (
+			SELECT id FROM backtest_jobs
+			WHERE status = 'queued' OR (status = 'running' AND lease_until < NOW())
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE backtest_jobs b
+		SET status = 'running', lease_until = NOW() + $1 * INTERVAL '1 second', claim_token = $2
+		FROM next
+		WHERE b.id = next.id
+		RETURNING b.id, b.user_id, b.status, b.request, b.created_at, b.claim_token
+	`
+	var (
+		job Job
+		req []byte
+	)
+	err := r.db.QueryRow(ctx, query, lease.Seconds(), rand.Text()).
+		Scan(&job.ID, &job.UserID, &job.Status, &req, &job.CreatedAt, &job.ClaimToken)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim backtest: %w", err)
+	}
+	if err := json.Unmarshal(req, &job.Request); err != nil {
+		// Left running, it would be reclaimed every time the lease ran out
+		decodeErr := fmt.Errorf("failed to decode backtest request: %w", err)
+		now := time.Now()
+		job.Status, job.Error, job.FinishedAt = StatusFailed, decodeErr.Error(), &now
+		if err := r.FinishJob(ctx, &job); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("backtest %d: %w", job.ID, decodeErr)
+	}
+	return &job, nil
+}
+
+func (r *PostgresRepository) FinishJob(ctx context.Context, job *Job) error {
+	var result []byte
+	if job.Result != nil {
+		var err error
+		if result, err = json.Marshal(job.Result); err != nil {
+			return err
+		}
+	}
+	tag, err := r.db.Exec(ctx,
+		`UPDATE backtest_jobs SET status = $2, result = $3, error = $4, finished_at = $5, lease_until = NULL
+		 WHERE id = $1 AND status = 'running' AND claim_token = $6`,
+		job.ID, job.Status, result, job.Error, job.FinishedAt, job.ClaimToken)
+	if err != nil {
+		return fmt.Errorf("failed to finish backtest: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package backtest
+
+import "math"
+
+// Stats summarise a run. Returns and drawdown are fractions.
+type Stats struct {
+	FinalEquity     float64 `json:"final_equity"`
+	TotalReturn     float64 `json:"total_return"`
+	BuyAndHold      float64 `json:"buy_and_hold_return"`
+	MaxDrawdown     float64 `json:"max_drawdown"`
+	Sharpe          float64 `json:"sharpe"`
+	Trades          int     `json:"trades"`
+	WinRate         float64 `json:"win_rate"`
+	AvgTradeReturn  float64 `json:"avg_trade_return"`
+	Exposure        float64 `json:"exposure"`
+	TotalCommission float64 `json:"total_commission"`
+}
+
+// barsPerYear annualises the Sharpe ratio, assuming daily bars.
+const barsPerYear = 252
+
+func computeStats(res *Result, initial float64) Stats {
+	var st Stats
+	if len(res.Equity) == 0 || initial <= 0 {
+		return st
+	}
+	st.FinalEquity = res.Equity[len(res.Equity)-1].Equity
+	st.TotalReturn = st.FinalEquity/initial - 1
+
+	bars := res.Equity
+	peak := initial
+	var rets []float64
+	prev := initial
+	invested := 0
+	for _, pt := range bars {
+		peak = math.Max(peak, pt.Equity)
+		st.MaxDrawdown = math.Max(st.MaxDrawdown, 1-pt.Equity/peak)
+		if prev > 0 {
+			rets = append(rets, pt.Equity/prev-1)
+		}
+		prev = pt.Equity
+		if pt.Position > 0 {
+			invested++
+		}
+	}
+	st.Exposure = float64(invested) / float64(len(bars))
+
+	if n := float64(len(rets)); n > 1 {
+		mean := 0.0
+		for _, r := range rets {
+			mean += r / n
+		}
+		variance := 0.0
+		for _, r := range rets {
+			variance += (r - mean) * (r - mean) / (n - 1)
+		}
+		if sd := math.Sqrt(variance); sd > 0 {
+			st.Sharpe = mean / sd * math.Sqrt(barsPerYear)
+		}
+	}
+
+	st.Trades = len(res.Trades)
+	wins := 0
+	for _, t := range res.Trades {
+		if t.PnL > 0 {
+			wins++
+		}
+		st.AvgTradeReturn += t.Return / float64(st.Trades)
+	}
+	if st.Trades > 0 {
+		st.WinRate = float64(wins) / float64(st.Trades)
+	}
+	for _, f := range res.Fills {
+		st.TotalCommission += f.Commission
+	}
+	return st
+}
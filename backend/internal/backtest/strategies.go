@@ -0,0 +1,125 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jamesfulreader/gostocks/internal/indicators"
+)
+
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// MACrossover goes all in when the fast SMA crosses above the slow one and
+// exits when it crosses back below.
+type MACrossover struct {
+	Fast, Slow int
+}
+
+func (s *MACrossover) Name() string { return fmt.Sprintf("ma_crossover(%d,%d)", s.Fast, s.Slow) }
+
+func (s *MACrossover) OnBar(b *Broker) {
+	closes := b.Closes()
+	if len(closes) < s.Slow+1 {
+		return
+	}
+	fast, slow := indicators.SMA(closes, s.Fast), indicators.SMA(closes, s.Slow)
+	n := len(closes) - 1
+	above, wasAbove := fast[n] > slow[n], fast[n-1] > slow[n-1]
+	switch {
+	case above && !wasAbove && b.Position() == 0:
+		b.Buy(math.Floor(b.Cash() / closes[n]))
+	case !above && wasAbove && b.Position() > 0:
+		b.Sell(b.Position())
+	}
+}
+
+// RSIMeanReversion buys when RSI drops below Oversold and sells when it
+// rises above Overbought.
+type RSIMeanReversion struct {
+	Period               int
+	Oversold, Overbought float64
+}
+
+func (s *RSIMeanReversion) Name() string {
+	return fmt.Sprintf("rsi_reversion(%d,%g,%g)", s.Period, s.Oversold, s.Overbought)
+}
+
+func (s *RSIMeanReversion) OnBar(b *Broker) {
+	closes := b.Closes()
+	rsi := indicators.RSI(closes, s.Period)
+	n := len(closes) - 1
+	if math.IsNaN(rsi[n]) {
+		return
+	}
+	switch {
+	case rsi[n] < s.Oversold && b.Position() == 0:
+		b.Buy(math.Floor(b.Cash() / closes[n]))
+	case rsi[n] > s.Overbought && b.Position() > 0:
+		b.Sell(b.Position())
+	}
+}
+
+type builtin struct {
+	defaults map[string]float64
+	build    func(p map[string]float64) (Strategy, error)
+}
+
+var builtins = map[string]builtin{
+	"ma_crossover": {
+		defaults: map[string]float64{"fast": 20, "slow": 50},
+		build: func(p map[string]float64) (Strategy, error) {
+			fast, slow := int(p["fast"]), int(p["slow"])
+			if fast < 1 || slow <= fast {
+				return nil, errors.New("ma_crossover needs 1 <= fast < slow")
+			}
+			return &MACrossover{Fast: fast, Slow: slow}, nil
+		},
+	},
+	"rsi_reversion": {
+		defaults: map[string]float64{"period": 14, "oversold": 30, "overbought": 70},
+		build: func(p map[string]float64) (Strategy, error) {
+			period := int(p["period"])
+			if period < 2 || p["oversold"] <= 0 || p["overbought"] >= 100 || p["oversold"] >= p["overbought"] {
+				return nil, errors.New("rsi_reversion needs period >= 2 and 0 < oversold < overbought < 100")
+			}
+			return &RSIMeanReversion{Period: period, Oversold: p["oversold"], Overbought: p["overbought"]}, nil
+		},
+	},
+}
+
+// Strategies lists the built-in strategy names.
+func Strategies() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy builds a built-in strategy. params override its defaults;
+// unknown keys are rejected.
+func NewStrategy(name string, params map[string]float64) (Strategy, error) {
+	def, ok := builtins[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownStrategy, name)
+	}
+	p := make(map[string]float64, len(def.defaults))
+	for k, v := range def.defaults {
+		p[k] = v
+	}
+	for k, v := range params {
+		if _, known := def.defaults[k]; !known {
+			return nil, fmt.Errorf("%w: unknown param %q for %s", ErrInvalidRequest, k, name)
+		}
+		p[k] = v
+	}
+	s, err := def.build(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,87 @@
+package digest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// Build computes userID's digest for the trading day from daily candles
+// keyed by symbol. Symbols with no candle for day, or no candle before it,
+// are listed as missing and left out of the totals.
+func Build(userID int, day time.Time, symbols []string, candles map[string][]stocks.Candle, topMovers int) *Digest {
+	day = market.Date(day)
+	d := &Digest{UserID: userID, TradingDay: day}
+	yearAgo := day.AddDate(-1, 0, 0)
+
+	for _, sym := range symbols {
+		series := candles[sym]
+		i := indexOfDay(series, day)
+		if i < 1 {
+			d.Missing = append(d.Missing, sym)
+			continue
+		}
+		today, prev := series[i], series[i-1]
+		d.Value += today.Close
+		d.PreviousValue += prev.Close
+		if prev.Close > 0 {
+			d.Movers = append(d.Movers, Mover{
+				Symbol:        sym,
+				Close:         today.Close,
+				PreviousClose: prev.Close,
+				ChangePercent: (today.Close - prev.Close) / prev.Close * 100,
+			})
+		}
+
+		high, low := math.Inf(-1), math.Inf(1)
+		for _, c := range series[:i] {
+			if candleDay(c).After(yearAgo) {
+				high = math.Max(high, c.High)
+				low = math.Min(low, c.Low)
+			}
+		}
+		if today.High > high {
+			d.NewHighs = append(d.NewHighs, sym)
+		}
+		if today.Low < low {
+			d.NewLows = append(d.NewLows, sym)
+		}
+	}
+
+	d.Change = d.Value - d.PreviousValue
+	if d.PreviousValue > 0 {
+		d.ChangePercent = d.Change / d.PreviousValue * 100
+	}
+	sort.SliceStable(d.Movers, func(i, j int) bool {
+		return math.Abs(d.Movers[i].ChangePercent) > math.Abs(d.Movers[j].ChangePercent)
+	})
+	if len(d.Movers) > topMovers {
+		d.Movers = d.Movers[:topMovers]
+	}
+	return d
+}
+
+// candleDay maps a daily candle to its exchange date. Providers stamp daily
+// bars with the session date at midnight in their own zone, so the calendar
+// fields are used as-is rather than converted.
+func candleDay(c stocks.Candle) time.Time {
+	y, m, dd := c.Time.Date()
+	return time.Date(y, m, dd, 0, 0, 0, 0, market.NewYork)
+}
+
+// indexOfDay returns the index of day's candle in an ascending series, or -1.
+func indexOfDay(series []stocks.Candle, day time.Time) int {
+	for i := len(series) - 1; i >= 0; i-- {
+		cd := candleDay(series[i])
+		if cd.Equal(day) {
+			return i
+		}
+		if cd.Before(day) {
+			break
+		}
+	}
+	return -1
+}
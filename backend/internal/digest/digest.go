@@ -0,0 +1,45 @@
+package digest
+
+import (
+	"context"
+	"time"
+)
+
+// Digest summarises one user's portfolio for one trading day. Values assume
+// one share of each symbol, matching the live portfolio snapshot.
+type Digest struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	TradingDay    time.Time `json:"trading_day"`
+	Value         float64   `json:"value"`
+	PreviousValue float64   `json:"previous_value"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Movers        []Mover   `json:"movers"`
+	NewHighs      []string  `json:"new_highs"`
+	NewLows       []string  `json:"new_lows"`
+	Missing       []string  `json:"missing,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Mover is one holding's move on the digest day.
+type Mover struct {
+	Symbol        string  `json:"symbol"`
+	Close         float64 `json:"close"`
+	PreviousClose float64 `json:"previous_close"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// Recipient is a user who holds at least one symbol.
+type Recipient struct {
+	UserID int
+	Email  string
+}
+
+type Repository interface {
+	// SaveDigest stores d unless the user already has a digest for that
+	// trading day, and reports whether it was inserted.
+	SaveDigest(ctx context.Context, d *Digest) (bool, error)
+	ListDigests(ctx context.Context, userID, limit int) ([]Digest, error)
+	Recipients(ctx context.Context) ([]Recipient, error)
+}
@@ -0,0 +1,143 @@
+package digest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/digest"
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// series returns ascending daily candles ending on end, one per closes entry.
+// High and low bracket the close by one.
+func series(end time.Time, closes ...float64) []stocks.Candle {
+	out := make([]stocks.Candle, len(closes))
+	for i, c := range closes {
+		day := end.AddDate(0, 0, i-len(closes)+1)
+		out[i] = stocks.Candle{
+			Time:  time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC),
+			Close: c, High: c + 1, Low: c - 1,
+		}
+	}
+	return out
+}
+
+func TestBuild(t *testing.T) {
+	day := time.Date(2026, time.April, 2, 0, 0, 0, 0, market.NewYork)
+	candles := map[string][]stocks.Candle{
+		"AAPL": series(day, 100, 110, 120, 130), // +8.33%, new high
+		"MSFT": series(day, 300, 290, 280, 275), // -1.79%, new low
+		"TSLA": series(day, 200, 210, 205, 206), // +0.49%
+		"OLD":  series(day.AddDate(0, 0, -1), 50, 51),
+	}
+
+	d := digest.Build(7, day, []string{"AAPL", "MSFT", "TSLA", "OLD", "NONE"}, candles, 2)
+
+	if d.Value != 130+275+206 || d.PreviousValue != 120+280+205 {
+		t.Errorf("unexpected totals %.2f / %.2f", d.Value, d.PreviousValue)
+	}
+	if d.Change != 6 {
+		t.Errorf("expected change 6, got %.2f", d.Change)
+	}
+	if len(d.Movers) != 2 || d.Movers[0].Symbol != "AAPL" || d.Movers[1].Symbol != "MSFT" {
+		t.Errorf("expected AAPL and MSFT as top movers, got %+v", d.Movers)
+	}
+	if len(d.NewHighs) != 1 || d.NewHighs[0] != "AAPL" {
+		t.Errorf("unexpected new highs %v", d.NewHighs)
+	}
+	if len(d.NewLows) != 1 || d.NewLows[0] != "MSFT" {
+		t.Errorf("unexpected new lows %v", d.NewLows)
+	}
+	if len(d.Missing) != 2 || d.Missing[0] != "OLD" || d.Missing[1] != "NONE" {
+		t.Errorf("expected stale and unknown symbols to be missing, got %v", d.Missing)
+	}
+}
+
+type fakeRepo struct {
+	saved map[int]*digest.Digest
+}
+
+func (f *fakeRepo) SaveDigest(ctx context.Context, d *digest.Digest) (bool, error) {
+	if _, ok := f.saved[d.UserID]; ok {
+		return false, nil
+	}
+	f.saved[d.UserID] = d
+	return true, nil
+}
+func (f *fakeRepo) ListDigests(ctx context.Context, userID, limit int) ([]digest.Digest, error) {
+	return nil, nil
+}
+func (f *fakeRepo) Recipients(ctx context.Context) ([]digest.Recipient, error) {
+	return []digest.Recipient{{UserID: 1, Email: "a@example.com"}, {UserID: 2, Email: "b@example.com"}}, nil
+}
+
+type fakePortfolios map[int][]string
+
+func (f fakePortfolios) GetPortfolio(ctx context.Context, userID int) ([]string, error) {
+	return f[userID], nil
+}
+
+type countingProvider struct {
+	stocks.Provider
+	day   time.Time
+	calls map[string]int
+	down  map[string]bool
+}
+
+func (p *countingProvider) Intraday(ctx context.Context, symbol, interval string, limit int) ([]stocks.Candle, error) {
+	p.calls[symbol]++
+	if p.down[symbol] {
+		return nil, errors.New("rate limited")
+	}
+	return series(p.day, 10, 11), nil
+}
+
+func TestRunDayStoresOncePerUser(t *testing.T) {
+	day := time.Date(2026, time.April, 2, 0, 0, 0, 0, market.NewYork)
+	repo := &fakeRepo{saved: map[int]*digest.Digest{}}
+	provider := &countingProvider{day: day, calls: map[string]int{}}
+	job := digest.NewJob(repo, fakePortfolios{1: {"AAPL", "MSFT"}, 2: {"AAPL"}}, provider)
+
+	var announced []string
+	job.OnDigest(func(ctx context.Context, r digest.Recipient, d *digest.Digest) {
+		announced = append(announced, r.Email)
+	})
+
+	job.RunDay(context.Background(), day)
+	job.RunDay(context.Background(), day) // a second replica, or a restart
+
+	if len(repo.saved) != 2 || repo.saved[1].Value != 22 || repo.saved[2].Value != 11 {
+		t.Errorf("unexpected digests %+v", repo.saved)
+	}
+	if len(announced) != 2 {
+		t.Errorf("expected each digest to be announced once, got %v", announced)
+	}
+	if provider.calls["AAPL"] != 2 {
+		t.Errorf("expected AAPL candles to be fetched once per run, got %d calls", provider.calls["AAPL"])
+	}
+}
+
+func TestRunDayHoldsBackDigestsDuringOutage(t *testing.T) {
+	day := time.Date(2026, time.April, 2, 0, 0, 0, 0, market.NewYork)
+	repo := &fakeRepo{saved: map[int]*digest.Digest{}}
+	provider := &countingProvider{day: day, calls: map[string]int{}, down: map[string]bool{"MSFT": true}}
+	job := digest.NewJob(repo, fakePortfolios{1: {"AAPL", "MSFT"}, 2: {"AAPL"}}, provider)
+
+	if job.RunDay(context.Background(), day) {
+		t.Error("expected an incomplete run")
+	}
+	if _, ok := repo.saved[1]; ok || repo.saved[2] == nil {
+		t.Fatalf("expected only user 2's digest, got %+v", repo.saved)
+	}
+
+	provider.down = nil
+	if !job.RunDay(context.Background(), day) {
+		t.Error("expected the retry to complete")
+	}
+	if d := repo.saved[1]; d == nil || len(d.Missing) != 0 {
+		t.Errorf("expected a full digest for user 1 on retry, got %+v", d)
+	}
+}
@@ -0,0 +1,143 @@
+package digest
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// PortfolioSource lists a user's symbols; *users.Service satisfies it.
+type PortfolioSource interface {
+	GetPortfolio(ctx context.Context, userID int) ([]string, error)
+}
+
+// DigestFunc is called for every newly stored digest.
+type DigestFunc func(ctx context.Context, r Recipient, d *Digest)
+
+// candleHistory covers a year of daily bars plus slack for holidays.
+const candleHistory = 260
+
+// Job builds a digest for every user with a portfolio once per trading day,
+// Delay after the close. Weekends and exchange holidays are skipped.
+// Digests are unique per user and day, so several replicas running the job
+// store and announce each digest once.
+type Job struct {
+	repo       Repository
+	portfolios PortfolioSource
+	provider   stocks.Provider
+	onDigest   DigestFunc
+
+	Delay     time.Duration
+	TopMovers int
+	// RetryDelay is how long to wait before building the digests a
+	// provider outage held back.
+	RetryDelay time.Duration
+}
+
+func NewJob(repo Repository, portfolios PortfolioSource, provider stocks.Provider) *Job {
+	return &Job{
+		repo:       repo,
+		portfolios: portfolios,
+		provider:   provider,
+		Delay:      30 * time.Minute,
+		TopMovers:  5,
+		RetryDelay: 15 * time.Minute,
+	}
+}
+
+// OnDigest registers the callback for newly stored digests.
+func (j *Job) OnDigest(fn DigestFunc) {
+	j.onDigest = fn
+}
+
+// runAt is when the digest for day is built.
+func (j *Job) runAt(day time.Time) time.Time {
+	return market.Close(day).Add(j.Delay)
+}
+
+// Run catches up on the most recent trading day, then builds each following
+// day's digests after the close until ctx is cancelled. Digests held back
+// by a provider outage are retried until the next day's run is due.
+func (j *Job) Run(ctx context.Context) {
+	now := time.Now()
+	day := market.Date(now)
+	if !market.IsTradingDay(day) || now.Before(j.runAt(day)) {
+		day = market.PreviousTradingDay(day)
+	}
+
+	for {
+		complete := j.RunDay(ctx, day)
+
+		at := time.Now().Add(j.RetryDelay)
+		if next := market.NextTradingDay(day); complete || !at.Before(j.runAt(next)) {
+			day, at = next, j.runAt(next)
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// RunDay builds and stores digests for day. Candles are fetched once per
+// symbol and shared across users. Users holding a symbol whose candles
+// couldn't be fetched are skipped, and RunDay reports false so they can be
+// retried; a digest is never stored with gaps an outage caused.
+func (j *Job) RunDay(ctx context.Context, day time.Time) bool {
+	recipients, err := j.repo.Recipients(ctx)
+	if err != nil {
+		log.Printf("digest: %v", err)
+		return false
+	}
+
+	candles := make(map[string][]stocks.Candle)
+	failed := make(map[string]bool)
+	stored, skipped := 0, 0
+recipients:
+	for _, r := range recipients {
+		symbols, err := j.portfolios.GetPortfolio(ctx, r.UserID)
+		if err != nil {
+			log.Printf("digest: portfolio for user %d: %v", r.UserID, err)
+			continue
+		}
+		if len(symbols) == 0 {
+			continue
+		}
+		for _, sym := range symbols {
+			if _, ok := candles[sym]; !ok && !failed[sym] {
+				series, err := j.provider.Intraday(ctx, sym, "daily", candleHistory)
+				if err != nil {
+					log.Printf("digest: candles for %s: %v", sym, err)
+					failed[sym] = true
+				} else {
+					candles[sym] = series
+				}
+			}
+			if failed[sym] {
+				skipped++
+				continue recipients
+			}
+		}
+
+		d := Build(r.UserID, day, symbols, candles, j.TopMovers)
+		inserted, err := j.repo.SaveDigest(ctx, d)
+		if err != nil {
+			log.Printf("digest: %v", err)
+			continue
+		}
+		if inserted {
+			stored++
+			if j.onDigest != nil {
+				j.onDigest(ctx, r, d)
+			}
+		}
+	}
+	log.Printf("digest: stored %d digests for %s, %d held back", stored, day.Format("2006-01-02"), skipped)
+	return skipped == 0
+}
@@ -0,0 +1,95 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) SaveDigest(ctx context.Context, d *Digest) (bool, error) {
+	movers, err := json.Marshal(d.Movers)
+	if err != nil {
+		return false, err
+	}
+	err = r.db.QueryRow(ctx,
+		`INSERT INTO portfolio_digests
+			(user_id, trading_day, value, previous_value, change, change_percent, movers, new_highs, new_lows, missing)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT (user_id, trading_day) DO NOTHING
+		 RETURNING id, created_at`,
+		d.UserID, d.TradingDay, d.Value, d.PreviousValue, d.Change, d.ChangePercent, movers,
+		nonNil(d.NewHighs), nonNil(d.NewLows), nonNil(d.Missing)).Scan(&d.ID, &d.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save digest: %w", err)
+	}
+	return true, nil
+}
+
+func (r *PostgresRepository) ListDigests(ctx context.Context, userID, limit int) ([]Digest, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, user_id, trading_day, value, previous_value, change, change_percent, movers, new_highs, new_lows, missing, created_at
+		 FROM portfolio_digests WHERE user_id = $1 ORDER BY trading_day DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digests: %w", err)
+	}
+	defer rows.Close()
+
+	var digests []Digest
+	for rows.Next() {
+		var d Digest
+		var movers []byte
+		if err := rows.Scan(&d.ID, &d.UserID, &d.TradingDay, &d.Value, &d.PreviousValue, &d.Change, &d.ChangePercent,
+			&movers, &d.NewHighs, &d.NewLows, &d.Missing, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan digest: %w", err)
+		}
+		if err := json.Unmarshal(movers, &d.Movers); err != nil {
+			return nil, fmt.Errorf("failed to decode digest movers: %w", err)
+		}
+		digests = append(digests, d)
+	}
+	return digests, rows.Err()
+}
+
+func (r *PostgresRepository) Recipients(ctx context.Context) ([]Recipient, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT u.id, u.email FROM users u
+		 WHERE u.email_verified_at IS NOT NULL AND u.disabled_at IS NULL
+		   AND EXISTS (SELECT 1 FROM user_portfolios p WHERE p.user_id = u.id)
+		 ORDER BY u.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digest recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Recipient
+	for rows.Next() {
+		var rc Recipient
+		if err := rows.Scan(&rc.UserID, &rc.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan digest recipient: %w", err)
+		}
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,19 @@
+package digest
+
+import "context"
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// List returns userID's digests, newest trading day first.
+func (s *Service) List(ctx context.Context, userID, limit int) ([]Digest, error) {
+	if limit <= 0 || limit > 365 {
+		limit = 30
+	}
+	return s.repo.ListDigests(ctx, userID, limit)
+}
@@ -0,0 +1,112 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+// Template names shipped with the package. Each has a <name>.txt template
+// that defines a "subject" block, and optionally a <name>.html template.
+const (
+	TemplateWelcome       = "welcome"
+	TemplateAlert         = "alert"
+	TemplateDigest        = "digest"
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Renderer renders named templates. Files in Dir, when set, take precedence
+// over the embedded defaults so deployments can restyle individual emails.
+type Renderer struct {
+	Dir string
+}
+
+// Render executes the text (and, if present, HTML) template called name.
+// The returned message has no recipient set.
+func (r *Renderer) Render(name string, data any) (Message, error) {
+	src, err := r.read(name + ".txt")
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := texttemplate.New(name).Parse(string(src))
+	if err != nil {
+		return Message{}, err
+	}
+	var subject, body bytes.Buffer
+	if text.Lookup("subject") == nil {
+		return Message{}, errors.New("email: template " + name + ".txt has no subject block")
+	}
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	msg := Message{Subject: strings.TrimSpace(subject.String()), Text: strings.TrimSpace(body.String()) + "\n"}
+
+	src, err = r.read(name + ".html")
+	if errors.Is(err, fs.ErrNotExist) {
+		return msg, nil
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := htmltemplate.New(name).Parse(string(src))
+	if err != nil {
+		return Message{}, err
+	}
+	var htmlBody bytes.Buffer
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+	msg.HTML = htmlBody.String()
+	return msg, nil
+}
+
+func (r *Renderer) read(file string) ([]byte, error) {
+	if r.Dir != "" {
+		src, err := os.ReadFile(filepath.Join(r.Dir, file))
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return src, err
+		}
+	}
+	return defaultTemplates.ReadFile("templates/" + file)
+}
+
+// Mailer renders templates and hands the result to a Notifier.
+type Mailer struct {
+	Notifier Notifier
+	Renderer *Renderer
+}
+
+// NewMailerFromEnv builds a Mailer using NewNotifierFromEnv, with template
+// overrides read from EMAIL_TEMPLATE_DIR.
+func NewMailerFromEnv() *Mailer {
+	return &Mailer{
+		Notifier: NewNotifierFromEnv(),
+		Renderer: &Renderer{Dir: config.GetenvDefault("EMAIL_TEMPLATE_DIR", "")},
+	}
+}
+
+// Send renders the template called name with data and sends it to to.
+func (m *Mailer) Send(ctx context.Context, to, name string, data any) error {
+	msg, err := m.Renderer.Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.Notifier.Send(ctx, msg)
+}
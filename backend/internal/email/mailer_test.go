@@ -0,0 +1,181 @@
+package email_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/digest"
+	"github.com/jamesfulreader/gostocks/internal/email"
+)
+
+type alertData struct {
+	Symbol  string
+	Message string
+	Price   float64
+	FiredAt time.Time
+}
+
+func TestMailerRendersDefaultTemplates(t *testing.T) {
+	sink := &email.MemoryNotifier{}
+	mailer := &email.Mailer{Notifier: sink, Renderer: &email.Renderer{}}
+
+	err := mailer.Send(context.Background(), "a@example.com", email.TemplateAlert, alertData{
+		Symbol:  "AAPL",
+		Message: "AAPL rose above 200.00",
+		Price:   201.5,
+		FiredAt: time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sent := sink.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected one message, got %d", len(sent))
+	}
+	msg := sent[0]
+	if msg.To != "a@example.com" || msg.Subject != "AAPL alert: AAPL rose above 200.00" {
+		t.Errorf("unexpected envelope %q / %q", msg.To, msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "Price: 201.50") {
+		t.Errorf("text body missing price:\n%s", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, "<strong>201.50</strong>") {
+		t.Errorf("html body missing price:\n%s", msg.HTML)
+	}
+}
+
+func TestRendererPrefersOverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	override := `{{define "subject"}}Hello {{.Email}}{{end}}Custom body`
+	if err := os.WriteFile(filepath.Join(dir, "welcome.txt"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := (&email.Renderer{Dir: dir}).Render(email.TemplateWelcome, map[string]string{"Email": "<b>@example.com"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if msg.Subject != "Hello <b>@example.com" || msg.Text != "Custom body\n" {
+		t.Errorf("override not used: %+v", msg)
+	}
+	// The HTML template was not overridden, so the embedded one is used and
+	// escapes its input.
+	if !strings.Contains(msg.HTML, "&lt;b&gt;@example.com") {
+		t.Errorf("expected escaped embedded html, got:\n%s", msg.HTML)
+	}
+}
+
+func TestFileNotifierWritesMultipartMessage(t *testing.T) {
+	dir := t.TempDir()
+	n := &email.FileNotifier{Dir: dir, From: "gostocks <noreply@example.com>"}
+	err := n.Send(context.Background(), email.Message{To: "a@example.com", Subject: "Hi", Text: "plain", HTML: "<p>rich</p>"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v", files)
+	}
+	raw, _ := os.ReadFile(files[0])
+	for _, want := range []string{"To: a@example.com", "Subject: Hi", "multipart/alternative", "plain", "<p>rich</p>"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("message missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestSubjectCannotInjectHeaders(t *testing.T) {
+	dir := t.TempDir()
+	n := &email.FileNotifier{Dir: dir, From: "gostocks <noreply@example.com>"}
+	err := n.Send(context.Background(), email.Message{To: "a@example.com", Subject: "Hi\r\nBcc: evil@example.com", Text: "plain"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	raw, _ := os.ReadFile(files[0])
+	if strings.Contains(string(raw), "\r\nBcc:") {
+		t.Errorf("subject started a new header:\n%s", raw)
+	}
+}
+
+func TestSMTPNotifierGivesUpOnStalledRelay(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept and never greet
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	n := &email.SMTPNotifier{Host: host, Port: port, From: "noreply@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Send(ctx, email.Message{To: "a@example.com", Subject: "Hi", Text: "plain"}); err == nil {
+		t.Fatal("expected an error from a stalled relay")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %v", elapsed)
+	}
+}
+
+func TestDigestTemplate(t *testing.T) {
+	d := &digest.Digest{
+		TradingDay:    time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC),
+		Value:         611,
+		Change:        6,
+		ChangePercent: 0.99,
+		Movers:        []digest.Mover{{Symbol: "AAPL", Close: 130, ChangePercent: 8.33}},
+		NewHighs:      []string{"AAPL", "NVDA"},
+	}
+	msg, err := (&email.Renderer{}).Render(email.TemplateDigest, d)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if msg.Subject != "Your portfolio on Apr 2: +0.99%" {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+	for _, want := range []string{"AAPL", "+8.33%", "New 52-week highs: AAPL, NVDA"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text body missing %q:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestTokenTemplates(t *testing.T) {
+	data := struct {
+		Email     string
+		Link      string
+		ExpiresAt time.Time
+	}{"a@example.com", "http://localhost:5173/reset-password?token=abc&x=1", time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+	for _, name := range []string{email.TemplateVerifyEmail, email.TemplatePasswordReset} {
+		msg, err := (&email.Renderer{}).Render(name, data)
+		if err != nil {
+			t.Fatalf("render %s: %v", name, err)
+		}
+		if !strings.Contains(msg.Text, data.Link) || !strings.Contains(msg.Text, "May 1 at 12:00 UTC") {
+			t.Errorf("%s text body missing link or expiry:\n%s", name, msg.Text)
+		}
+		if !strings.Contains(msg.HTML, `href="http://localhost:5173/reset-password?token=abc&amp;x=1"`) {
+			t.Errorf("%s html body missing escaped link:\n%s", name, msg.HTML)
+		}
+	}
+}
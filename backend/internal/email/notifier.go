@@ -0,0 +1,239 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+// Message is a rendered email with plain-text and optional HTML bodies.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers rendered messages.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewNotifierFromEnv picks a Notifier from EMAIL_BACKEND: "smtp", "file"
+// (the default, writing .eml files to EMAIL_FILE_DIR) or "log" (bodies
+// included when EMAIL_LOG_BODY is set).
+func NewNotifierFromEnv() Notifier {
+	from := config.GetenvDefault("EMAIL_FROM", "gostocks <noreply@localhost>")
+	switch config.GetenvDefault("EMAIL_BACKEND", "file") {
+	case "smtp":
+		return &SMTPNotifier{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     config.GetenvDefault("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "log":
+		return &LogNotifier{Body: config.GetenvBool("EMAIL_LOG_BODY", false)}
+	default:
+		return &FileNotifier{Dir: config.GetenvDefault("EMAIL_FILE_DIR", "mail"), From: from}
+	}
+}
+
+// SMTPNotifier sends through an SMTP relay using STARTTLS when offered.
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers msg, giving up when ctx is done even if the relay stalls
+// mid-conversation.
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	body, err := buildMIME(n.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := n.send(ctx, msg.To, body); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail over a connection bound to ctx.
+func (n *SMTPNotifier) send(ctx context.Context, to string, body []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(n.Host, n.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(envelopeAddress(n.From)); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// FileNotifier writes each message as an .eml file, for local development.
+type FileNotifier struct {
+	Dir  string
+	From string
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	body, err := buildMIME(n.From, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(n.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(n.Dir, name), body, 0o644)
+}
+
+// LogNotifier logs the recipient and subject, and with Body the text body
+// too so verification and reset links can be followed in development.
+type LogNotifier struct {
+	Body bool
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	if n.Body {
+		log.Printf("email to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+		return nil
+	}
+	log.Printf("email to %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+// MemoryNotifier keeps sent messages in memory, for tests.
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+// Sent returns a copy of every message sent so far.
+func (n *MemoryNotifier) Sent() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Message(nil), n.sent...)
+}
+
+// buildMIME renders msg as an RFC 5322 message, multipart/alternative when
+// there is an HTML body.
+func buildMIME(from string, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", encodeHeader(msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		buf.WriteString(msg.Text)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeHeader makes s safe as a header value: line breaks, which could
+// start new headers, are dropped and non-ASCII is RFC 2047 encoded.
+func encodeHeader(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// envelopeAddress extracts "a@b" from "Name <a@b>".
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
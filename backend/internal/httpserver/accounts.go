@@ -0,0 +1,131 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/email"
+	"github.com/jamesfulreader/gostocks/internal/users"
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+// tokenEmail is the data for the verify-email and password-reset templates.
+type tokenEmail struct {
+	Email     string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// sendTokenEmail emails t to its user as a link to path on the frontend.
+func (s *Server) sendTokenEmail(t *users.IssuedToken, template, path string) {
+	link := s.appBaseURL + path + "?token=" + url.QueryEscape(t.Token)
+	s.sendEmail(t.User.Email, template, tokenEmail{Email: t.User.Email, Link: link, ExpiresAt: t.ExpiresAt})
+}
+
+func (s *Server) handleVerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	user, err := s.userService.VerifyEmail(c.Request.Context(), req.Token)
+	if errors.Is(err, users.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+	s.sendEmail(user.Email, email.TemplateWelcome, user)
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) handleResendVerification(c *gin.Context) {
+	issued, err := s.userService.RequestVerification(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+	if issued == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already verified"})
+		return
+	}
+	s.sendTokenEmail(issued, email.TemplateVerifyEmail, "/verify-email")
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleForgotPassword answers the same way whether or not the email has
+// an account.
+func (s *Server) handleForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	issued, err := s.userService.RequestPasswordReset(c.Request.Context(), req.Email)
+	if err != nil {
+		log.Printf("Failed to issue password reset for %s: %v", req.Email, err)
+	}
+	if issued != nil {
+		s.sendTokenEmail(issued, email.TemplatePasswordReset, "/reset-password")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleResetPassword(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := s.userService.ResetPassword(ctx, req.Token, req.Password)
+	if errors.Is(err, users.ErrWeakPassword) || errors.Is(err, users.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	// Whoever knew the old password loses their sessions and any API keys
+	// they made, and the owner shouldn't stay locked out by their own
+	// earlier guesses
+	if err := s.sessionService.LogoutAll(ctx, user.ID); err != nil {
+		log.Printf("Failed to revoke sessions for user %d after password reset: %v", user.ID, err)
+	}
+	if err := s.apiKeyService.RevokeAll(ctx, user.ID); err != nil {
+		log.Printf("Failed to revoke api keys for user %d after password reset: %v", user.ID, err)
+	}
+	if err := s.loginGuard.Clear(ctx, user.Email); err != nil {
+		log.Printf("Failed to clear login lockout for user %d: %v", user.ID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// passwordPolicyFromEnv reads the PASSWORD_* policy settings.
+func passwordPolicyFromEnv() users.PasswordPolicy {
+	p := users.DefaultPasswordPolicy()
+	p.MinLength = config.GetenvInt("PASSWORD_MIN_LENGTH", p.MinLength)
+	p.RequireUpper = config.GetenvBool("PASSWORD_REQUIRE_UPPER", p.RequireUpper)
+	p.RequireLower = config.GetenvBool("PASSWORD_REQUIRE_LOWER", p.RequireLower)
+	p.RequireDigit = config.GetenvBool("PASSWORD_REQUIRE_DIGIT", p.RequireDigit)
+	p.RequireSymbol = config.GetenvBool("PASSWORD_REQUIRE_SYMBOL", p.RequireSymbol)
+	return p
+}
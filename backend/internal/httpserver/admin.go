@@ -0,0 +1,110 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+type adminUserRequest struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+func (s *Server) handleAdminListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	list, err := s.userService.ListUsers(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// handleAdminSetRole changes a user's role. It takes effect when their
+// current access token is next refreshed.
+func (s *Server) handleAdminSetRole(c *gin.Context) {
+	var req adminUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	auditDetail(c, "user_id", req.UserID)
+	auditDetail(c, "role", req.Role)
+	user, err := s.userService.SetRole(c.Request.Context(), req.UserID, req.Role)
+	s.adminUserResponse(c, user, err)
+}
+
+// handleAdminDisableUser blocks a user from logging in and ends all of
+// their sessions.
+func (s *Server) handleAdminDisableUser(c *gin.Context) {
+	var req adminUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	auditDetail(c, "user_id", req.UserID)
+	if req.UserID == c.GetInt("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot disable yourself"})
+		return
+	}
+	user, err := s.userService.SetDisabled(c.Request.Context(), req.UserID, true)
+	if err == nil {
+		if err := s.sessionService.LogoutAll(c.Request.Context(), user.ID); err != nil {
+			log.Printf("admin: failed to end sessions of user %d: %v", user.ID, err)
+		}
+		s.disconnectUser(user.ID)
+	}
+	s.adminUserResponse(c, user, err)
+}
+
+func (s *Server) handleAdminEnableUser(c *gin.Context) {
+	var req adminUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	auditDetail(c, "user_id", req.UserID)
+	user, err := s.userService.SetDisabled(c.Request.Context(), req.UserID, false)
+	s.adminUserResponse(c, user, err)
+}
+
+func (s *Server) adminUserResponse(c *gin.Context, user *users.User, err error) {
+	switch {
+	case errors.Is(err, users.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, users.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+	default:
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+func (s *Server) handleAdminProviders(c *gin.Context) {
+	health := []stocks.ProviderHealth{}
+	if hr, ok := s.provider.(stocks.HealthReporter); ok {
+		health = append(health, hr.Health()...)
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": health})
+}
+
+// handleAdminPurgeCaches empties this replica's quote and indicator caches.
+func (s *Server) handleAdminPurgeCaches(c *gin.Context) {
+	purged := gin.H{}
+	if p, ok := s.provider.(interface{ Purge() }); ok {
+		p.Purge()
+		purged["quotes"] = true
+	}
+	if s.indicatorService != nil {
+		purged["indicators"] = s.indicatorService.Purge()
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "purged": purged})
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/alerts"
+)
+
+func (s *Server) handleListAlerts(c *gin.Context) {
+	userID := c.GetInt("userID")
+	rules, err := s.alertService.ListRules(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (s *Server) handleCreateAlert(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req struct {
+		Symbol    string  `json:"symbol"`
+		Kind      string  `json:"kind"`
+		Threshold float64 `json:"threshold"`
+		Window    int     `json:"window"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	rule, err := s.alertService.CreateRule(c.Request.Context(), userID, alerts.Rule{
+		Symbol:    req.Symbol,
+		Kind:      req.Kind,
+		Threshold: req.Threshold,
+		Window:    req.Window,
+	})
+	if errors.Is(err, alerts.ErrInvalidRule) || errors.Is(err, alerts.ErrTooManyRules) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create alert"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) handleDeleteAlert(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err = s.alertService.DeleteRule(c.Request.Context(), userID, id)
+	if errors.Is(err, alerts.ErrRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete alert"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleAlertHistory(c *gin.Context) {
+	userID := c.GetInt("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	history, err := s.alertService.History(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get alert history"})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/analytics"
+	"github.com/jamesfulreader/gostocks/internal/market"
+)
+
+func (s *Server) handlePortfolioRisk(c *gin.Context) {
+	userID := c.GetInt("userID")
+	days, _ := strconv.Atoi(c.Query("days"))
+	risk, err := s.analyticsService.Risk(c.Request.Context(), userID, c.Query("benchmark"), days)
+	if s.analyticsError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, risk)
+}
+
+func (s *Server) handlePortfolioPerformance(c *gin.Context) {
+	userID := c.GetInt("userID")
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, market.NewYork)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(-1, 0, 0)
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, market.NewYork)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = t
+	}
+
+	perf, err := s.analyticsService.Performance(c.Request.Context(), userID, c.Query("benchmark"), from, to)
+	if s.analyticsError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, perf)
+}
+
+// analyticsError writes the response for err and reports whether it did.
+func (s *Server) analyticsError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, analytics.ErrInvalidRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, analytics.ErrEmptyPortfolio), errors.Is(err, analytics.ErrInsufficientData):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("analytics error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to load price history"})
+	}
+	return true
+}
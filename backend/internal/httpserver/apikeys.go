@@ -0,0 +1,68 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/apikeys"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+func (s *Server) handleListAPIKeys(c *gin.Context) {
+	userID := c.GetInt("userID")
+	keys, err := s.apiKeyService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list api keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "scopes": auth.Scopes()})
+}
+
+// handleCreateAPIKey returns the new key's secret; it can't be retrieved
+// again.
+func (s *Server) handleCreateAPIKey(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	key, err := s.apiKeyService.Create(c.Request.Context(), userID, req.Name, req.Scopes, req.ExpiresAt)
+	if errors.Is(err, apikeys.ErrInvalidKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+func (s *Server) handleDeleteAPIKey(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err = s.apiKeyService.Delete(c.Request.Context(), userID, id)
+	if errors.Is(err, apikeys.ErrKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete api key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
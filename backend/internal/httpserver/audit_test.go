@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/audit"
+)
+
+type memAuditRepo struct {
+	events []audit.Event
+}
+
+func (m *memAuditRepo) Append(ctx context.Context, e *audit.Event) error {
+	e.ID = len(m.events) + 1
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *memAuditRepo) List(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	return m.events, nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &memAuditRepo{}
+	s := &Server{auditLog: audit.NewLog(repo)}
+
+	router := gin.New()
+	api := router.Group("/api")
+	api.Use(s.auditMiddleware())
+	api.GET("/portfolio", func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.POST("/portfolio", func(c *gin.Context) {
+		c.Set("userID", 7)
+		auditDetail(c, "symbol", "AAPL")
+		c.Status(http.StatusOK)
+	})
+	api.POST("/register", func(c *gin.Context) {
+		auditActor(c, 9)
+		c.Status(http.StatusCreated)
+	})
+	api.POST("/login", func(c *gin.Context) { c.Status(http.StatusUnauthorized) })
+
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/api/portfolio"},
+		{http.MethodPost, "/api/portfolio"},
+		{http.MethodPost, "/api/register"},
+		{http.MethodPost, "/api/login"},
+		{http.MethodPost, "/api/nowhere"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(repo.events) != 3 {
+		t.Fatalf("recorded %d events, want 3: %+v", len(repo.events), repo.events)
+	}
+	add, register, login := repo.events[0], repo.events[1], repo.events[2]
+	if add.Action != "portfolio.add" || add.ActorID == nil || *add.ActorID != 7 || add.Details["symbol"] != "AAPL" {
+		t.Errorf("portfolio add event = %+v", add)
+	}
+	if add.IP != "192.0.2.1" || add.Status != http.StatusOK || add.At.IsZero() {
+		t.Errorf("portfolio add event = %+v", add)
+	}
+	if register.Action != "user.register" || register.ActorID == nil || *register.ActorID != 9 {
+		t.Errorf("register event = %+v", register)
+	}
+	if login.Action != "user.login" || login.ActorID != nil || login.Status != http.StatusUnauthorized {
+		t.Errorf("failed login event = %+v", login)
+	}
+}
@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/backtest"
+)
+
+func (s *Server) handleListBacktests(c *gin.Context) {
+	userID := c.GetInt("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	jobs, err := s.backtestService.List(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list backtests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backtests": jobs, "strategies": backtest.Strategies()})
+}
+
+func (s *Server) handleCreateBacktest(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req backtest.Request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	job, err := s.backtestService.Submit(c.Request.Context(), userID, req)
+	if errors.Is(err, backtest.ErrInvalidRequest) || errors.Is(err, backtest.ErrUnknownStrategy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue backtest"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (s *Server) handleGetBacktest(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	job, err := s.backtestService.Get(c.Request.Context(), userID, id)
+	if errors.Is(err, backtest.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "backtest not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get backtest"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
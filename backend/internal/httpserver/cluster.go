@@ -0,0 +1,327 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+const (
+	// ticksChannel carries quotes from the leader to every replica.
+	ticksChannel = "gostocks_ticks"
+	// demandChannel carries each replica's subscribed symbols to the leader.
+	demandChannel = "gostocks_demand"
+
+	// tickerLockKey is the advisory lock held by the replica that polls
+	// upstream. Its value is "gostocks" read as a big-endian int64.
+	tickerLockKey int64 = 0x676f73746f636b73
+
+	// demandTTL is how long a replica's announced symbols stay polled
+	// without being re-announced.
+	demandTTL = 30 * time.Second
+
+	// maxNotifyPayload keeps NOTIFY payloads under Postgres's 8000-byte
+	// limit.
+	maxNotifyPayload = 7900
+)
+
+// clusterMessage is the JSON payload of a NOTIFY on ticksChannel or
+// demandChannel.
+type clusterMessage struct {
+	Type    string            `json:"type"` // "quote", "tick_end", "user", "portfolio", "disconnect" or "demand"
+	ID      uint64            `json:"id,omitempty"`
+	Symbol  string            `json:"symbol,omitempty"`
+	Added   bool              `json:"added,omitempty"`
+	Quote   *stocks.Quote     `json:"quote,omitempty"`
+	Replica string            `json:"replica,omitempty"`
+	Symbols []string          `json:"symbols,omitempty"`
+	UserID  int               `json:"user_id,omitempty"`
+	Message *WebSocketMessage `json:"message,omitempty"`
+}
+
+// Cluster lets several backend replicas share one upstream poller. Replicas
+// elect a leader with a Postgres advisory lock; the leader polls the union of
+// every replica's subscriptions and publishes quotes with NOTIFY, and every
+// replica LISTENs and fans them out to its own clients.
+type Cluster struct {
+	pool     *pgxpool.Pool
+	sm       *SubscriptionManager
+	replica  string
+	isLeader atomic.Bool
+
+	mu     sync.Mutex
+	demand map[string]time.Time
+	// pending collects quotes between tick_end messages.
+	pending map[string]*stocks.Quote
+}
+
+func NewCluster(pool *pgxpool.Pool, sm *SubscriptionManager) *Cluster {
+	host, _ := os.Hostname()
+	return &Cluster{
+		pool:    pool,
+		sm:      sm,
+		replica: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		demand:  make(map[string]time.Time),
+		pending: make(map[string]*stocks.Quote),
+	}
+}
+
+// Start runs leader election and the notification listener until ctx ends.
+func (cl *Cluster) Start(ctx context.Context) {
+	go cl.elect(ctx)
+	go cl.listen(ctx)
+}
+
+// IsLeader reports whether this replica currently holds the ticker lock.
+func (cl *Cluster) IsLeader() bool {
+	return cl.isLeader.Load()
+}
+
+// elect tries to take the advisory lock and, once held, keeps the session
+// that owns it alive. Postgres drops the lock if that session dies, which
+// lets another replica take over.
+func (cl *Cluster) elect(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var lockConn *pgxpool.Conn
+	for {
+		if lockConn != nil {
+			if _, err := lockConn.Exec(ctx, "SELECT 1"); err != nil {
+				log.Printf("cluster: lost ticker leadership: %v", err)
+				lockConn.Conn().Close(context.Background())
+				lockConn.Release()
+				lockConn = nil
+				cl.setLeader(false)
+			}
+		} else {
+			conn, err := cl.pool.Acquire(ctx)
+			if err == nil {
+				var acquired bool
+				err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", tickerLockKey).Scan(&acquired)
+				if err == nil && acquired {
+					lockConn = conn
+					cl.setLeader(true)
+					log.Printf("cluster: replica %s is now the ticker leader", cl.replica)
+				} else {
+					conn.Release()
+				}
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("cluster: leader election: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lockConn != nil {
+				lockConn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", tickerLockKey)
+				lockConn.Release()
+				cl.setLeader(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cl *Cluster) setLeader(leader bool) {
+	cl.isLeader.Store(leader)
+	if leader {
+		clusterLeader.Set(1)
+	} else {
+		clusterLeader.Set(0)
+	}
+}
+
+// listen holds a dedicated connection LISTENing on both channels,
+// reconnecting with backoff if it drops.
+func (cl *Cluster) listen(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := cl.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("cluster: listener stopped: %v; retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (cl *Cluster) listenOnce(ctx context.Context) error {
+	conn, err := cl.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A LISTENing session must not go back to the pool.
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	for _, channel := range []string{ticksChannel, demandChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		clusterMetrics.Add(metricNotificationsReceived, 1)
+		cl.handleNotification(n.Channel, n.Payload)
+	}
+}
+
+// handleNotification applies one NOTIFY payload to local state.
+func (cl *Cluster) handleNotification(channel, payload string) {
+	var msg clusterMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("cluster: bad payload on %s: %v", channel, err)
+		return
+	}
+
+	switch msg.Type {
+	case "demand":
+		now := time.Now()
+		cl.mu.Lock()
+		for _, symbol := range msg.Symbols {
+			cl.demand[symbol] = now
+		}
+		cl.mu.Unlock()
+	case "quote":
+		if msg.Quote == nil || msg.Symbol == "" {
+			return
+		}
+		cl.mu.Lock()
+		cl.pending[msg.Symbol] = msg.Quote
+		cl.mu.Unlock()
+		cl.sm.broadcast <- WebSocketMessage{ID: msg.ID, Action: "update", Symbol: msg.Symbol, Payload: msg.Quote}
+	case "user":
+		if msg.Message != nil {
+			cl.sm.SendToUser(msg.UserID, *msg.Message)
+		}
+	case "portfolio":
+		if msg.Symbol != "" {
+			cl.sm.PortfolioChanged(msg.UserID, msg.Symbol, msg.Added)
+		}
+	case "disconnect":
+		cl.sm.DisconnectUser(msg.UserID)
+	case "tick_end":
+		cl.mu.Lock()
+		quotes := cl.pending
+		cl.pending = make(map[string]*stocks.Quote)
+		cl.mu.Unlock()
+		cl.sm.ticks <- quotes
+	}
+}
+
+func (cl *Cluster) notify(ctx context.Context, channel string, msg clusterMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := cl.pool.Exec(ctx, "SELECT pg_notify($1, $2)", channel, string(payload)); err != nil {
+		return err
+	}
+	clusterMetrics.Add(metricNotificationsSent, 1)
+	return nil
+}
+
+// Announce tells the leader which symbols this replica's clients want,
+// over as many notifications as the payload limit needs.
+func (cl *Cluster) Announce(ctx context.Context, symbols []string) error {
+	for _, batch := range demandBatches(cl.replica, symbols) {
+		if err := cl.notify(ctx, demandChannel, clusterMessage{Type: "demand", Replica: cl.replica, Symbols: batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// demandBatches splits symbols so that each demand message announcing a
+// batch encodes to at most maxNotifyPayload bytes.
+func demandBatches(replica string, symbols []string) [][]string {
+	empty, _ := json.Marshal(clusterMessage{Type: "demand", Replica: replica, Symbols: []string{""}})
+	var batches [][]string
+	var batch []string
+	size := len(empty)
+	for _, symbol := range symbols {
+		encoded, _ := json.Marshal(symbol)
+		if len(batch) > 0 && size+len(encoded)+1 > maxNotifyPayload {
+			batches = append(batches, batch)
+			batch, size = nil, len(empty)
+		}
+		batch = append(batch, symbol)
+		size += len(encoded) + 1
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// Demand returns the union of local symbols and those announced by any
+// replica within demandTTL, dropping expired entries.
+func (cl *Cluster) Demand(local []string) []string {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	now := time.Now()
+	for _, symbol := range local {
+		cl.demand[symbol] = now
+	}
+	symbols := make([]string, 0, len(cl.demand))
+	for symbol, seen := range cl.demand {
+		if now.Sub(seen) > demandTTL {
+			delete(cl.demand, symbol)
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// PublishQuote sends the quote polled for symbol to every replica, numbered
+// from a shared sequence so SSE clients can resume on any of them.
+func (cl *Cluster) PublishQuote(ctx context.Context, symbol string, q *stocks.Quote) error {
+	var id int64
+	if err := cl.pool.QueryRow(ctx, "SELECT nextval('stream_event_seq')").Scan(&id); err != nil {
+		return err
+	}
+	return cl.notify(ctx, ticksChannel, clusterMessage{Type: "quote", ID: uint64(id), Symbol: symbol, Quote: q})
+}
+
+// PublishTickEnd marks the end of a polling round so replicas can push
+// portfolio aggregates.
+func (cl *Cluster) PublishTickEnd(ctx context.Context) error {
+	return cl.notify(ctx, ticksChannel, clusterMessage{Type: "tick_end"})
+}
+
+// PublishPortfolioChange updates the subscriptions of userID's
+// portfolio-following connections on every replica.
+func (cl *Cluster) PublishPortfolioChange(ctx context.Context, userID int, symbol string, added bool) error {
+	return cl.notify(ctx, ticksChannel, clusterMessage{Type: "portfolio", UserID: userID, Symbol: symbol, Added: added})
+}
+
+// PublishDisconnect closes userID's connections on every replica.
+func (cl *Cluster) PublishDisconnect(ctx context.Context, userID int) error {
+	return cl.notify(ctx, ticksChannel, clusterMessage{Type: "disconnect", UserID: userID})
+}
+
+// PublishToUser delivers message to userID's connections on every replica.
+func (cl *Cluster) PublishToUser(ctx context.Context, userID int, message WebSocketMessage) error {
+	return cl.notify(ctx, ticksChannel, clusterMessage{Type: "user", UserID: userID, Message: &message})
+}
@@ -0,0 +1,126 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+func notification(t *testing.T, msg clusterMessage) string {
+	t.Helper()
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(b)
+}
+
+func TestClusterNotificationsFanOutLocally(t *testing.T) {
+	sm := NewSubscriptionManager(0, 0)
+	client := &Client{send: make(chan WebSocketMessage, 4), symbols: map[string]bool{}, portfolio: map[string]bool{"AAPL": true}}
+	go sm.Run()
+	sm.register <- client
+	sm.subscribe <- subscription{client, "AAPL"}
+
+	cl := NewCluster(nil, sm)
+	cl.handleNotification(ticksChannel, notification(t, clusterMessage{
+		Type: "quote", Symbol: "AAPL", Quote: &stocks.Quote{Symbol: "AAPL", Price: 10},
+	}))
+	cl.handleNotification(ticksChannel, notification(t, clusterMessage{Type: "tick_end"}))
+
+	select {
+	case msg := <-client.send:
+		if msg.Action != "update" || msg.Symbol != "AAPL" {
+			t.Errorf("expected AAPL update, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no update delivered")
+	}
+	select {
+	case msg := <-client.send:
+		snap, ok := msg.Payload.(PortfolioSnapshot)
+		if msg.Action != "portfolio" || !ok || snap.Value != 10 {
+			t.Errorf("expected portfolio snapshot worth 10, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no portfolio snapshot delivered")
+	}
+}
+
+func TestClusterDemandUnionAndExpiry(t *testing.T) {
+	cl := NewCluster(nil, nil)
+	cl.handleNotification(demandChannel, notification(t, clusterMessage{
+		Type: "demand", Replica: "other", Symbols: []string{"MSFT", "TSLA"},
+	}))
+	cl.demand["OLD"] = time.Now().Add(-2 * demandTTL)
+
+	got := cl.Demand([]string{"AAPL", "MSFT"})
+	sort.Strings(got)
+	if !equalStrings(got, []string{"AAPL", "MSFT", "TSLA"}) {
+		t.Errorf("unexpected demand %v", got)
+	}
+	if _, ok := cl.demand["OLD"]; ok {
+		t.Error("expired demand was not dropped")
+	}
+}
+
+func TestClusterDemandBatchesFitNotifyLimit(t *testing.T) {
+	symbols := make([]string, 2000)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("SYM%05d", i)
+	}
+
+	var announced []string
+	for _, batch := range demandBatches("replica-1", symbols) {
+		payload := notification(t, clusterMessage{Type: "demand", Replica: "replica-1", Symbols: batch})
+		if len(payload) > maxNotifyPayload {
+			t.Errorf("payload of %d bytes exceeds the limit", len(payload))
+		}
+		announced = append(announced, batch...)
+	}
+	if !equalStrings(announced, symbols) {
+		t.Errorf("batches announced %d of %d symbols", len(announced), len(symbols))
+	}
+	if batches := demandBatches("replica-1", nil); len(batches) != 0 {
+		t.Errorf("expected no batches for no symbols, got %v", batches)
+	}
+}
+
+func TestClusterPortfolioChanges(t *testing.T) {
+	sm := NewSubscriptionManager(0, 0)
+	client := &Client{userID: 5, send: make(chan WebSocketMessage, 4), symbols: map[string]bool{}, portfolio: map[string]bool{}}
+	go sm.Run()
+	sm.register <- client
+
+	cl := NewCluster(nil, sm)
+	cl.handleNotification(ticksChannel, notification(t, clusterMessage{Type: "portfolio", UserID: 5, Symbol: "TSLA", Added: true}))
+	cl.handleNotification(ticksChannel, notification(t, clusterMessage{Type: "portfolio", UserID: 6, Symbol: "GOOG", Added: true}))
+
+	waitFor(t, time.Second, func() bool {
+		return equalStrings(subscribedSymbols(sm), []string{"TSLA"})
+	})
+}
+
+func TestClusterDisconnectsUser(t *testing.T) {
+	sm := NewSubscriptionManager(0, 0)
+	disabled := &Client{userID: 5, send: make(chan WebSocketMessage, 4), symbols: map[string]bool{}}
+	other := &Client{userID: 6, send: make(chan WebSocketMessage, 4), symbols: map[string]bool{}}
+	go sm.Run()
+	sm.register <- disabled
+	sm.register <- other
+
+	cl := NewCluster(nil, sm)
+	cl.handleNotification(ticksChannel, notification(t, clusterMessage{Type: "disconnect", UserID: 5}))
+
+	if _, ok := <-disabled.send; ok {
+		t.Error("expected the disabled user's connection to be closed")
+	}
+	sm.SendToUser(6, WebSocketMessage{Action: "ping"})
+	if msg := <-other.send; msg.Action != "ping" {
+		t.Errorf("expected other users to stay connected, got %+v", msg)
+	}
+}
@@ -0,0 +1,19 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleListDigests(c *gin.Context) {
+	userID := c.GetInt("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	digests, err := s.digestService.List(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list digests"})
+		return
+	}
+	c.JSON(http.StatusOK, digests)
+}
@@ -0,0 +1,43 @@
+package httpserver
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// emailTimeout bounds a single send so a stuck SMTP relay cannot pile up
+// goroutines.
+const emailTimeout = 30 * time.Second
+
+// sendEmail renders and sends a templated email in the background. Failures
+// are logged; email is never on the request path.
+func (s *Server) sendEmail(to, template string, data any) {
+	if s.mailer == nil || to == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, to, template, data); err != nil {
+			log.Printf("email %s to %s: %v", template, to, err)
+		}
+	}()
+}
+
+// emailUser looks up userID's address and sends them a templated email,
+// unless the address is unverified or the account disabled.
+func (s *Server) emailUser(userID int, template string, data any) {
+	if s.mailer == nil {
+		return
+	}
+	user, err := s.userService.GetUser(context.Background(), userID)
+	if err != nil {
+		log.Printf("email %s to user %d: %v", template, userID, err)
+		return
+	}
+	if user.EmailVerifiedAt == nil || user.Disabled() {
+		return
+	}
+	s.sendEmail(user.Email, template, data)
+}
@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/indicators"
+)
+
+func (s *Server) handleIndicators(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	interval := c.DefaultQuery("interval", "daily")
+	name := c.Query("name")
+	if symbol == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing symbol or name"})
+		return
+	}
+
+	result, err := s.indicatorService.Get(c.Request.Context(), symbol, interval, name, c.Query("params"))
+	switch {
+	case errors.Is(err, indicators.ErrUnknownIndicator):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "supported": indicators.Names()})
+		return
+	case errors.Is(err, indicators.ErrUnknownInterval):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "supported": indicators.Intervals()})
+		return
+	case errors.Is(err, indicators.ErrInvalidParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("indicators error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"symbol": symbol, "interval": interval, "indicator": result})
+}
@@ -0,0 +1,17 @@
+package httpserver
+
+import "testing"
+
+func TestRedactPath(t *testing.T) {
+	cases := map[string]string{
+		"/api/quote?symbol=AAPL":                "/api/quote?symbol=AAPL",
+		"/ws?token=eyJhbGciOi.secret":           "/ws?token=REDACTED",
+		"/api/stream?symbols=AAPL&token=abc.de": "/api/stream?symbols=AAPL&token=REDACTED",
+		"/ws":                                   "/ws",
+	}
+	for in, want := range cases {
+		if got := redactPath(in); got != want {
+			t.Errorf("redactPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/market"
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+// tickInterval is how often the ticker loop wakes up. Upstream polling
+// happens on the ticks allowed by TickerConfig; cluster demand is announced
+// on every tick so it never expires on the leader.
+const tickInterval = 5 * time.Second
+
+// TickerConfig sets how often quotes are polled upstream in each market
+// session. Zero values poll on every tick.
+type TickerConfig struct {
+	OpenInterval     time.Duration
+	ExtendedInterval time.Duration
+	ClosedInterval   time.Duration
+}
+
+// TickerConfigFromEnv reads the TICKER_* intervals.
+func TickerConfigFromEnv() TickerConfig {
+	return TickerConfig{
+		OpenInterval:     config.GetenvDuration("TICKER_OPEN_INTERVAL", tickInterval),
+		ExtendedInterval: config.GetenvDuration("TICKER_EXTENDED_INTERVAL", 30*time.Second),
+		ClosedInterval:   config.GetenvDuration("TICKER_CLOSED_INTERVAL", 5*time.Minute),
+	}
+}
+
+// PollInterval returns the polling cadence for the session in progress at t.
+func (c TickerConfig) PollInterval(t time.Time) time.Duration {
+	switch market.SessionAt(t) {
+	case market.SessionRegular:
+		return c.OpenInterval
+	case market.SessionPreMarket, market.SessionPostMarket:
+		return c.ExtendedInterval
+	}
+	return c.ClosedInterval
+}
+
+func (s *Server) handleMarketStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, market.StatusAt(time.Now()))
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// wsMetrics is published at /metrics (expvar JSON) under the "websocket" key.
+var wsMetrics = expvar.NewMap("websocket")
+
+const (
+	metricConnectionsActive = "connections_active"
+	metricConnectionsTotal  = "connections_total"
+	metricReapedTimeout     = "reaped_timeout"
+	metricReapedSlow        = "reaped_slow_consumer"
+
+	metricAuthFailures          = "auth_failures"
+	metricOriginRejected        = "origin_rejected"
+	metricSubscriptionsRejected = "subscriptions_rejected"
+)
+
+// clusterMetrics describes the Postgres LISTEN/NOTIFY fan-out.
+var (
+	clusterMetrics = expvar.NewMap("cluster")
+	clusterLeader  = new(expvar.Int)
+)
+
+const (
+	metricNotificationsSent     = "notifications_sent"
+	metricNotificationsReceived = "notifications_received"
+)
+
+func init() {
+	clusterMetrics.Set("leader", clusterLeader)
+}
+
+// publicMetrics are the expvars served at /metrics. The rest, like
+// cmdline and memstats, would expose process arguments and internals to
+// anyone.
+var publicMetrics = []string{"websocket", "cluster"}
+
+func handleMetrics(c *gin.Context) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, name := range publicMetrics {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%q:%s", name, expvar.Get(name).String())
+	}
+	b.WriteString("}")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(b.String()))
+}
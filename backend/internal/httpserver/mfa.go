@@ -0,0 +1,200 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/lockout"
+	"github.com/jamesfulreader/gostocks/internal/mfa"
+)
+
+// mfaError maps mfa errors to responses, returning false for unexpected
+// ones so the caller can pick its own 500 message.
+func mfaError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, mfa.ErrAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (s *Server) handleMFAStatus(c *gin.Context) {
+	status, err := s.mfaService.Status(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get 2fa status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleMFAEnroll(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := s.userService.GetUser(ctx, c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+	enrollment, err := s.mfaService.Enroll(ctx, user.ID, user.Email)
+	if mfaError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (s *Server) handleMFAConfirm(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	codes, err := s.mfaService.Confirm(c.Request.Context(), c.GetInt("userID"), req.Code)
+	if mfaError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm 2fa"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// beginMFACheck gates a settings change that needs a 2FA code behind the
+// login lockout, so a stolen session can't be used to guess codes. It
+// answers the request and returns false if the caller must stop.
+func (s *Server) beginMFACheck(c *gin.Context) (lockout.Attempt, bool) {
+	user, err := s.userService.GetUser(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		return lockout.Attempt{}, false
+	}
+	attempt := lockout.Attempt{Email: user.Email, UserID: &user.ID, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	return attempt, !s.loginLocked(c, attempt)
+}
+
+// endMFACheck records the result of the code check begun by beginMFACheck.
+// Wrong codes count as failed logins.
+func (s *Server) endMFACheck(ctx context.Context, attempt lockout.Attempt, err error) {
+	attempt.Outcome = lockout.OutcomeMFAVerified
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		attempt.Outcome = lockout.OutcomeInvalid
+	}
+	s.recordLogin(ctx, attempt)
+}
+
+func (s *Server) handleMFADisable(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	attempt, ok := s.beginMFACheck(c)
+	if !ok {
+		return
+	}
+	err := s.mfaService.Disable(c.Request.Context(), c.GetInt("userID"), req.Code)
+	s.endMFACheck(c.Request.Context(), attempt, err)
+	if mfaError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable 2fa"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleMFARecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	attempt, ok := s.beginMFACheck(c)
+	if !ok {
+		return
+	}
+	codes, err := s.mfaService.RegenerateRecoveryCodes(c.Request.Context(), c.GetInt("userID"), req.Code)
+	s.endMFACheck(c.Request.Context(), attempt, err)
+	if mfaError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to regenerate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// handleLoginMFA exchanges the challenge handleLogin gave a 2FA user, plus
+// a TOTP or recovery code, for tokens. Wrong codes count towards the same
+// lockout as wrong passwords.
+func (s *Server) handleLoginMFA(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, err := s.mfaService.ChallengeUser(ctx, req.ChallengeToken)
+	if errors.Is(err, mfa.ErrInvalidChallenge) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+	user, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+
+	attempt := lockout.Attempt{Email: user.Email, UserID: &user.ID, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	if s.loginLocked(c, attempt) {
+		return
+	}
+	if user.Disabled() {
+		attempt.Outcome = lockout.OutcomeDisabled
+		s.recordLogin(ctx, attempt)
+		c.JSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+		return
+	}
+
+	_, err = s.mfaService.Redeem(ctx, req.ChallengeToken, req.Code)
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		attempt.Outcome = lockout.OutcomeInvalid
+		s.recordLogin(ctx, attempt)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, mfa.ErrInvalidChallenge) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+	s.completeLogin(c, user, attempt)
+}
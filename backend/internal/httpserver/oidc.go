@@ -0,0 +1,100 @@
+package httpserver
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/lockout"
+	"github.com/jamesfulreader/gostocks/internal/oidc"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// handleOIDCConfig tells the frontend whether to offer single sign-on.
+func (s *Server) handleOIDCConfig(c *gin.Context) {
+	if s.oidcService == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "password_login": s.passwordLogin})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "name": s.oidcName, "password_login": s.passwordLogin})
+}
+
+// handleOIDCLogin redirects the browser to the identity provider.
+func (s *Server) handleOIDCLogin(c *gin.Context) {
+	if s.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+		return
+	}
+	u, binding, err := s.oidcService.Begin(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start oidc login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+	s.setOIDCBinding(c, binding, int(s.oidcService.StateTTL.Seconds()))
+	c.Redirect(http.StatusFound, u)
+}
+
+// oidcBindingCookie ties a sign-in to the browser that began it. Lax lets
+// the top-level redirect to /api/oidc/login set it and keeps it off
+// cross-site POSTs to the callback.
+const oidcBindingCookie = "oidc_binding"
+
+func (s *Server) setOIDCBinding(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, value, maxAge, "/api/oidc", "", strings.HasPrefix(s.appBaseURL, "https://"), true)
+}
+
+// handleOIDCCallback takes the code and state the provider sent the
+// frontend's callback page and logs the user in as handleLogin would.
+func (s *Server) handleOIDCCallback(c *gin.Context) {
+	if s.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+		return
+	}
+	var req struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	// A missing cookie fails the binding check like a wrong one
+	binding, _ := c.Cookie(oidcBindingCookie)
+	s.setOIDCBinding(c, "", -1)
+
+	ctx := c.Request.Context()
+	user, err := s.oidcService.Complete(ctx, req.Code, req.State, binding)
+	switch {
+	case errors.Is(err, oidc.ErrInvalidState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, oidc.ErrEmailUnverified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, users.ErrUnverifiedAccount):
+		c.JSON(http.StatusConflict, gin.H{"error": "verify your email or reset your password before signing in with " + s.oidcName})
+		return
+	case errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidIDToken):
+		log.Printf("oidc login rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in failed"})
+		return
+	case err != nil:
+		log.Printf("oidc login failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	attempt := lockout.Attempt{Email: user.Email, UserID: &user.ID, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	if user.Disabled() {
+		attempt.Outcome = lockout.OutcomeDisabled
+		s.recordLogin(ctx, attempt)
+		c.JSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+		return
+	}
+	s.startLogin(c, user, attempt)
+}
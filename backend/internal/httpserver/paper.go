@@ -0,0 +1,132 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/paper"
+)
+
+// defaultStartingCash funds paper accounts opened without an explicit amount.
+const defaultStartingCash = 100000
+
+func (s *Server) handleOpenPaperAccount(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req struct {
+		StartingCash float64 `json:"starting_cash"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+	}
+	if req.StartingCash == 0 {
+		req.StartingCash = defaultStartingCash
+	}
+
+	acct, err := s.paperService.OpenAccount(c.Request.Context(), userID, req.StartingCash)
+	switch {
+	case errors.Is(err, paper.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, paper.ErrAccountExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open paper account"})
+	default:
+		c.JSON(http.StatusCreated, acct)
+	}
+}
+
+func (s *Server) handleGetPaperAccount(c *gin.Context) {
+	userID := c.GetInt("userID")
+	acct, err := s.paperService.Account(c.Request.Context(), userID)
+	if errors.Is(err, paper.ErrNoAccount) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get paper account"})
+		return
+	}
+	c.JSON(http.StatusOK, acct)
+}
+
+func (s *Server) handleListPaperOrders(c *gin.Context) {
+	userID := c.GetInt("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	orders, err := s.paperService.Orders(c.Request.Context(), userID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list orders"})
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+func (s *Server) handlePlacePaperOrder(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req paper.Order
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	order, err := s.paperService.PlaceOrder(c.Request.Context(), userID, req)
+	switch {
+	case errors.Is(err, paper.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, paper.ErrNoAccount):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, paper.ErrInsufficientCash), errors.Is(err, paper.ErrInsufficientShares):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "order": order})
+	case errors.Is(err, paper.ErrQuoteUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to place order"})
+	default:
+		c.JSON(http.StatusCreated, order)
+	}
+}
+
+func (s *Server) handleCancelPaperOrder(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err = s.paperService.CancelOrder(c.Request.Context(), userID, id)
+	if errors.Is(err, paper.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel order"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handlePaperPositions(c *gin.Context) {
+	userID := c.GetInt("userID")
+	positions, err := s.paperService.Positions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list positions"})
+		return
+	}
+	c.JSON(http.StatusOK, positions)
+}
+
+func (s *Server) handlePaperFills(c *gin.Context) {
+	userID := c.GetInt("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	fills, err := s.paperService.Fills(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list fills"})
+		return
+	}
+	c.JSON(http.StatusOK, fills)
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// PortfolioSnapshot is pushed to portfolio-following clients on every tick.
+// Portfolios do not track quantities yet, so values assume one share of each
+// symbol.
+type PortfolioSnapshot struct {
+	Value         float64  `json:"value"`
+	Change        float64  `json:"change"`
+	ChangePercent float64  `json:"changePercent"`
+	Symbols       int      `json:"symbols"`
+	Missing       []string `json:"missing,omitempty"`
+}
+
+func newPortfolioSnapshot(portfolio map[string]bool, quotes map[string]*stocks.Quote) PortfolioSnapshot {
+	snap := PortfolioSnapshot{Symbols: len(portfolio)}
+	for symbol := range portfolio {
+		q, ok := quotes[symbol]
+		if !ok {
+			snap.Missing = append(snap.Missing, symbol)
+			continue
+		}
+		snap.Value += q.Price
+		snap.Change += q.Change
+	}
+	if prev := snap.Value - snap.Change; prev > 0 {
+		snap.ChangePercent = snap.Change / prev * 100
+	}
+	sort.Strings(snap.Missing)
+	return snap
+}
+
+// subscribePortfolio subscribes client to every symbol in its user's
+// portfolio and keeps it in sync with later REST changes.
+func (s *Server) subscribePortfolio(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	symbols, err := s.userService.GetPortfolio(ctx, client.userID)
+	if err != nil {
+		log.Printf("websocket portfolio lookup for user %d: %v", client.userID, err)
+		s.subManager.direct <- clientMessage{client, WebSocketMessage{Action: "error", Payload: "failed to get portfolio"}}
+		return
+	}
+	s.subManager.followPortfolio <- portfolioFollow{client, symbols}
+	log.Printf("Client for user %d subscribed to portfolio (%d symbols)", client.userID, len(symbols))
+}
+
+// PortfolioChanged updates the subscriptions of every portfolio-following
+// connection owned by userID.
+func (sm *SubscriptionManager) PortfolioChanged(userID int, symbol string, added bool) {
+	sm.portfolioChange <- portfolioChange{userID: userID, symbol: symbol, added: added}
+}
+
+// applyPortfolioChange must be called with sm.mu held for writing.
+func (sm *SubscriptionManager) applyPortfolioChange(change portfolioChange) {
+	for client := range sm.clients {
+		if client.userID != change.userID || client.portfolio == nil {
+			continue
+		}
+		if change.added {
+			client.portfolio[change.symbol] = true
+			sm.addSubscription(client, change.symbol)
+		} else {
+			delete(client.portfolio, change.symbol)
+			sm.removeSubscription(client, change.symbol)
+		}
+	}
+}
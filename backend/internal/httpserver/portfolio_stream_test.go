@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// fakeUserRepo is an in-memory users.Repository holding only portfolios.
+type fakeUserRepo struct {
+	portfolios map[int][]string
+}
+
+func (f *fakeUserRepo) CreateUser(ctx context.Context, email, passwordHash, role string) (*users.User, error) {
+	return &users.User{ID: 1, Email: email, PasswordHash: passwordHash, Role: role}, nil
+}
+func (f *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) GetUserByID(ctx context.Context, id int) (*users.User, error) {
+	return &users.User{ID: id, Email: "user@example.com"}, nil
+}
+func (f *fakeUserRepo) ListUsers(ctx context.Context, limit, offset int) ([]users.User, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) SetRole(ctx context.Context, id int, role string) (*users.User, error) {
+	return nil, users.ErrUserNotFound
+}
+func (f *fakeUserRepo) SetDisabled(ctx context.Context, id int, disabled bool) (*users.User, error) {
+	return nil, users.ErrUserNotFound
+}
+func (f *fakeUserRepo) PromoteAdmins(ctx context.Context, emails []string) error {
+	return nil
+}
+func (f *fakeUserRepo) AddToPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	f.portfolios[ownerID] = append(f.portfolios[ownerID], symbol)
+	return true, nil
+}
+func (f *fakeUserRepo) GetPortfolio(ctx context.Context, actorID, ownerID int) ([]string, error) {
+	return f.portfolios[ownerID], nil
+}
+func (f *fakeUserRepo) RemoveFromPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	return false, nil
+}
+func (f *fakeUserRepo) PortfolioHistory(ctx context.Context, userID int) ([]users.PortfolioEvent, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) PortfolioRole(ctx context.Context, actorID, ownerID int) (string, error) {
+	return users.PortfolioOwner, nil
+}
+func (f *fakeUserRepo) SetPortfolioMember(ctx context.Context, ownerID, memberID int, role string) (*users.PortfolioMember, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) RemovePortfolioMember(ctx context.Context, ownerID, memberID int) error {
+	return nil
+}
+func (f *fakeUserRepo) PortfolioMembers(ctx context.Context, ownerID int) ([]users.PortfolioMember, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) SharedPortfolios(ctx context.Context, memberID int) ([]users.SharedPortfolio, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) MarkEmailVerified(ctx context.Context, id int, at time.Time) (*users.User, error) {
+	return nil, users.ErrUserNotFound
+}
+func (f *fakeUserRepo) CreateToken(ctx context.Context, t *users.Token) error {
+	return nil
+}
+func (f *fakeUserRepo) VerifyEmail(ctx context.Context, tokenHash []byte, now time.Time) (*users.User, error) {
+	return nil, users.ErrInvalidToken
+}
+func (f *fakeUserRepo) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string, now time.Time) (*users.User, error) {
+	return nil, users.ErrInvalidToken
+}
+
+func subscribedSymbols(sm *SubscriptionManager) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	out := make([]string, 0, len(sm.subscribers))
+	for sym := range sm.subscribers {
+		out = append(out, sym)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSubscribePortfolioFollowsRESTChanges(t *testing.T) {
+	s, url := newTestWebSocketServer(t, testConfig())
+	s.subManager.maxPerUser = 0
+	s.userService = users.NewService(&fakeUserRepo{portfolios: map[int][]string{
+		5: {"AAPL", "MSFT"},
+	}})
+
+	conn := dialAuthenticated(t, url, 5)
+	if err := conn.WriteJSON(WebSocketMessage{Action: "subscribe_portfolio"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitFor(t, time.Second, func() bool {
+		return equalStrings(subscribedSymbols(s.subManager), []string{"AAPL", "MSFT"})
+	})
+
+	s.subManager.PortfolioChanged(5, "TSLA", true)
+	s.subManager.PortfolioChanged(5, "AAPL", false)
+	// Another user's change must not leak into this connection.
+	s.subManager.PortfolioChanged(6, "GOOG", true)
+	waitFor(t, time.Second, func() bool {
+		return equalStrings(subscribedSymbols(s.subManager), []string{"MSFT", "TSLA"})
+	})
+
+	s.subManager.ticks <- map[string]*stocks.Quote{
+		"MSFT": {Symbol: "MSFT", Price: 300, Change: 6},
+		"TSLA": {Symbol: "TSLA", Price: 200, Change: -6},
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg struct {
+		Action  string            `json:"action"`
+		Payload PortfolioSnapshot `json:"payload"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.Action != "portfolio" || msg.Payload.Value != 500 || msg.Payload.Symbols != 2 {
+		t.Errorf("unexpected portfolio message: %+v", msg)
+	}
+}
+
+func TestNewPortfolioSnapshot(t *testing.T) {
+	portfolio := map[string]bool{"AAPL": true, "MSFT": true, "GONE": true}
+	quotes := map[string]*stocks.Quote{
+		"AAPL": {Price: 110, Change: 10},
+		"MSFT": {Price: 90, Change: -5},
+	}
+
+	snap := newPortfolioSnapshot(portfolio, quotes)
+	if snap.Value != 200 || snap.Change != 5 || snap.Symbols != 3 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+	if want := 5.0 / 195 * 100; math.Abs(snap.ChangePercent-want) > 1e-9 {
+		t.Errorf("expected change percent %f, got %f", want, snap.ChangePercent)
+	}
+	if len(snap.Missing) != 1 || snap.Missing[0] != "GONE" {
+		t.Errorf("expected GONE to be missing, got %v", snap.Missing)
+	}
+}
@@ -1,46 +1,222 @@
 package httpserver
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jamesfulreader/gostocks/internal/alerts"
+	"github.com/jamesfulreader/gostocks/internal/analytics"
+	"github.com/jamesfulreader/gostocks/internal/apikeys"
+	"github.com/jamesfulreader/gostocks/internal/audit"
 	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/backtest"
+	"github.com/jamesfulreader/gostocks/internal/digest"
+	"github.com/jamesfulreader/gostocks/internal/email"
+	"github.com/jamesfulreader/gostocks/internal/indicators"
+	"github.com/jamesfulreader/gostocks/internal/lockout"
+	"github.com/jamesfulreader/gostocks/internal/mfa"
+	"github.com/jamesfulreader/gostocks/internal/oidc"
+	"github.com/jamesfulreader/gostocks/internal/paper"
+	"github.com/jamesfulreader/gostocks/internal/sessions"
 	"github.com/jamesfulreader/gostocks/internal/stocks"
 	"github.com/jamesfulreader/gostocks/internal/users"
+	"github.com/jamesfulreader/gostocks/internal/webhooks"
+	"github.com/jamesfulreader/gostocks/pkg/config"
 )
 
 type Server struct {
-	addr        string
-	provider    stocks.Provider
-	router      *gin.Engine
-	subManager  *SubscriptionManager
-	userService *users.Service
+	addr             string
+	provider         stocks.Provider
+	router           *gin.Engine
+	subManager       *SubscriptionManager
+	userService      *users.Service
+	wsConfig         WebSocketConfig
+	tickerConfig     TickerConfig
+	allowedOrigins   []string
+	appBaseURL       string
+	upgrader         websocket.Upgrader
+	cluster          *Cluster
+	alertService     *alerts.Service
+	alertEvaluator   *alerts.Evaluator
+	webhookService   *webhooks.Service
+	digestService    *digest.Service
+	indicatorService *indicators.Service
+	analyticsService *analytics.Service
+	backtestService  *backtest.Service
+	paperService     *paper.Service
+	paperMatcher     *paper.Matcher
+	sessionService   *sessions.Service
+	apiKeyService    *apikeys.Service
+	loginGuard       *lockout.Guard
+	auditLog         *audit.Log
+	mfaService       *mfa.Service
+	oidcService      *oidc.Service
+	oidcName         string
+	passwordLogin    bool
+	mailer           *email.Mailer
+}
+
+// allowedOriginsFromEnv reads the comma-separated CORS_ALLOWED_ORIGINS list.
+// The same list gates browser WebSocket upgrades.
+func allowedOriginsFromEnv() []string {
+	var origins []string
+	for _, o := range strings.Split(config.GetenvDefault("CORS_ALLOWED_ORIGINS", "http://localhost:5173"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// trustedProxiesFromEnv reads the comma-separated TRUSTED_PROXIES list of
+// proxy IPs and CIDRs. Only they may set X-Forwarded-For or X-Real-IP;
+// otherwise clients could pick the IP that login lockouts, connection caps
+// and the audit log see.
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, p := range strings.Split(config.GetenvDefault("TRUSTED_PROXIES", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// adminEmailsFromEnv reads the comma-separated ADMIN_EMAILS list.
+func adminEmailsFromEnv() []string {
+	var emails []string
+	for _, e := range strings.Split(config.GetenvDefault("ADMIN_EMAILS", ""), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emails = append(emails, e)
+		}
+	}
+	return emails
 }
 
 func New(provider stocks.Provider, db *pgxpool.Pool, addr string) *Server {
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
+	if err := router.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	allowedOrigins := allowedOriginsFromEnv()
 
 	// CORS configuration
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:5173"}
-	config.AllowMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	router.Use(cors.New(config))
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = allowedOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", auth.APIKeyHeader, "Last-Event-ID"}
+	router.Use(cors.New(corsConfig))
 
 	userRepo := users.NewPostgresRepository(db)
 	userService := users.NewService(userRepo)
+	userService.AdminEmails = adminEmailsFromEnv()
+	userService.PasswordPolicy = passwordPolicyFromEnv()
+	userService.VerifyTokenTTL = config.GetenvDuration("EMAIL_VERIFY_TOKEN_TTL", userService.VerifyTokenTTL)
+	userService.ResetTokenTTL = config.GetenvDuration("PASSWORD_RESET_TOKEN_TTL", userService.ResetTokenTTL)
+	if err := userService.PromoteAdmins(context.Background()); err != nil {
+		log.Printf("Failed to promote admins: %v", err)
+	}
+
+	sessionService := sessions.NewService(sessions.NewPostgresRepository(db), userService)
+	sessionService.RefreshTTL = config.GetenvDuration("REFRESH_TOKEN_TTL", sessionService.RefreshTTL)
+	auth.SetDenylist(sessionService)
+	go sessionService.Run(context.Background())
+
+	apiKeyService := apikeys.NewService(apikeys.NewPostgresRepository(db), userService)
+	auth.SetAPIKeyVerifier(apiKeyService)
+
+	loginGuard := lockout.NewGuard(lockout.NewPostgresRepository(db))
+	loginGuard.AccountThreshold = config.GetenvInt("LOGIN_MAX_FAILURES", loginGuard.AccountThreshold)
+	loginGuard.IPThreshold = config.GetenvInt("LOGIN_MAX_FAILURES_PER_IP", loginGuard.IPThreshold)
+	loginGuard.BaseLockout = config.GetenvDuration("LOGIN_LOCKOUT_BASE", loginGuard.BaseLockout)
+	loginGuard.MaxLockout = config.GetenvDuration("LOGIN_LOCKOUT_MAX", loginGuard.MaxLockout)
 
+	alertRepo := alerts.NewPostgresRepository(db)
+	webhookRepo := webhooks.NewPostgresRepository(db)
+	digestRepo := digest.NewPostgresRepository(db)
+	backtestRepo := backtest.NewPostgresRepository(db)
+	paperRepo := paper.NewPostgresRepository(db)
+
+	wsConfig := WebSocketConfigFromEnv()
 	s := &Server{
-		addr:        addr,
-		provider:    provider,
-		router:      router,
-		subManager:  NewSubscriptionManager(),
-		userService: userService,
+		addr:             addr,
+		provider:         provider,
+		router:           router,
+		subManager:       NewSubscriptionManager(wsConfig.MaxSubscriptionsPerUser, wsConfig.MaxSubscriptionsPerIP),
+		userService:      userService,
+		wsConfig:         wsConfig,
+		tickerConfig:     TickerConfigFromEnv(),
+		allowedOrigins:   allowedOrigins,
+		appBaseURL:       strings.TrimSuffix(config.GetenvDefault("APP_BASE_URL", "http://localhost:5173"), "/"),
+		alertService:     alerts.NewService(alertRepo),
+		alertEvaluator:   alerts.NewEvaluator(alertRepo, provider),
+		webhookService:   webhooks.NewService(webhookRepo),
+		digestService:    digest.NewService(digestRepo),
+		backtestService:  backtest.NewService(backtestRepo),
+		paperService:     paper.NewService(paperRepo, provider),
+		indicatorService: indicators.NewService(provider, config.GetenvDuration("INDICATOR_CACHE_TTL", 5*time.Minute)),
+		sessionService:   sessionService,
+		apiKeyService:    apiKeyService,
+		loginGuard:       loginGuard,
+		auditLog:         audit.NewLog(audit.NewPostgresRepository(db)),
+		mfaService:       mfa.NewService(mfa.NewPostgresRepository(db)),
+		mailer:           email.NewMailerFromEnv(),
+	}
+	s.upgrader = s.newUpgrader()
+
+	// Single sign-on runs alongside passwords unless PASSWORD_LOGIN turns
+	// them off
+	if cfg := oidc.ConfigFromEnv(); cfg != nil {
+		s.oidcService = oidc.NewService(oidc.NewPostgresRepository(db), oidc.NewProvider(*cfg), userService)
+		s.oidcName = cfg.Name
 	}
+	s.passwordLogin = s.oidcService == nil || config.GetenvBool("PASSWORD_LOGIN", true)
+
+	s.analyticsService = analytics.NewService(provider, userService)
+	s.analyticsService.Benchmark = config.GetenvDefault("RISK_BENCHMARK", "SPY")
+	s.analyticsService.RiskFreeRate = config.GetenvFloat("RISK_FREE_RATE", 0)
+
+	// Share one upstream poller across replicas unless told to stay local
+	if db != nil && config.GetenvDefault("TICKER_FANOUT", "postgres") == "postgres" {
+		s.cluster = NewCluster(db, s.subManager)
+		s.cluster.Start(context.Background())
+		log.Println("Ticker fan-out via Postgres LISTEN/NOTIFY")
+	}
+
+	// Deliver fired alerts over the owner's WebSocket connections, webhooks and email
+	s.alertEvaluator.OnFire(func(ctx context.Context, f alerts.Firing) {
+		s.notifyUser(f.UserID, WebSocketMessage{Action: "alert", Symbol: f.Symbol, Payload: f})
+		s.publishWebhook(ctx, f.UserID, webhooks.EventAlertFired, f)
+		s.emailUser(f.UserID, email.TemplateAlert, f)
+	})
+	go s.alertEvaluator.Run(context.Background())
+	go webhooks.NewDispatcher(webhookRepo, nil).Run(context.Background())
+
+	// Build end-of-day portfolio digests and email them out
+	digestJob := digest.NewJob(digestRepo, userService, provider)
+	digestJob.OnDigest(func(ctx context.Context, r digest.Recipient, d *digest.Digest) {
+		s.sendEmail(r.Email, email.TemplateDigest, d)
+	})
+	go digestJob.Run(context.Background())
+
+	go backtest.NewRunner(backtestRepo, provider).Run(context.Background())
+
+	// Fill resting paper orders from ticker prices and tell the owner
+	s.paperService.OnFill(func(ctx context.Context, f *paper.Fill) {
+		s.notifyUser(f.UserID, WebSocketMessage{Action: "fill", Symbol: f.Symbol, Payload: f})
+	})
+	s.paperMatcher = paper.NewMatcher(s.paperService)
+	go s.paperMatcher.Run(context.Background())
 
 	// Start subscription manager and ticker
 	go s.subManager.Run()
@@ -50,29 +226,129 @@ func New(provider stocks.Provider, db *pgxpool.Pool, addr string) *Server {
 	return s
 }
 
+// apiKeyScopes lists the protected routes scripts may call with an API key
+// and the scope each needs. Everything else requires a logged-in user.
+var apiKeyScopes = auth.ScopePolicy{
+	"GET /api/quote":                 auth.ScopeReadQuotes,
+	"GET /api/intraday":              auth.ScopeReadQuotes,
+	"GET /api/indicators":            auth.ScopeReadQuotes,
+	"GET /api/stream":                auth.ScopeReadQuotes,
+	"GET /api/portfolio":             auth.ScopeReadPortfolio,
+	"GET /api/portfolio/risk":        auth.ScopeReadPortfolio,
+	"GET /api/portfolio/performance": auth.ScopeReadPortfolio,
+	"GET /api/portfolio/history":     auth.ScopeReadPortfolio,
+	"GET /api/portfolio/shared":      auth.ScopeReadPortfolio,
+	"GET /api/digests":               auth.ScopeReadPortfolio,
+	"POST /api/portfolio":            auth.ScopeWritePortfolio,
+	"DELETE /api/portfolio":          auth.ScopeWritePortfolio,
+}
+
 func (s *Server) routes() {
 	s.router.GET("/healthz", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
 
+	s.router.GET("/metrics", handleMetrics)
+
 	s.router.GET("/ws", s.handleWebSocket)
 
+	// Public keys for services verifying our tokens
+	s.router.GET("/.well-known/jwks.json", s.handleJWKS)
+
 	api := s.router.Group("/api")
+	api.Use(s.auditMiddleware())
 	{
-		api.GET("/quote", s.handleQuote)
-		api.GET("/intraday", s.handleIntraday)
+		// Public, but API keys used on them need read:quotes
+		quotes := api.Group("/", auth.OptionalAPIKey(apiKeyScopes))
+		quotes.GET("/quote", s.handleQuote)
+		quotes.GET("/intraday", s.handleIntraday)
+		quotes.GET("/indicators", s.handleIndicators)
+		api.GET("/stream", s.handleStream)
+		api.GET("/market/status", s.handleMarketStatus)
 
 		// Auth routes
-		api.POST("/register", s.handleRegister)
-		api.POST("/login", s.handleLogin)
+		if s.passwordLogin {
+			api.POST("/register", s.handleRegister)
+			api.POST("/login", s.handleLogin)
+			api.POST("/password/forgot", s.handleForgotPassword)
+			api.POST("/password/reset", s.handleResetPassword)
+		}
+		api.GET("/oidc/config", s.handleOIDCConfig)
+		api.GET("/oidc/login", s.handleOIDCLogin)
+		api.POST("/oidc/callback", s.handleOIDCCallback)
+		api.POST("/login/2fa", s.handleLoginMFA)
+		api.POST("/refresh", s.handleRefresh)
+		api.POST("/verify-email", s.handleVerifyEmail)
 
 		// Protected routes
 		protected := api.Group("/")
-		protected.Use(auth.AuthMiddleware())
+		protected.Use(auth.AuthMiddleware(apiKeyScopes))
 		{
+			protected.POST("/verify-email/resend", s.handleResendVerification)
+			protected.POST("/logout", s.handleLogout)
+			protected.POST("/logout/all", s.handleLogoutAll)
+			protected.GET("/sessions", s.handleListSessions)
+			protected.DELETE("/sessions", s.handleRevokeSession)
+
+			protected.GET("/2fa", s.handleMFAStatus)
+			protected.POST("/2fa/enroll", s.handleMFAEnroll)
+			protected.POST("/2fa/confirm", s.handleMFAConfirm)
+			protected.POST("/2fa/disable", s.handleMFADisable)
+			protected.POST("/2fa/recovery-codes", s.handleMFARecoveryCodes)
+
+			protected.GET("/api-keys", s.handleListAPIKeys)
+			protected.POST("/api-keys", s.handleCreateAPIKey)
+			protected.DELETE("/api-keys", s.handleDeleteAPIKey)
+
 			protected.GET("/portfolio", s.handleGetPortfolio)
 			protected.POST("/portfolio", s.handleAddToPortfolio)
 			protected.DELETE("/portfolio", s.handleRemoveFromPortfolio)
+			protected.GET("/portfolio/history", s.handlePortfolioHistory)
+			protected.GET("/portfolio/members", s.handleListPortfolioMembers)
+			protected.POST("/portfolio/members", s.handleSharePortfolio)
+			protected.DELETE("/portfolio/members", s.handleUnsharePortfolio)
+			protected.GET("/portfolio/shared", s.handleSharedPortfolios)
+			protected.DELETE("/portfolio/shared", s.handleLeavePortfolio)
+			protected.GET("/portfolio/risk", s.handlePortfolioRisk)
+			protected.GET("/portfolio/performance", s.handlePortfolioPerformance)
+
+			protected.GET("/alerts", s.handleListAlerts)
+			protected.POST("/alerts", s.handleCreateAlert)
+			protected.DELETE("/alerts", s.handleDeleteAlert)
+			protected.GET("/alerts/history", s.handleAlertHistory)
+
+			protected.GET("/digests", s.handleListDigests)
+
+			protected.GET("/backtests", s.handleListBacktests)
+			protected.POST("/backtests", s.handleCreateBacktest)
+			protected.GET("/backtests/result", s.handleGetBacktest)
+
+			protected.GET("/paper/account", s.handleGetPaperAccount)
+			protected.POST("/paper/account", s.handleOpenPaperAccount)
+			protected.GET("/paper/orders", s.handleListPaperOrders)
+			protected.POST("/paper/orders", s.handlePlacePaperOrder)
+			protected.DELETE("/paper/orders", s.handleCancelPaperOrder)
+			protected.GET("/paper/positions", s.handlePaperPositions)
+			protected.GET("/paper/fills", s.handlePaperFills)
+
+			protected.GET("/webhooks", s.handleListWebhooks)
+			protected.POST("/webhooks", s.handleCreateWebhook)
+			protected.DELETE("/webhooks", s.handleDeleteWebhook)
+			protected.GET("/webhooks/deliveries", s.handleWebhookDeliveries)
+			protected.POST("/webhooks/deliveries/retry", s.handleRetryWebhookDelivery)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		admin.Use(auth.AuthMiddleware(nil), auth.RequireRole(auth.RoleAdmin))
+		{
+			admin.GET("/users", s.handleAdminListUsers)
+			admin.POST("/users/role", s.handleAdminSetRole)
+			admin.POST("/users/disable", s.handleAdminDisableUser)
+			admin.POST("/users/enable", s.handleAdminEnableUser)
+			admin.GET("/providers", s.handleAdminProviders)
+			admin.POST("/cache/purge", s.handleAdminPurgeCaches)
+			admin.GET("/audit", s.handleAdminAudit)
 		}
 	}
 }
@@ -125,12 +401,30 @@ func (s *Server) handleRegister(c *gin.Context) {
 		return
 	}
 
-	user, err := s.userService.Register(c.Request.Context(), req.Email, req.Password)
-	if err != nil {
+	ctx := c.Request.Context()
+	auditDetail(c, "email", users.NormalizeEmail(req.Email))
+	user, err := s.userService.Register(ctx, req.Email, req.Password)
+	switch {
+	case errors.Is(err, users.ErrInvalidEmail), errors.Is(err, users.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, users.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register"})
 		return
 	}
 
+	auditActor(c, user.ID)
+
+	// The welcome email follows once the address is confirmed
+	issued, err := s.userService.RequestVerification(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to issue verification token for user %d: %v", user.ID, err)
+	} else if issued != nil {
+		s.sendTokenEmail(issued, email.TemplateVerifyEmail, "/verify-email")
+	}
 	c.JSON(http.StatusOK, user)
 }
 
@@ -144,24 +438,126 @@ func (s *Server) handleLogin(c *gin.Context) {
 		return
 	}
 
-	user, err := s.userService.Login(c.Request.Context(), req.Email, req.Password)
-	if err != nil {
+	ctx := c.Request.Context()
+	attempt := lockout.Attempt{Email: req.Email, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	auditDetail(c, "email", lockout.NormalizeEmail(req.Email))
+
+	// Locked out emails get the same answer whether or not they exist
+	if s.loginLocked(c, attempt) {
+		return
+	}
+
+	user, err := s.userService.Login(ctx, req.Email, req.Password)
+	if errors.Is(err, users.ErrUserDisabled) {
+		attempt.Outcome = lockout.OutcomeDisabled
+		s.recordLogin(ctx, attempt)
+		c.JSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+		return
+	}
+	if errors.Is(err, users.ErrInvalidCredentials) {
+		attempt.Outcome = lockout.OutcomeInvalid
+		s.recordLogin(ctx, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+	attempt.UserID = &user.ID
+	s.startLogin(c, user, attempt)
+}
 
-	token, err := auth.GenerateToken(user.ID)
+// startLogin finishes a first-factor login: users with 2FA get a challenge
+// for handleLoginMFA, everyone else a session.
+func (s *Server) startLogin(c *gin.Context, user *users.User, attempt lockout.Attempt) {
+	ctx := c.Request.Context()
+	enabled, err := s.mfaService.Enabled(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+	if enabled {
+		token, expiresAt, err := s.mfaService.Challenge(ctx, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+			return
+		}
+		attempt.Outcome = lockout.OutcomeMFARequired
+		s.recordLogin(ctx, attempt)
+		auditDetail(c, "mfa_required", true)
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":    true,
+			"challenge_token": token,
+			"expires_in":      int(time.Until(expiresAt).Seconds()),
+		})
+		return
+	}
+
+	s.completeLogin(c, user, attempt)
+}
+
+// completeLogin records a successful login and starts a session for user.
+func (s *Server) completeLogin(c *gin.Context, user *users.User, attempt lockout.Attempt) {
+	attempt.Outcome = lockout.OutcomeSuccess
+	s.recordLogin(c.Request.Context(), attempt)
+	auditActor(c, user.ID)
+
+	tokens, err := s.sessionService.Start(c.Request.Context(), user, sessionClient(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
+	})
+}
+
+// loginLocked answers the request and returns true if attempt's account or
+// IP is locked out.
+func (s *Server) loginLocked(c *gin.Context, attempt lockout.Attempt) bool {
+	ctx := c.Request.Context()
+	wait, err := s.loginGuard.Check(ctx, attempt.Email, attempt.IP)
+	if errors.Is(err, lockout.ErrLocked) {
+		attempt.Outcome = lockout.OutcomeLocked
+		s.recordLogin(ctx, attempt)
+		c.Header("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+		return true
+	}
+	if err != nil {
+		log.Printf("Failed to check login lockout: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "login unavailable"})
+		return true
+	}
+	return false
+}
+
+func (s *Server) recordLogin(ctx context.Context, a lockout.Attempt) {
+	if err := s.loginGuard.Record(ctx, a); err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", a.Email, err)
+	}
+}
+
+func (s *Server) handleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.Keys().JWKS())
 }
 
 func (s *Server) handleGetPortfolio(c *gin.Context) {
-	userID := c.GetInt("userID")
-	portfolio, err := s.userService.GetPortfolio(c.Request.Context(), userID)
+	ownerID, ok := portfolioOwner(c)
+	if !ok {
+		return
+	}
+	portfolio, err := s.userService.ViewPortfolio(c.Request.Context(), c.GetInt("userID"), ownerID)
+	if portfolioError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get portfolio"})
 		return
@@ -171,6 +567,10 @@ func (s *Server) handleGetPortfolio(c *gin.Context) {
 
 func (s *Server) handleAddToPortfolio(c *gin.Context) {
 	userID := c.GetInt("userID")
+	ownerID, ok := portfolioOwner(c)
+	if !ok {
+		return
+	}
 	var req struct {
 		Symbol string `json:"symbol"`
 	}
@@ -179,24 +579,48 @@ func (s *Server) handleAddToPortfolio(c *gin.Context) {
 		return
 	}
 
-	if err := s.userService.AddToPortfolio(c.Request.Context(), userID, req.Symbol); err != nil {
+	auditDetail(c, "symbol", req.Symbol)
+	auditDetail(c, "owner_id", ownerID)
+	added, err := s.userService.AddToPortfolio(c.Request.Context(), userID, ownerID, req.Symbol)
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add to portfolio"})
 		return
 	}
+	if added {
+		s.portfolioChanged(ownerID, req.Symbol, true)
+		s.publishWebhook(c.Request.Context(), ownerID, webhooks.EventPortfolioAdded, gin.H{"symbol": req.Symbol, "actor_id": userID})
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
 func (s *Server) handleRemoveFromPortfolio(c *gin.Context) {
 	userID := c.GetInt("userID")
+	ownerID, ok := portfolioOwner(c)
+	if !ok {
+		return
+	}
 	symbol := c.Query("symbol")
 	if symbol == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing symbol"})
 		return
 	}
 
-	if err := s.userService.RemoveFromPortfolio(c.Request.Context(), userID, symbol); err != nil {
+	auditDetail(c, "symbol", symbol)
+	auditDetail(c, "owner_id", ownerID)
+	removed, err := s.userService.RemoveFromPortfolio(c.Request.Context(), userID, ownerID, symbol)
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove from portfolio"})
 		return
 	}
+	if removed {
+		s.portfolioChanged(ownerID, symbol, false)
+		s.publishWebhook(c.Request.Context(), ownerID, webhooks.EventPortfolioRemoved, gin.H{"symbol": symbol, "actor_id": userID})
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
@@ -0,0 +1,88 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/sessions"
+)
+
+func sessionClient(c *gin.Context) sessions.Client {
+	return sessions.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// requestClaims returns the claims AuthMiddleware stored for the request.
+func requestClaims(c *gin.Context) *auth.Claims {
+	claims, _ := c.MustGet("claims").(*auth.Claims)
+	return claims
+}
+
+func (s *Server) handleRefresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	tokens, err := s.sessionService.Refresh(c.Request.Context(), req.RefreshToken, sessionClient(c))
+	if errors.Is(err, sessions.ErrInvalidToken) || errors.Is(err, sessions.ErrTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *Server) handleLogout(c *gin.Context) {
+	if err := s.sessionService.Logout(c.Request.Context(), requestClaims(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleLogoutAll(c *gin.Context) {
+	userID := c.GetInt("userID")
+	if err := s.sessionService.LogoutAll(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleListSessions(c *gin.Context) {
+	userID := c.GetInt("userID")
+	list, err := s.sessionService.List(c.Request.Context(), userID, requestClaims(c).SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (s *Server) handleRevokeSession(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err := s.sessionService.RevokeSession(c.Request.Context(), userID, id)
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,148 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// portfolioOwner reads whose portfolio a request is about from ?owner=,
+// defaulting to the caller's own. It answers the request and returns false
+// if the parameter is malformed.
+func portfolioOwner(c *gin.Context) (int, bool) {
+	owner := c.Query("owner")
+	if owner == "" {
+		return c.GetInt("userID"), true
+	}
+	id, err := strconv.Atoi(owner)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner"})
+		return 0, false
+	}
+	return id, true
+}
+
+// portfolioError maps portfolio access errors to responses, returning false
+// for unexpected ones so the caller can pick its own 500 message.
+func portfolioError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, users.ErrPortfolioNotFound), errors.Is(err, users.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, users.ErrPortfolioReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, users.ErrInvalidMember):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *Server) handlePortfolioHistory(c *gin.Context) {
+	ownerID, ok := portfolioOwner(c)
+	if !ok {
+		return
+	}
+	events, err := s.userService.ViewPortfolioHistory(c.Request.Context(), c.GetInt("userID"), ownerID)
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get portfolio history"})
+		return
+	}
+	if events == nil {
+		events = []users.PortfolioEvent{}
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+func (s *Server) handleListPortfolioMembers(c *gin.Context) {
+	members, err := s.userService.PortfolioMembers(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list portfolio members"})
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// handleSharePortfolio shares the caller's portfolio with another user, or
+// changes their role. Unknown emails get the same answer as known ones so
+// the endpoint can't be used to find out who has an account.
+func (s *Server) handleSharePortfolio(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	auditDetail(c, "email", users.NormalizeEmail(req.Email))
+	auditDetail(c, "role", req.Role)
+	_, err := s.userService.SharePortfolio(c.Request.Context(), c.GetInt("userID"), req.Email, req.Role)
+	if errors.Is(err, users.ErrUserNotFound) {
+		err = nil
+	}
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to share portfolio"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleUnsharePortfolio(c *gin.Context) {
+	memberID, err := strconv.Atoi(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	auditDetail(c, "member_id", memberID)
+	err = s.userService.UnsharePortfolio(c.Request.Context(), c.GetInt("userID"), memberID)
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove portfolio member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleSharedPortfolios lists the portfolios others have shared with the
+// caller.
+func (s *Server) handleSharedPortfolios(c *gin.Context) {
+	shared, err := s.userService.SharedPortfolios(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list shared portfolios"})
+		return
+	}
+	c.JSON(http.StatusOK, shared)
+}
+
+// handleLeavePortfolio removes the caller from a portfolio shared with
+// them.
+func (s *Server) handleLeavePortfolio(c *gin.Context) {
+	ownerID, err := strconv.Atoi(c.Query("owner"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner"})
+		return
+	}
+	auditDetail(c, "owner_id", ownerID)
+	err = s.userService.UnsharePortfolio(c.Request.Context(), ownerID, c.GetInt("userID"))
+	if portfolioError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to leave portfolio"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// shareUserRepo knows a single account besides the caller.
+type shareUserRepo struct {
+	fakeUserRepo
+}
+
+func (r *shareUserRepo) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	if email != "friend@example.com" {
+		return nil, users.ErrUserNotFound
+	}
+	return &users.User{ID: 2, Email: email}, nil
+}
+
+func (r *shareUserRepo) SetPortfolioMember(ctx context.Context, ownerID, memberID int, role string) (*users.PortfolioMember, error) {
+	return &users.PortfolioMember{UserID: memberID, Email: "friend@example.com", Role: role}, nil
+}
+
+func TestSharePortfolioHidesUnknownEmails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{userService: users.NewService(&shareUserRepo{})}
+	router := gin.New()
+	router.POST("/api/portfolio/members", func(c *gin.Context) { c.Set("userID", 1) }, s.handleSharePortfolio)
+
+	share := func(email string) *httptest.ResponseRecorder {
+		body := `{"email":"` + email + `","role":"viewer"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/portfolio/members", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	known, unknown := share("friend@example.com"), share("nobody@example.com")
+	if known.Code != http.StatusOK || unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Errorf("known email: %d %s; unknown email: %d %s; want identical responses",
+			known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+}
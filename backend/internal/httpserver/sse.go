@@ -0,0 +1,197 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// resumeRequest subscribes an SSE client and replays any history it missed,
+// as one step so no update is lost or duplicated in between.
+type resumeRequest struct {
+	client  *Client
+	symbols []string
+	since   uint64
+}
+
+// attach must be called with sm.mu held for writing.
+func (sm *SubscriptionManager) attach(req resumeRequest) {
+	for _, symbol := range req.symbols {
+		sm.addSubscription(req.client, symbol)
+	}
+	if req.since == 0 {
+		return
+	}
+	// An id this replica can't account for, e.g. from before a restart or
+	// older than its history, gets the latest update per symbol instead of
+	// a replay with gaps.
+	if len(sm.history) == 0 || req.since+1 < sm.history[0].ID || req.since > sm.seq {
+		sm.resync(req.client)
+		return
+	}
+	for _, msg := range sm.history {
+		if msg.ID <= req.since {
+			continue
+		}
+		if msg.Symbol == "" || req.client.symbols[msg.Symbol] {
+			sm.deliver(req.client, msg)
+		}
+	}
+}
+
+// resync sends client the latest update for each symbol it follows. It
+// must be called with sm.mu held for writing.
+func (sm *SubscriptionManager) resync(client *Client) {
+	seen := make(map[string]bool)
+	for i := len(sm.history) - 1; i >= 0; i-- {
+		msg := sm.history[i]
+		if msg.Symbol != "" && client.symbols[msg.Symbol] && !seen[msg.Symbol] {
+			seen[msg.Symbol] = true
+			sm.deliver(client, msg)
+		}
+	}
+}
+
+// parseSymbols splits a comma-separated list into unique normalized
+// symbols, dropping invalid ones.
+func parseSymbols(raw string) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, sym := range strings.Split(raw, ",") {
+		sym, ok := stocks.NormalizeSymbol(sym)
+		if ok && !seen[sym] {
+			seen[sym] = true
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// streamToken reads the JWT from the Authorization header or, for
+// EventSource clients that cannot set headers, the token query parameter.
+func streamToken(c *gin.Context) string {
+	if parts := strings.Fields(c.GetHeader("Authorization")); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		return parts[1]
+	}
+	return c.Query("token")
+}
+
+// streamUser authenticates a stream request by JWT or by an API key with
+// the read:quotes scope, returning the user ID.
+func streamUser(c *gin.Context) (int, bool) {
+	if key := c.GetHeader(auth.APIKeyHeader); key != "" {
+		principal, err := auth.AuthenticateAPIKey(c.Request.Context(), key)
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return 0, false
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify API key"})
+			return 0, false
+		}
+		if !principal.HasScope(auth.ScopeReadQuotes) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + auth.ScopeReadQuotes})
+			return 0, false
+		}
+		return principal.UserID, true
+	}
+	claims, err := auth.Authenticate(c.Request.Context(), streamToken(c))
+	if errors.Is(err, auth.ErrDenylistUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify token"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// handleStream serves the same updates as /ws over Server-Sent Events.
+// Clients pick symbols with ?symbols=AAPL,MSFT and may resume after a
+// disconnect with the Last-Event-ID header (or ?lastEventId=).
+func (s *Server) handleStream(c *gin.Context) {
+	userID, ok := streamUser(c)
+	if !ok {
+		return
+	}
+	symbols := parseSymbols(c.Query("symbols"))
+	if len(symbols) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing symbols"})
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+	since, _ := strconv.ParseUint(lastID, 10, 64)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.Flush(); err != nil {
+		log.Printf("sse flush unsupported: %v", err)
+		return
+	}
+
+	client := newClient(nil, s.wsConfig.SendBuffer, userID, c.ClientIP())
+	s.subManager.register <- client
+	defer func() {
+		s.subManager.unregister <- client
+	}()
+	s.subManager.resume <- resumeRequest{client: client, symbols: symbols, since: since}
+
+	heartbeat := time.NewTicker(s.wsConfig.SSEHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case msg, ok := <-client.send:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(s.wsConfig.WriteTimeout))
+			if err := writeEvent(c.Writer, msg); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(s.wsConfig.WriteTimeout))
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, msg WebSocketMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if msg.ID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", msg.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Action, data)
+	return err
+}
@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+func newTestStreamServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := testConfig()
+	cfg.SSEHeartbeat = 50 * time.Millisecond
+	s := &Server{
+		router:     gin.New(),
+		subManager: NewSubscriptionManager(0, 0),
+		wsConfig:   cfg,
+	}
+	go s.subManager.Run()
+	s.router.GET("/api/stream", s.handleStream)
+
+	ts := httptest.NewServer(s.router)
+	t.Cleanup(ts.Close)
+	return s, ts.URL + "/api/stream"
+}
+
+func openStream(t *testing.T, url, lastEventID string) (*bufio.Reader, func()) {
+	t.Helper()
+	token, _ := auth.GenerateToken(3)
+	req, _ := http.NewRequest(http.MethodGet, url+"?symbols=aapl,MSFT", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+}
+
+// readEvent returns the next event's fields, skipping heartbeat comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	event := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(event) > 0 {
+				return event
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			event[k] = v
+		}
+	}
+}
+
+func TestStreamDeliversUpdatesAndResumes(t *testing.T) {
+	s, url := newTestStreamServer(t)
+
+	r, closeStream := openStream(t, url, "")
+	waitFor(t, time.Second, func() bool {
+		return equalStrings(subscribedSymbols(s.subManager), []string{"AAPL", "MSFT"})
+	})
+
+	s.subManager.broadcast <- WebSocketMessage{Action: "update", Symbol: "AAPL", Payload: 1}
+	first := readEvent(t, r)
+	if first["event"] != "update" || first["id"] == "" || !strings.Contains(first["data"], `"symbol":"AAPL"`) {
+		t.Fatalf("unexpected event: %v", first)
+	}
+	closeStream()
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 0 })
+
+	// Updates published while disconnected are replayed on resume.
+	s.subManager.broadcast <- WebSocketMessage{Action: "update", Symbol: "TSLA", Payload: 2}
+	s.subManager.broadcast <- WebSocketMessage{Action: "update", Symbol: "MSFT", Payload: 3}
+
+	r, closeStream = openStream(t, url, first["id"])
+	defer closeStream()
+	replayed := readEvent(t, r)
+	if !strings.Contains(replayed["data"], `"symbol":"MSFT"`) {
+		t.Errorf("expected MSFT to be replayed, got %v", replayed)
+	}
+}
+
+func TestStreamResyncsUnknownEventIDs(t *testing.T) {
+	s, url := newTestStreamServer(t)
+
+	// Numbered by the cluster, as if relayed from another replica
+	s.subManager.broadcast <- WebSocketMessage{ID: 100, Action: "update", Symbol: "AAPL", Payload: 1}
+	s.subManager.broadcast <- WebSocketMessage{ID: 101, Action: "update", Symbol: "AAPL", Payload: 2}
+	s.subManager.broadcast <- WebSocketMessage{ID: 102, Action: "update", Symbol: "TSLA", Payload: 3}
+
+	// An id older than this replica's history gets the latest state, not
+	// a replay that silently skips what came before
+	r, closeStream := openStream(t, url, "7")
+	defer closeStream()
+	event := readEvent(t, r)
+	if event["id"] != "101" || !strings.Contains(event["data"], `"symbol":"AAPL"`) {
+		t.Errorf("expected the latest AAPL update, got %v", event)
+	}
+
+	// Local numbering carries on from the cluster's
+	s.subManager.broadcast <- WebSocketMessage{Action: "update", Symbol: "MSFT", Payload: 4}
+	if event := readEvent(t, r); event["id"] != "103" {
+		t.Errorf("expected id 103, got %v", event)
+	}
+}
+
+func TestStreamSendsHeartbeats(t *testing.T) {
+	_, url := newTestStreamServer(t)
+	r, closeStream := openStream(t, url, "")
+	defer closeStream()
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.HasPrefix(line, ": heartbeat") {
+		t.Errorf("expected heartbeat comment, got %q", line)
+	}
+}
+
+func TestStreamRequiresToken(t *testing.T) {
+	_, url := newTestStreamServer(t)
+	resp, err := http.Get(url + "?symbols=AAPL")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,109 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jamesfulreader/gostocks/internal/webhooks"
+)
+
+// publishWebhook queues event for the user's webhooks. Failures are logged
+// rather than returned so they never fail the action that caused them.
+func (s *Server) publishWebhook(ctx context.Context, userID int, event string, data any) {
+	if s.webhookService == nil {
+		return
+	}
+	if err := s.webhookService.Publish(ctx, userID, event, data); err != nil {
+		log.Printf("webhooks: publish %s for user %d: %v", event, userID, err)
+	}
+}
+
+func (s *Server) handleListWebhooks(c *gin.Context) {
+	userID := c.GetInt("userID")
+	hooks, err := s.webhookService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (s *Server) handleCreateWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	hook, err := s.webhookService.Register(c.Request.Context(), userID, req.URL, req.Events)
+	if errors.Is(err, webhooks.ErrInvalidWebhook) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err = s.webhookService.Delete(c.Request.Context(), userID, id)
+	if errors.Is(err, webhooks.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleWebhookDeliveries(c *gin.Context) {
+	userID := c.GetInt("userID")
+	webhookID, _ := strconv.Atoi(c.Query("webhook_id"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	deliveries, err := s.webhookService.Deliveries(c.Request.Context(), userID, webhookID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (s *Server) handleRetryWebhookDelivery(c *gin.Context) {
+	userID := c.GetInt("userID")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+
+	err = s.webhookService.Retry(c.Request.Context(), userID, id)
+	if errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retry delivery"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -2,159 +2,722 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+	"github.com/jamesfulreader/gostocks/pkg/config"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for now
-	},
+// bearerSubprotocol lets browsers, which cannot set headers on a WebSocket
+// handshake, pass the JWT as Sec-WebSocket-Protocol: bearer, <token>.
+const bearerSubprotocol = "bearer"
+
+func (s *Server) newUpgrader() websocket.Upgrader {
+	return websocket.Upgrader{
+		CheckOrigin:  s.checkOrigin,
+		Subprotocols: []string{bearerSubprotocol},
+	}
 }
 
+// checkOrigin allows the same origins as CORS. Requests without an Origin
+// header come from non-browser clients and still have to authenticate.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	log.Printf("websocket origin %q rejected", origin)
+	wsMetrics.Add(metricOriginRejected, 1)
+	return false
+}
+
+// historySize is how many broadcast messages are kept for SSE resume.
+const historySize = 512
+
 type WebSocketMessage struct {
+	// ID is assigned to broadcast messages in order and doubles as the SSE
+	// event id used for Last-Event-ID resume. Clustered replicas take it
+	// from a Postgres sequence so it survives reconnecting elsewhere.
+	ID      uint64      `json:"id,omitempty"`
 	Action  string      `json:"action"`
 	Symbol  string      `json:"symbol,omitempty"`
+	Token   string      `json:"token,omitempty"`
 	Payload interface{} `json:"payload,omitempty"`
 }
 
-type SubscriptionManager struct {
-	clients     map[*websocket.Conn]bool
-	subscribers map[string]map[*websocket.Conn]bool
-	broadcast   chan WebSocketMessage
-	register    chan *websocket.Conn
-	unregister  chan *websocket.Conn
-	subscribe   chan struct {
-		conn   *websocket.Conn
-		symbol string
+// WebSocketConfig controls keepalives and limits for /ws connections.
+type WebSocketConfig struct {
+	// ReadTimeout is how long a connection may stay silent (no message and
+	// no pong) before it is considered dead and reaped.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds a single write, including pings.
+	WriteTimeout time.Duration
+	// PingInterval must be shorter than ReadTimeout so a healthy peer can
+	// answer before its deadline expires.
+	PingInterval time.Duration
+	// MaxMessageSize is the largest inbound message accepted, in bytes.
+	MaxMessageSize int64
+	// SendBuffer is the number of outbound messages queued per client before
+	// it is treated as a slow consumer and dropped.
+	SendBuffer int
+	// AuthTimeout is how long a connection that did not present a token in
+	// the handshake has to send an "auth" message.
+	AuthTimeout time.Duration
+	// MaxSubscriptionsPerUser and MaxSubscriptionsPerIP cap symbol
+	// subscriptions summed over all of a user's (or address's) connections.
+	MaxSubscriptionsPerUser int
+	MaxSubscriptionsPerIP   int
+	// SSEHeartbeat is how often /api/stream writes a comment line so proxies
+	// keep the response open.
+	SSEHeartbeat time.Duration
+}
+
+// minStreamTimeout is the shortest stream timeout or interval accepted
+// from the environment. Tickers panic on zero and negative periods.
+const minStreamTimeout = time.Second
+
+// streamTimeoutFromEnv reads key, falling back to def for values below
+// minStreamTimeout.
+func streamTimeoutFromEnv(key string, def time.Duration) time.Duration {
+	d := config.GetenvDuration(key, def)
+	if d < minStreamTimeout {
+		log.Printf("%s=%v is shorter than %v, using %v", key, d, minStreamTimeout, def)
+		return def
+	}
+	return d
+}
+
+// WebSocketConfigFromEnv reads the WS_* environment variables, falling back
+// to sensible defaults.
+func WebSocketConfigFromEnv() WebSocketConfig {
+	readTimeout := streamTimeoutFromEnv("WS_READ_TIMEOUT", 60*time.Second)
+	return WebSocketConfig{
+		ReadTimeout:             readTimeout,
+		WriteTimeout:            streamTimeoutFromEnv("WS_WRITE_TIMEOUT", 10*time.Second),
+		PingInterval:            readTimeout * 9 / 10,
+		MaxMessageSize:          int64(config.GetenvInt("WS_MAX_MESSAGE_SIZE", 4096)),
+		SendBuffer:              64,
+		AuthTimeout:             streamTimeoutFromEnv("WS_AUTH_TIMEOUT", 10*time.Second),
+		MaxSubscriptionsPerUser: config.GetenvInt("WS_MAX_SUBSCRIPTIONS_PER_USER", 50),
+		MaxSubscriptionsPerIP:   config.GetenvInt("WS_MAX_SUBSCRIPTIONS_PER_IP", 100),
+		SSEHeartbeat:            streamTimeoutFromEnv("SSE_HEARTBEAT_INTERVAL", 15*time.Second),
 	}
-	mu sync.RWMutex
 }
 
-func NewSubscriptionManager() *SubscriptionManager {
+// Client is a single subscriber connection, either a WebSocket or an SSE
+// stream (conn is nil). Outbound messages are queued on send and written by
+// the connection's own goroutine so that one stalled peer cannot block the
+// fan-out to everyone else.
+type Client struct {
+	conn   *websocket.Conn
+	send   chan WebSocketMessage
+	userID int
+	ip     string
+	// symbols and portfolio are owned by the SubscriptionManager and guarded
+	// by its mutex. portfolio is nil unless the client sent subscribe_portfolio.
+	symbols   map[string]bool
+	portfolio map[string]bool
+}
+
+func newClient(conn *websocket.Conn, buffer int, userID int, ip string) *Client {
+	return &Client{
+		conn:    conn,
+		send:    make(chan WebSocketMessage, buffer),
+		userID:  userID,
+		ip:      ip,
+		symbols: make(map[string]bool),
+	}
+}
+
+type subscription struct {
+	client *Client
+	symbol string
+}
+
+// clientMessage is a message addressed to a single connection.
+type clientMessage struct {
+	client  *Client
+	message WebSocketMessage
+}
+
+// userMessage is a message for every connection owned by a user.
+type userMessage struct {
+	userID  int
+	message WebSocketMessage
+}
+
+// portfolioFollow attaches a client to its user's portfolio.
+type portfolioFollow struct {
+	client  *Client
+	symbols []string
+}
+
+// portfolioChange is a symbol added to or removed from a user's portfolio
+// through the REST API.
+type portfolioChange struct {
+	userID int
+	symbol string
+	added  bool
+}
+
+type SubscriptionManager struct {
+	clients         map[*Client]bool
+	subscribers     map[string]map[*Client]bool
+	broadcast       chan WebSocketMessage
+	direct          chan clientMessage
+	userMessages    chan userMessage
+	register        chan *Client
+	unregister      chan *Client
+	disconnect      chan int
+	subscribe       chan subscription
+	unsubscribe     chan subscription
+	followPortfolio chan portfolioFollow
+	portfolioChange chan portfolioChange
+	ticks           chan map[string]*stocks.Quote
+	resume          chan resumeRequest
+	mu              sync.RWMutex
+
+	// seq numbers broadcast messages; history holds the most recent ones.
+	seq     uint64
+	history []WebSocketMessage
+
+	// Subscription counts summed across connections, used to enforce limits.
+	userSubs   map[int]int
+	ipSubs     map[string]int
+	maxPerUser int
+	maxPerIP   int
+}
+
+// NewSubscriptionManager returns a manager that caps subscriptions per user
+// and per client IP. A limit of zero or less disables that cap.
+func NewSubscriptionManager(maxPerUser, maxPerIP int) *SubscriptionManager {
 	return &SubscriptionManager{
-		clients:     make(map[*websocket.Conn]bool),
-		subscribers: make(map[string]map[*websocket.Conn]bool),
-		broadcast:   make(chan WebSocketMessage),
-		register:    make(chan *websocket.Conn),
-		unregister:  make(chan *websocket.Conn),
-		subscribe: make(chan struct {
-			conn   *websocket.Conn
-			symbol string
-		}),
+		clients:         make(map[*Client]bool),
+		subscribers:     make(map[string]map[*Client]bool),
+		broadcast:       make(chan WebSocketMessage),
+		direct:          make(chan clientMessage),
+		userMessages:    make(chan userMessage),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		disconnect:      make(chan int),
+		subscribe:       make(chan subscription),
+		unsubscribe:     make(chan subscription),
+		followPortfolio: make(chan portfolioFollow),
+		portfolioChange: make(chan portfolioChange),
+		ticks:           make(chan map[string]*stocks.Quote),
+		resume:          make(chan resumeRequest),
+		userSubs:        make(map[int]int),
+		ipSubs:          make(map[string]int),
+		maxPerUser:      maxPerUser,
+		maxPerIP:        maxPerIP,
 	}
 }
 
 func (sm *SubscriptionManager) Run() {
 	for {
 		select {
-		case conn := <-sm.register:
+		case client := <-sm.register:
 			sm.mu.Lock()
-			sm.clients[conn] = true
+			sm.clients[client] = true
 			sm.mu.Unlock()
-		case conn := <-sm.unregister:
+			wsMetrics.Add(metricConnectionsActive, 1)
+			wsMetrics.Add(metricConnectionsTotal, 1)
+		case client := <-sm.unregister:
 			sm.mu.Lock()
-			if _, ok := sm.clients[conn]; ok {
-				delete(sm.clients, conn)
-				conn.Close()
-				// Remove from subscribers
-				for symbol, subs := range sm.subscribers {
-					delete(subs, conn)
-					if len(subs) == 0 {
-						delete(sm.subscribers, symbol)
-					}
+			sm.removeClient(client)
+			sm.mu.Unlock()
+		case userID := <-sm.disconnect:
+			sm.mu.Lock()
+			for client := range sm.clients {
+				if client.userID == userID {
+					sm.removeClient(client)
 				}
 			}
 			sm.mu.Unlock()
 		case sub := <-sm.subscribe:
 			sm.mu.Lock()
-			if sm.subscribers[sub.symbol] == nil {
-				sm.subscribers[sub.symbol] = make(map[*websocket.Conn]bool)
+			sm.addSubscription(sub.client, sub.symbol)
+			sm.mu.Unlock()
+		case dm := <-sm.direct:
+			sm.mu.Lock()
+			if sm.clients[dm.client] {
+				sm.deliver(dm.client, dm.message)
+			}
+			sm.mu.Unlock()
+		case um := <-sm.userMessages:
+			sm.mu.Lock()
+			for client := range sm.clients {
+				if client.userID == um.userID {
+					sm.deliver(client, um.message)
+				}
 			}
-			sm.subscribers[sub.symbol][sub.conn] = true
+			sm.mu.Unlock()
+		case sub := <-sm.unsubscribe:
+			sm.mu.Lock()
+			sm.removeSubscription(sub.client, sub.symbol)
+			sm.mu.Unlock()
+		case follow := <-sm.followPortfolio:
+			sm.mu.Lock()
+			if sm.clients[follow.client] {
+				follow.client.portfolio = make(map[string]bool, len(follow.symbols))
+				for _, symbol := range follow.symbols {
+					follow.client.portfolio[symbol] = true
+					sm.addSubscription(follow.client, symbol)
+				}
+			}
+			sm.mu.Unlock()
+		case change := <-sm.portfolioChange:
+			sm.mu.Lock()
+			sm.applyPortfolioChange(change)
+			sm.mu.Unlock()
+		case quotes := <-sm.ticks:
+			sm.mu.Lock()
+			for client := range sm.clients {
+				if client.portfolio != nil {
+					sm.deliver(client, WebSocketMessage{
+						Action:  "portfolio",
+						Payload: newPortfolioSnapshot(client.portfolio, quotes),
+					})
+				}
+			}
+			sm.mu.Unlock()
+		case req := <-sm.resume:
+			sm.mu.Lock()
+			sm.attach(req)
 			sm.mu.Unlock()
 		case message := <-sm.broadcast:
-			sm.mu.RLock()
+			sm.mu.Lock()
+			// Clustered quotes arrive numbered from a shared sequence, so
+			// ids mean the same on every replica
+			if message.ID == 0 {
+				sm.seq++
+				message.ID = sm.seq
+			} else {
+				sm.seq = max(sm.seq, message.ID)
+			}
+			sm.history = append(sm.history, message)
+			if len(sm.history) > historySize {
+				sm.history = sm.history[len(sm.history)-historySize:]
+			}
 			if message.Symbol != "" {
 				// Send to subscribers of this symbol
-				if subs, ok := sm.subscribers[message.Symbol]; ok {
-					for conn := range subs {
-						conn.WriteJSON(message)
-					}
+				for client := range sm.subscribers[message.Symbol] {
+					sm.deliver(client, message)
 				}
 			} else {
 				// Broadcast to all
-				for conn := range sm.clients {
-					conn.WriteJSON(message)
+				for client := range sm.clients {
+					sm.deliver(client, message)
 				}
 			}
-			sm.mu.RUnlock()
+			sm.mu.Unlock()
+		}
+	}
+}
+
+// addSubscription subscribes client to symbol unless that would exceed the
+// per-user or per-IP cap, in which case the client is sent an error instead.
+// Callers must hold sm.mu for writing.
+func (sm *SubscriptionManager) addSubscription(client *Client, symbol string) {
+	// A client reaped between reading the request and getting here must not
+	// be resurrected into the subscriber set.
+	if !sm.clients[client] || client.symbols[symbol] {
+		return
+	}
+	if (sm.maxPerUser > 0 && sm.userSubs[client.userID] >= sm.maxPerUser) ||
+		(sm.maxPerIP > 0 && sm.ipSubs[client.ip] >= sm.maxPerIP) {
+		wsMetrics.Add(metricSubscriptionsRejected, 1)
+		sm.deliver(client, WebSocketMessage{Action: "error", Symbol: symbol, Payload: "subscription limit reached"})
+		return
+	}
+
+	if sm.subscribers[symbol] == nil {
+		sm.subscribers[symbol] = make(map[*Client]bool)
+	}
+	sm.subscribers[symbol][client] = true
+	client.symbols[symbol] = true
+	sm.userSubs[client.userID]++
+	sm.ipSubs[client.ip]++
+}
+
+// removeSubscription drops client's subscription to symbol, if it has one.
+// Callers must hold sm.mu for writing.
+func (sm *SubscriptionManager) removeSubscription(client *Client, symbol string) {
+	if !client.symbols[symbol] {
+		return
+	}
+	delete(client.symbols, symbol)
+	if subs := sm.subscribers[symbol]; subs != nil {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(sm.subscribers, symbol)
 		}
 	}
+	sm.userSubs[client.userID]--
+	sm.ipSubs[client.ip]--
 }
 
+// deliver queues message for client, dropping the client if its buffer is
+// full. Callers must hold sm.mu for writing.
+func (sm *SubscriptionManager) deliver(client *Client, message WebSocketMessage) {
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("reaped slow consumer %s (user %d)", client.ip, client.userID)
+		wsMetrics.Add(metricReapedSlow, 1)
+		sm.removeClient(client)
+	}
+}
+
+// removeClient forgets client and closes its send channel, which makes the
+// write pump send a close frame and hang up. Callers must hold sm.mu for
+// writing. It is safe to call more than once for the same client.
+func (sm *SubscriptionManager) removeClient(client *Client) {
+	if _, ok := sm.clients[client]; !ok {
+		return
+	}
+	delete(sm.clients, client)
+	close(client.send)
+	for symbol := range client.symbols {
+		subs := sm.subscribers[symbol]
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(sm.subscribers, symbol)
+		}
+	}
+	if sm.userSubs[client.userID] -= len(client.symbols); sm.userSubs[client.userID] <= 0 {
+		delete(sm.userSubs, client.userID)
+	}
+	if sm.ipSubs[client.ip] -= len(client.symbols); sm.ipSubs[client.ip] <= 0 {
+		delete(sm.ipSubs, client.ip)
+	}
+	wsMetrics.Add(metricConnectionsActive, -1)
+}
+
+// handleWebSocket upgrades an authenticated connection. The JWT may be given
+// as a ?token= query parameter, as the second entry of a "bearer" subprotocol,
+// or in an {"action":"auth","token":"..."} first message.
 func (s *Server) handleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	var claims *auth.Claims
+	if token := handshakeToken(c.Request); token != "" {
+		var err error
+		claims, err = auth.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			wsMetrics.Add(metricAuthFailures, 1)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade to websocket: %v", err)
 		return
 	}
 
-	s.subManager.register <- conn
+	if claims == nil {
+		claims, err = s.authenticateFirstMessage(conn)
+		if err != nil {
+			wsMetrics.Add(metricAuthFailures, 1)
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "authentication required"),
+				time.Now().Add(s.wsConfig.WriteTimeout))
+			conn.Close()
+			return
+		}
+	}
 
+	client := newClient(conn, s.wsConfig.SendBuffer, claims.UserID, c.ClientIP())
+	s.subManager.register <- client
+
+	go s.writePump(client)
+	s.readPump(client)
+}
+
+// handshakeToken extracts a JWT offered during the HTTP upgrade, if any.
+func handshakeToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) >= 2 && protocols[0] == bearerSubprotocol {
+		return protocols[1]
+	}
+	return ""
+}
+
+// authenticateFirstMessage waits up to AuthTimeout for an auth message.
+func (s *Server) authenticateFirstMessage(conn *websocket.Conn) (*auth.Claims, error) {
+	conn.SetReadLimit(s.wsConfig.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(s.wsConfig.AuthTimeout))
+	var msg WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Action != "auth" || msg.Token == "" {
+		return nil, errors.New("expected auth message")
+	}
+	return auth.Authenticate(context.Background(), msg.Token)
+}
+
+// readPump processes inbound messages until the peer goes away or stops
+// answering pings within the read timeout.
+func (s *Server) readPump(client *Client) {
+	conn := client.conn
 	defer func() {
-		s.subManager.unregister <- conn
+		s.subManager.unregister <- client
+		conn.Close()
 	}()
 
+	conn.SetReadLimit(s.wsConfig.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(s.wsConfig.ReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.wsConfig.ReadTimeout))
+	})
+
 	for {
 		var msg WebSocketMessage
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			log.Printf("websocket read error: %v", err)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("websocket reaped idle connection %s", conn.RemoteAddr())
+				wsMetrics.Add(metricReapedTimeout, 1)
+			} else {
+				log.Printf("websocket read error: %v", err)
+			}
 			break
 		}
+		conn.SetReadDeadline(time.Now().Add(s.wsConfig.ReadTimeout))
 
-		if msg.Action == "subscribe" && msg.Symbol != "" {
-			s.subManager.subscribe <- struct {
-				conn   *websocket.Conn
-				symbol string
-			}{conn, msg.Symbol}
-			log.Printf("Client subscribed to %s", msg.Symbol)
+		switch msg.Action {
+		case "subscribe", "unsubscribe":
+			symbol, ok := stocks.NormalizeSymbol(msg.Symbol)
+			if !ok {
+				s.subManager.direct <- clientMessage{client, WebSocketMessage{Action: "error", Symbol: msg.Symbol, Payload: "invalid symbol"}}
+				continue
+			}
+			if msg.Action == "subscribe" {
+				s.subManager.subscribe <- subscription{client, symbol}
+				log.Printf("Client subscribed to %s", symbol)
+			} else {
+				s.subManager.unsubscribe <- subscription{client, symbol}
+			}
+		case "subscribe_portfolio":
+			s.subscribePortfolio(client)
 		}
 	}
 }
 
-// StartTicker simulates real-time updates
+// writePump is the only goroutine that writes to the connection. It drains
+// the client's queue and pings the peer so half-open connections are noticed.
+func (s *Server) writePump(client *Client) {
+	conn := client.conn
+	ticker := time.NewTicker(s.wsConfig.PingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-client.send:
+			conn.SetWriteDeadline(time.Now().Add(s.wsConfig.WriteTimeout))
+			if !ok {
+				// The manager dropped this client.
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(s.wsConfig.WriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SendToUser delivers message to every local connection owned by userID.
+func (sm *SubscriptionManager) SendToUser(userID int, message WebSocketMessage) {
+	sm.userMessages <- userMessage{userID: userID, message: message}
+}
+
+// DisconnectUser closes every local connection owned by userID.
+func (sm *SubscriptionManager) DisconnectUser(userID int) {
+	sm.disconnect <- userID
+}
+
+// Symbols returns every symbol with at least one local subscriber.
+func (sm *SubscriptionManager) Symbols() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	symbols := make([]string, 0, len(sm.subscribers))
+	for sym := range sm.subscribers {
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// StartTicker polls quotes for subscribed symbols every few seconds. With a
+// Cluster only the elected leader polls, for the symbols wanted by any
+// replica, and the results reach clients through Postgres NOTIFY.
 func (s *Server) StartTicker() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(tickInterval)
 	go func() {
-		for range ticker.C {
-			// In a real app, we would fetch real data here.
-			// For now, we'll just iterate over active subscriptions and send a dummy update.
-			s.subManager.mu.RLock()
-			symbols := make([]string, 0, len(s.subManager.subscribers))
-			for sym := range s.subManager.subscribers {
-				symbols = append(symbols, sym)
+		var lastPoll time.Time
+		for now := range ticker.C {
+			symbols := s.subManager.Symbols()
+			if s.cluster != nil {
+				if err := s.cluster.Announce(context.Background(), symbols); err != nil {
+					log.Printf("cluster: announce demand: %v", err)
+				}
+				if !s.cluster.IsLeader() {
+					continue
+				}
+				symbols = s.cluster.Demand(symbols)
+			}
+			// Poll less often outside the regular session
+			if now.Sub(lastPoll) < s.tickerConfig.PollInterval(now) {
+				continue
 			}
-			s.subManager.mu.RUnlock()
+			lastPoll = now
+			symbols = s.withWatchedSymbols(symbols)
 
+			quotes := make(map[string]*stocks.Quote, len(symbols))
 			for _, sym := range symbols {
 				// Fetch latest quote (this is blocking, ideally should be async or cached)
 				// Using a background context for the ticker
 				q, err := s.provider.Quote(context.Background(), sym)
 				if err == nil {
-					s.subManager.broadcast <- WebSocketMessage{
-						Action:  "update",
-						Symbol:  sym,
-						Payload: q,
-					}
+					quotes[sym] = q
+					s.publishQuote(sym, q)
 				}
 			}
+			s.publishTickEnd(quotes)
+			if s.alertEvaluator != nil {
+				s.alertEvaluator.Feed(quotes)
+			}
+			if s.paperMatcher != nil {
+				s.paperMatcher.Feed(quotes)
+			}
 		}
 	}()
 }
+
+// withWatchedSymbols adds symbols that have active alert rules or resting
+// paper orders, so they are evaluated even when nobody is watching them.
+func (s *Server) withWatchedSymbols(symbols []string) []string {
+	if s.alertEvaluator != nil {
+		extra, err := s.alertEvaluator.Symbols(context.Background())
+		if err != nil {
+			log.Printf("alerts: %v", err)
+		}
+		symbols = mergeSymbols(symbols, extra)
+	}
+	if s.paperMatcher != nil {
+		extra, err := s.paperMatcher.Symbols(context.Background())
+		if err != nil {
+			log.Printf("paper: %v", err)
+		}
+		symbols = mergeSymbols(symbols, extra)
+	}
+	return symbols
+}
+
+func mergeSymbols(symbols, extra []string) []string {
+	seen := make(map[string]bool, len(symbols))
+	for _, sym := range symbols {
+		seen[sym] = true
+	}
+	for _, sym := range extra {
+		if !seen[sym] {
+			seen[sym] = true
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// notifyUser sends message to all of userID's connections, on whichever
+// replica they are attached to.
+func (s *Server) notifyUser(userID int, message WebSocketMessage) {
+	if s.cluster != nil {
+		err := s.cluster.PublishToUser(context.Background(), userID, message)
+		if err == nil {
+			return
+		}
+		log.Printf("cluster: publish to user %d: %v", userID, err)
+	}
+	s.subManager.SendToUser(userID, message)
+}
+
+// disconnectUser closes all of userID's streaming connections, on whichever
+// replica they are attached to.
+func (s *Server) disconnectUser(userID int) {
+	if s.cluster != nil {
+		err := s.cluster.PublishDisconnect(context.Background(), userID)
+		if err == nil {
+			return
+		}
+		log.Printf("cluster: publish disconnect for user %d: %v", userID, err)
+	}
+	s.subManager.DisconnectUser(userID)
+}
+
+// portfolioChanged resubscribes userID's portfolio-following connections
+// after a REST change, on whichever replica they are attached to.
+func (s *Server) portfolioChanged(userID int, symbol string, added bool) {
+	if s.cluster != nil {
+		err := s.cluster.PublishPortfolioChange(context.Background(), userID, symbol, added)
+		if err == nil {
+			return
+		}
+		log.Printf("cluster: publish portfolio change for user %d: %v", userID, err)
+	}
+	s.subManager.PortfolioChanged(userID, symbol, added)
+}
+
+// publishQuote hands a polled quote to local subscribers, or to every
+// replica when clustered. If NOTIFY fails, local clients still get it.
+func (s *Server) publishQuote(symbol string, q *stocks.Quote) {
+	if s.cluster != nil {
+		err := s.cluster.PublishQuote(context.Background(), symbol, q)
+		if err == nil {
+			return
+		}
+		log.Printf("cluster: publish %s: %v", symbol, err)
+	}
+	s.subManager.broadcast <- WebSocketMessage{
+		Action:  "update",
+		Symbol:  symbol,
+		Payload: q,
+	}
+}
+
+func (s *Server) publishTickEnd(quotes map[string]*stocks.Quote) {
+	if s.cluster != nil {
+		err := s.cluster.PublishTickEnd(context.Background())
+		if err == nil {
+			return
+		}
+		log.Printf("cluster: publish tick end: %v", err)
+	}
+	s.subManager.ticks <- quotes
+}
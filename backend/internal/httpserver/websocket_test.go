@@ -0,0 +1,274 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+)
+
+func newTestWebSocketServer(t *testing.T, cfg WebSocketConfig) (*Server, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &Server{
+		router:         gin.New(),
+		subManager:     NewSubscriptionManager(cfg.MaxSubscriptionsPerUser, cfg.MaxSubscriptionsPerIP),
+		wsConfig:       cfg,
+		allowedOrigins: []string{"http://allowed.example"},
+	}
+	s.upgrader = s.newUpgrader()
+	go s.subManager.Run()
+	s.router.GET("/ws", s.handleWebSocket)
+
+	ts := httptest.NewServer(s.router)
+	t.Cleanup(ts.Close)
+	return s, "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+}
+
+func dialAuthenticated(t *testing.T, url string, userID int) *websocket.Conn {
+	t.Helper()
+	token, err := auth.GenerateToken(userID)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func clientCount(sm *SubscriptionManager) int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.clients)
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before timeout")
+}
+
+func TestWebSocketReapsSilentClient(t *testing.T) {
+	s, url := newTestWebSocketServer(t, WebSocketConfig{
+		ReadTimeout:    200 * time.Millisecond,
+		WriteTimeout:   time.Second,
+		PingInterval:   time.Hour, // never ping, so the peer never gets a chance to pong
+		MaxMessageSize: 512,
+		SendBuffer:     4,
+	})
+
+	before := wsMetrics.Get(metricReapedTimeout)
+	dialAuthenticated(t, url, 1)
+
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 1 })
+	waitFor(t, 2*time.Second, func() bool { return clientCount(s.subManager) == 0 })
+
+	after := wsMetrics.Get(metricReapedTimeout)
+	if after == nil || (before != nil && after.String() == before.String()) {
+		t.Errorf("expected %s to increase, before=%v after=%v", metricReapedTimeout, before, after)
+	}
+}
+
+func TestWebSocketPongKeepsClientAlive(t *testing.T) {
+	s, url := newTestWebSocketServer(t, WebSocketConfig{
+		ReadTimeout:    300 * time.Millisecond,
+		WriteTimeout:   time.Second,
+		PingInterval:   100 * time.Millisecond,
+		MaxMessageSize: 512,
+		SendBuffer:     4,
+	})
+
+	conn := dialAuthenticated(t, url, 1)
+
+	// Reading lets the default ping handler answer with pongs.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 1 })
+	time.Sleep(time.Second)
+	if n := clientCount(s.subManager); n != 1 {
+		t.Errorf("expected client to survive with pongs, got %d clients", n)
+	}
+}
+
+func TestWebSocketRejectsOversizedMessage(t *testing.T) {
+	s, url := newTestWebSocketServer(t, WebSocketConfig{
+		ReadTimeout:    time.Minute,
+		WriteTimeout:   time.Second,
+		PingInterval:   time.Minute,
+		MaxMessageSize: 64,
+		SendBuffer:     4,
+	})
+
+	conn := dialAuthenticated(t, url, 1)
+
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 1 })
+	big := `{"action":"subscribe","symbol":"` + strings.Repeat("A", 128) + `"}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(big)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 0 })
+}
+
+func testConfig() WebSocketConfig {
+	return WebSocketConfig{
+		ReadTimeout:             time.Minute,
+		WriteTimeout:            time.Second,
+		PingInterval:            time.Minute,
+		MaxMessageSize:          512,
+		SendBuffer:              8,
+		AuthTimeout:             200 * time.Millisecond,
+		MaxSubscriptionsPerUser: 2,
+		MaxSubscriptionsPerIP:   10,
+	}
+}
+
+func TestWebSocketRequiresAuthentication(t *testing.T) {
+	s, url := newTestWebSocketServer(t, testConfig())
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token=garbage", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad token, got err=%v resp=%v", err, resp)
+	}
+
+	// No token at all: the server waits for an auth message, then hangs up.
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected policy violation close, got %v", err)
+	}
+	if n := clientCount(s.subManager); n != 0 {
+		t.Errorf("unauthenticated client was registered: %d clients", n)
+	}
+}
+
+func TestWebSocketAuthenticatesViaFirstMessageAndSubprotocol(t *testing.T) {
+	s, url := newTestWebSocketServer(t, testConfig())
+	token, _ := auth.GenerateToken(7)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(WebSocketMessage{Action: "auth", Token: token}); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 1 })
+
+	dialer := websocket.Dialer{Subprotocols: []string{bearerSubprotocol, token}}
+	conn2, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial with subprotocol: %v", err)
+	}
+	defer conn2.Close()
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != bearerSubprotocol {
+		t.Errorf("expected negotiated subprotocol %q, got %q", bearerSubprotocol, got)
+	}
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 2 })
+}
+
+func TestWebSocketRejectsDisallowedOrigin(t *testing.T) {
+	_, url := newTestWebSocketServer(t, testConfig())
+	token, _ := auth.GenerateToken(1)
+
+	header := http.Header{"Origin": {"http://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token="+token, header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed origin, got err=%v resp=%v", err, resp)
+	}
+
+	header.Set("Origin", "http://allowed.example")
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, header)
+	if err != nil {
+		t.Fatalf("dial from allowed origin: %v", err)
+	}
+	conn.Close()
+}
+
+func TestWebSocketSubscriptionLimitPerUser(t *testing.T) {
+	s, url := newTestWebSocketServer(t, testConfig())
+
+	// Two connections for the same user share the per-user cap of 2.
+	a := dialAuthenticated(t, url, 42)
+	b := dialAuthenticated(t, url, 42)
+	waitFor(t, time.Second, func() bool { return clientCount(s.subManager) == 2 })
+
+	a.WriteJSON(WebSocketMessage{Action: "subscribe", Symbol: "AAPL"})
+	a.WriteJSON(WebSocketMessage{Action: "subscribe", Symbol: "MSFT"})
+	waitFor(t, time.Second, func() bool {
+		s.subManager.mu.RLock()
+		defer s.subManager.mu.RUnlock()
+		return s.subManager.userSubs[42] == 2
+	})
+
+	b.WriteJSON(WebSocketMessage{Action: "subscribe", Symbol: "TSLA"})
+	b.SetReadDeadline(time.Now().Add(time.Second))
+	var msg WebSocketMessage
+	if err := b.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.Action != "error" || msg.Symbol != "TSLA" {
+		t.Errorf("expected limit error for TSLA, got %+v", msg)
+	}
+
+	s.subManager.mu.RLock()
+	_, subscribed := s.subManager.subscribers["TSLA"]
+	s.subManager.mu.RUnlock()
+	if subscribed {
+		t.Error("TSLA subscription should have been rejected")
+	}
+}
+
+func TestWebSocketNormalizesSymbols(t *testing.T) {
+	s, url := newTestWebSocketServer(t, testConfig())
+	conn := dialAuthenticated(t, url, 42)
+
+	// Keyed the same as an SSE client asking for ?symbols=aapl
+	conn.WriteJSON(WebSocketMessage{Action: "subscribe", Symbol: " aapl "})
+	waitFor(t, time.Second, func() bool { return equalStrings(subscribedSymbols(s.subManager), []string{"AAPL"}) })
+
+	conn.WriteJSON(WebSocketMessage{Action: "subscribe", Symbol: "not a ticker"})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.Action != "error" {
+		t.Errorf("expected an error for an invalid symbol, got %+v", msg)
+	}
+}
+
+func TestWebSocketConfigRejectsShortTimeouts(t *testing.T) {
+	t.Setenv("WS_READ_TIMEOUT", "0s")
+	t.Setenv("SSE_HEARTBEAT_INTERVAL", "-5s")
+
+	cfg := WebSocketConfigFromEnv()
+	if cfg.ReadTimeout != 60*time.Second || cfg.PingInterval <= 0 {
+		t.Errorf("read timeout %v, ping interval %v; want the defaults", cfg.ReadTimeout, cfg.PingInterval)
+	}
+	if cfg.SSEHeartbeat != 15*time.Second {
+		t.Errorf("heartbeat %v, want the default", cfg.SSEHeartbeat)
+	}
+}
@@ -0,0 +1,218 @@
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+var (
+	ErrUnknownIndicator = errors.New("unknown indicator")
+	ErrUnknownInterval  = errors.New("unknown interval")
+	ErrInvalidParams    = errors.New("invalid indicator params")
+)
+
+// intervals are the candle intervals the providers serve.
+var intervals = []string{"daily"}
+
+// Params are an indicator's numeric parameters, e.g. {"period": 14}.
+type Params map[string]float64
+
+// String renders params in a canonical "k=v,k=v" form, sorted by key.
+func (p Params) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.FormatFloat(p[k], 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Point is one defined indicator value.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Result holds one or more named output series, e.g. "macd", "signal" and
+// "histogram" for MACD. Warm-up values are omitted.
+type Result struct {
+	Name   string             `json:"name"`
+	Params Params             `json:"params"`
+	Series map[string][]Point `json:"series"`
+}
+
+type definition struct {
+	defaults Params
+	// minimums holds the lowest allowed value per param; params not listed
+	// must be at least 1. Integer params are listed in integers.
+	minimums map[string]float64
+	integers []string
+	compute  func(c columns, p Params) map[string][]float64
+}
+
+var definitions = map[string]definition{
+	"sma": {
+		defaults: Params{"period": 20},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			return map[string][]float64{"sma": SMA(c.close, int(p["period"]))}
+		},
+	},
+	"ema": {
+		defaults: Params{"period": 20},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			return map[string][]float64{"ema": EMA(c.close, int(p["period"]))}
+		},
+	},
+	"rsi": {
+		defaults: Params{"period": 14},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			return map[string][]float64{"rsi": RSI(c.close, int(p["period"]))}
+		},
+	},
+	"macd": {
+		defaults: Params{"fast": 12, "slow": 26, "signal": 9},
+		integers: []string{"fast", "slow", "signal"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			line, sig, hist := MACD(c.close, int(p["fast"]), int(p["slow"]), int(p["signal"]))
+			return map[string][]float64{"macd": line, "signal": sig, "histogram": hist}
+		},
+	},
+	"bollinger": {
+		defaults: Params{"period": 20, "k": 2},
+		minimums: map[string]float64{"k": 0},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			middle, upper, lower := Bollinger(c.close, int(p["period"]), p["k"])
+			return map[string][]float64{"middle": middle, "upper": upper, "lower": lower}
+		},
+	},
+	"atr": {
+		defaults: Params{"period": 14},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			return map[string][]float64{"atr": ATR(c.high, c.low, c.close, int(p["period"]))}
+		},
+	},
+	"vwap": {
+		defaults: Params{"period": 0},
+		minimums: map[string]float64{"period": 0},
+		integers: []string{"period"},
+		compute: func(c columns, p Params) map[string][]float64 {
+			return map[string][]float64{"vwap": VWAP(c.high, c.low, c.close, c.volume, int(p["period"]))}
+		},
+	},
+}
+
+// Names lists the supported indicators.
+func Names() []string {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Intervals lists the supported candle intervals.
+func Intervals() []string {
+	return append([]string(nil), intervals...)
+}
+
+// ParseParams parses "period:14" or "fast:12,slow:26" for the named
+// indicator, filling in defaults for anything left out.
+func ParseParams(name, raw string) (Params, error) {
+	def, ok := definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownIndicator, name)
+	}
+	p := make(Params, len(def.defaults))
+	for k, v := range def.defaults {
+		p[k] = v
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, found := strings.Cut(pair, ":")
+		if !found {
+			k, v, found = strings.Cut(pair, "=")
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		if _, known := def.defaults[k]; !found || !known {
+			return nil, fmt.Errorf("%w: unexpected %q for %s", ErrInvalidParams, pair, name)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%w: %s must be a number", ErrInvalidParams, k)
+		}
+		p[k] = f
+	}
+
+	for k, v := range p {
+		lowest, ok := def.minimums[k]
+		if !ok {
+			lowest = 1
+		}
+		if v < lowest {
+			return nil, fmt.Errorf("%w: %s must be at least %g", ErrInvalidParams, k, lowest)
+		}
+	}
+	for _, k := range def.integers {
+		if p[k] != math.Trunc(p[k]) || p[k] > 1000 {
+			return nil, fmt.Errorf("%w: %s must be a whole number up to 1000", ErrInvalidParams, k)
+		}
+	}
+	if name == "macd" && p["fast"] >= p["slow"] {
+		return nil, fmt.Errorf("%w: fast must be shorter than slow", ErrInvalidParams)
+	}
+	return p, nil
+}
+
+type columns struct {
+	high, low, close []float64
+	volume           []int64
+}
+
+// Compute evaluates the named indicator over candles, which must be in
+// ascending time order. params should come from ParseParams.
+func Compute(name string, params Params, candles []stocks.Candle) (*Result, error) {
+	def, ok := definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownIndicator, name)
+	}
+	c := columns{
+		high:   make([]float64, len(candles)),
+		low:    make([]float64, len(candles)),
+		close:  make([]float64, len(candles)),
+		volume: make([]int64, len(candles)),
+	}
+	for i, candle := range candles {
+		c.high[i], c.low[i], c.close[i], c.volume[i] = candle.High, candle.Low, candle.Close, candle.Volume
+	}
+
+	res := &Result{Name: name, Params: params, Series: map[string][]Point{}}
+	for key, values := range def.compute(c, params) {
+		points := []Point{}
+		for i, v := range values {
+			if !math.IsNaN(v) {
+				points = append(points, Point{Time: candles[i].Time, Value: v})
+			}
+		}
+		res.Series[key] = points
+	}
+	return res, nil
+}
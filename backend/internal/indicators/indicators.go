@@ -0,0 +1,169 @@
+// Package indicators computes technical indicators over candle series.
+//
+// Every function returns slices aligned with its input; values that are not
+// defined yet (the warm-up period) are NaN.
+package indicators
+
+import "math"
+
+func nans(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// SMA is the simple moving average over period values.
+func SMA(values []float64, period int) []float64 {
+	out := nans(len(values))
+	if period <= 0 {
+		return out
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with smoothing 2/(period+1),
+// seeded with the SMA of the first period values. Leading NaNs in values
+// are skipped, so EMA can be applied to the output of another indicator.
+func EMA(values []float64, period int) []float64 {
+	out := nans(len(values))
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if period <= 0 || len(values)-start < period {
+		return out
+	}
+	alpha := 2 / float64(period+1)
+	sum := 0.0
+	for _, v := range values[start : start+period] {
+		sum += v
+	}
+	prev := sum / float64(period)
+	out[start+period-1] = prev
+	for i := start + period; i < len(values); i++ {
+		prev = alpha*values[i] + (1-alpha)*prev
+		out[i] = prev
+	}
+	return out
+}
+
+// RSI is Wilder's relative strength index.
+func RSI(values []float64, period int) []float64 {
+	out := nans(len(values))
+	if period <= 0 || len(values) <= period {
+		return out
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD returns the MACD line (fast EMA minus slow EMA), its signal EMA and
+// the histogram between them.
+func MACD(values []float64, fast, slow, signal int) (line, sig, hist []float64) {
+	fastEMA, slowEMA := EMA(values, fast), EMA(values, slow)
+	line = nans(len(values))
+	for i := range values {
+		line[i] = fastEMA[i] - slowEMA[i] // NaN until both are defined
+	}
+	sig = EMA(line, signal)
+	hist = make([]float64, len(values))
+	for i := range values {
+		hist[i] = line[i] - sig[i]
+	}
+	return line, sig, hist
+}
+
+// Bollinger returns the middle SMA and the bands k population standard
+// deviations above and below it.
+func Bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = nans(len(values)), nans(len(values))
+	for i := period - 1; i < len(values) && period > 0; i++ {
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+	}
+	return middle, upper, lower
+}
+
+// ATR is Wilder's average true range. The first value averages the true
+// ranges of bars 1..period; the first bar has no previous close.
+func ATR(high, low, close []float64, period int) []float64 {
+	out := nans(len(close))
+	if period <= 0 || len(close) <= period {
+		return out
+	}
+	tr := func(i int) float64 {
+		return math.Max(high[i]-low[i], math.Max(math.Abs(high[i]-close[i-1]), math.Abs(low[i]-close[i-1])))
+	}
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += tr(i)
+	}
+	prev := sum / float64(period)
+	out[period] = prev
+	for i := period + 1; i < len(close); i++ {
+		prev = (prev*float64(period-1) + tr(i)) / float64(period)
+		out[i] = prev
+	}
+	return out
+}
+
+// VWAP is the volume-weighted average of the typical price (high+low+close)/3.
+// With period 0 it accumulates over the whole series; otherwise it covers
+// the last period bars.
+func VWAP(high, low, close []float64, volume []int64, period int) []float64 {
+	out := nans(len(close))
+	var pv, vol float64
+	for i := range close {
+		pv += (high[i] + low[i] + close[i]) / 3 * float64(volume[i])
+		vol += float64(volume[i])
+		if period > 0 && i >= period {
+			j := i - period
+			pv -= (high[j] + low[j] + close[j]) / 3 * float64(volume[j])
+			vol -= float64(volume[j])
+		}
+		if (period == 0 || i >= period-1) && vol > 0 {
+			out[i] = pv / vol
+		}
+	}
+	return out
+}
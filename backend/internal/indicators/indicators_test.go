@@ -0,0 +1,156 @@
+package indicators_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/indicators"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// reference.json holds the RSI and EMA worked examples published by
+// StockCharts (rounded to cents, so compared to within a cent) and an 80-bar series with expected values
+// computed independently, keyed by "<name>:<canonical params>".
+type published struct {
+	Close    []float64 `json:"close"`
+	Period   int       `json:"period"`
+	Expected []float64 `json:"expected"`
+}
+
+type reference struct {
+	RSI      published                        `json:"stockcharts_rsi"`
+	EMA      published                        `json:"stockcharts_ema"`
+	Candles  []stocks.Candle                  `json:"candles"`
+	Expected map[string]map[string][]*float64 `json:"expected"`
+}
+
+func loadReference(t *testing.T) reference {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/reference.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref reference
+	if err := json.Unmarshal(raw, &ref); err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func checkPublished(t *testing.T, name string, got []float64, want published) {
+	t.Helper()
+	got = got[len(got)-len(want.Expected):]
+	for i, w := range want.Expected {
+		if math.Abs(got[i]-w) > 0.01 {
+			t.Errorf("%s[%d]: got %.4f, want %.2f", name, i, got[i], w)
+		}
+	}
+}
+
+func TestPublishedExamples(t *testing.T) {
+	ref := loadReference(t)
+	checkPublished(t, "rsi", indicators.RSI(ref.RSI.Close, ref.RSI.Period), ref.RSI)
+	checkPublished(t, "ema", indicators.EMA(ref.EMA.Close, ref.EMA.Period), ref.EMA)
+}
+
+func TestComputeMatchesReference(t *testing.T) {
+	ref := loadReference(t)
+	for key, series := range ref.Expected {
+		name, rawParams, _ := strings.Cut(key, ":")
+		params, err := indicators.ParseParams(name, rawParams)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if params.String() != rawParams {
+			t.Errorf("%s: canonical params %q", key, params.String())
+		}
+		res, err := indicators.Compute(name, params, ref.Candles)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		for output, want := range series {
+			var defined []float64
+			for _, v := range want {
+				if v != nil {
+					defined = append(defined, *v)
+				}
+			}
+			got := res.Series[output]
+			if len(got) != len(defined) {
+				t.Errorf("%s/%s: got %d points, want %d", key, output, len(got), len(defined))
+				continue
+			}
+			for i, w := range defined {
+				if math.Abs(got[i].Value-w) > 1e-6 {
+					t.Errorf("%s/%s[%d]: got %.8f, want %.8f", key, output, i, got[i].Value, w)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	p, err := indicators.ParseParams("bollinger", "k=2.5")
+	if err != nil || p["period"] != 20 || p["k"] != 2.5 {
+		t.Errorf("expected defaults merged with k, got %v (%v)", p, err)
+	}
+	bad := []struct{ name, params string }{
+		{"rsi", "period:0"},
+		{"rsi", "period:1.5"},
+		{"rsi", "length:14"},
+		{"macd", "fast:30,slow:26"},
+		{"sma", "period:abc"},
+	}
+	for _, b := range bad {
+		if _, err := indicators.ParseParams(b.name, b.params); !errors.Is(err, indicators.ErrInvalidParams) {
+			t.Errorf("%s %q: expected ErrInvalidParams, got %v", b.name, b.params, err)
+		}
+	}
+	if _, err := indicators.ParseParams("stochastic", ""); !errors.Is(err, indicators.ErrUnknownIndicator) {
+		t.Errorf("expected ErrUnknownIndicator, got %v", err)
+	}
+}
+
+type countingProvider struct {
+	stocks.Provider
+	calls int
+}
+
+func (p *countingProvider) Intraday(ctx context.Context, symbol, interval string, limit int) ([]stocks.Candle, error) {
+	p.calls++
+	return stocks.NewMock().Intraday(ctx, symbol, interval, 60)
+}
+
+func TestServiceCachesByCanonicalParams(t *testing.T) {
+	provider := &countingProvider{}
+	svc := indicators.NewService(provider, time.Minute)
+	ctx := context.Background()
+
+	for _, params := range []string{"period:10", "period=10", " PERIOD:10 "} {
+		if _, err := svc.Get(ctx, "AAPL", "daily", "SMA", params); err != nil {
+			t.Fatalf("%q: %v", params, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("expected one upstream fetch, got %d", provider.calls)
+	}
+	svc.Get(ctx, "AAPL", "daily", "sma", "period:20")
+	if provider.calls != 2 {
+		t.Errorf("expected new params to miss the cache, got %d fetches", provider.calls)
+	}
+
+	// Unsupported intervals are refused rather than fetched and cached
+	if _, err := svc.Get(ctx, "AAPL", "1min", "sma", "period:10"); !errors.Is(err, indicators.ErrUnknownInterval) {
+		t.Errorf("expected ErrUnknownInterval, got %v", err)
+	}
+	if _, err := svc.Get(ctx, "AAPL", "DAILY", "sma", "period:10"); err != nil || provider.calls != 2 {
+		t.Errorf("expected DAILY to share the daily cache entry, got %v after %d fetches", err, provider.calls)
+	}
+}
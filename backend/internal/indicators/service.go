@@ -0,0 +1,82 @@
+package indicators
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+// Service computes indicators over provider candles and caches results per
+// (symbol, interval, indicator, params).
+type Service struct {
+	provider stocks.Provider
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewService(provider stocks.Provider, ttl time.Duration) *Service {
+	return &Service{provider: provider, ttl: ttl, cache: make(map[string]cacheEntry)}
+}
+
+// Get returns the named indicator for symbol. rawParams is parsed with
+// ParseParams; equivalent spellings share a cache entry. interval must be
+// one of Intervals.
+func (s *Service) Get(ctx context.Context, symbol, interval, name, rawParams string) (*Result, error) {
+	interval = strings.ToLower(interval)
+	if !slices.Contains(intervals, interval) {
+		return nil, ErrUnknownInterval
+	}
+	name = strings.ToLower(name)
+	params, err := ParseParams(name, rawParams)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.Join([]string{symbol, interval, name, params.String()}, "|")
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.result, nil
+	}
+
+	candles, err := s.provider.Intraday(ctx, symbol, interval, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := Compute(name, params, candles)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.cache {
+		if now.After(e.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cacheEntry{result: result, expires: now.Add(s.ttl)}
+	return result, nil
+}
+
+// Purge drops every cached result, returning how many there were.
+func (s *Service) Purge() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.cache)
+	s.cache = make(map[string]cacheEntry)
+	return n
+}
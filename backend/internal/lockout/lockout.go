@@ -0,0 +1,214 @@
+// Package lockout slows down password guessing. Failed logins are counted
+// per account and per client IP; past a threshold each further failure
+// locks the key out for twice as long as the last, and every attempt is
+// written to an audit log.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrLocked = errors.New("too many failed login attempts")
+
+// Attempt outcomes.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeInvalid  = "invalid_credentials"
+	OutcomeLocked   = "locked"
+	OutcomeDisabled = "disabled"
+	// OutcomeMFARequired is a right password awaiting a second factor. It
+	// neither counts as a failure nor clears earlier ones.
+	OutcomeMFARequired = "mfa_required"
+	// OutcomeMFAVerified is a right code given to change 2FA settings.
+	// Like OutcomeMFARequired it leaves the failure count alone.
+	OutcomeMFAVerified = "mfa_verified"
+)
+
+// Attempt is one login attempt as recorded in the audit log.
+type Attempt struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	UserID    *int      `json:"user_id,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Outcome   string    `json:"outcome"`
+	At        time.Time `json:"at"`
+}
+
+// Counts is the state of a key after Reserve.
+type Counts struct {
+	// Failures is the number of recent consecutive failures.
+	Failures int
+	// Pending is the number of attempts under way, including the one just
+	// reserved.
+	Pending     int
+	LockedUntil time.Time
+}
+
+type Repository interface {
+	// Reserve atomically counts an attempt under way against key, unless
+	// key is locked out. Failures older than window and pending attempts
+	// older than stale are forgotten first.
+	Reserve(ctx context.Context, key string, at time.Time, window, stale time.Duration) (Counts, error)
+	// Release ends a pending attempt that didn't fail.
+	Release(ctx context.Context, key string) error
+	// RecordFailure ends a pending attempt as a failure, restarting the
+	// count if the previous failure is older than window, and returns the
+	// new count.
+	RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	RecordAttempt(ctx context.Context, a *Attempt) error
+}
+
+// Guard tracks failures for accounts and IPs.
+type Guard struct {
+	repo Repository
+	// AccountThreshold and IPThreshold are how many failures are allowed
+	// before lockouts begin. IPs get more room since many users can share
+	// one.
+	AccountThreshold int
+	IPThreshold      int
+	// BaseLockout is the first lockout; each later failure doubles it, up
+	// to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long failures are remembered without another one.
+	Window time.Duration
+}
+
+func NewGuard(repo Repository) *Guard {
+	return &Guard{
+		repo:             repo,
+		AccountThreshold: 5,
+		IPThreshold:      20,
+		BaseLockout:      30 * time.Second,
+		MaxLockout:       time.Hour,
+		Window:           24 * time.Hour,
+	}
+}
+
+// NormalizeEmail is the form emails are tracked and audited under.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountKey(email string) string { return "email:" + NormalizeEmail(email) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// LockDuration is how long to lock a key out after its failures-th
+// consecutive failure.
+func LockDuration(failures, threshold int, base, max time.Duration) time.Duration {
+	if failures <= threshold {
+		return 0
+	}
+	d := base
+	for i := threshold + 1; i < failures; i++ {
+		if d *= 2; d >= max {
+			return max
+		}
+	}
+	return min(d, max)
+}
+
+// pendingTimeout is how long a reserved attempt counts as under way if
+// it is never recorded, e.g. because its request failed.
+const pendingTimeout = time.Minute
+
+// Check returns ErrLocked, and how long until the lockout ends, if either
+// the account or the IP is locked out. It applies whether or not the
+// account exists.
+//
+// Otherwise the attempt is reserved against both, and must be followed by
+// a Record of how it went. Reserving and checking are one step so parallel
+// guesses can't all pass the check before any failure lands: no more
+// attempts may be under way than there are failures left before a
+// lockout, or one once it has expired.
+func (g *Guard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	var reserved []string
+	for _, k := range []struct {
+		key       string
+		threshold int
+	}{{accountKey(email), g.AccountThreshold}, {ipKey(ip), g.IPThreshold}} {
+		counts, err := g.repo.Reserve(ctx, k.key, now, g.Window, pendingTimeout)
+		var wait time.Duration
+		switch {
+		case err != nil:
+		case counts.LockedUntil.After(now):
+			wait, err = counts.LockedUntil.Sub(now), ErrLocked
+		case counts.Pending > max(k.threshold+1-counts.Failures, 1):
+			reserved = append(reserved, k.key)
+			wait, err = g.BaseLockout, ErrLocked
+		default:
+			reserved = append(reserved, k.key)
+			continue
+		}
+		if rerr := g.release(ctx, reserved...); rerr != nil {
+			return 0, rerr
+		}
+		return wait, err
+	}
+	return 0, nil
+}
+
+func (g *Guard) release(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := g.repo.Release(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record audits a and updates the failure counts. Invalid credentials count
+// against both the account and IP; a success clears the account's count.
+// Any attempt Check reserved is ended.
+func (g *Guard) Record(ctx context.Context, a Attempt) error {
+	a.Email = NormalizeEmail(a.Email)
+	if a.At.IsZero() {
+		a.At = time.Now()
+	}
+	if err := g.repo.RecordAttempt(ctx, &a); err != nil {
+		return err
+	}
+
+	switch a.Outcome {
+	case OutcomeSuccess:
+		if err := g.repo.Reset(ctx, accountKey(a.Email)); err != nil {
+			return err
+		}
+		return g.repo.Release(ctx, ipKey(a.IP))
+	case OutcomeDisabled, OutcomeMFARequired, OutcomeMFAVerified:
+		return g.release(ctx, accountKey(a.Email), ipKey(a.IP))
+	case OutcomeInvalid:
+		if err := g.fail(ctx, accountKey(a.Email), g.AccountThreshold, a.At); err != nil {
+			return err
+		}
+		return g.fail(ctx, ipKey(a.IP), g.IPThreshold, a.At)
+	}
+	return nil
+}
+
+// Clear forgets the failures counted against email, ending any lockout,
+// e.g. once its owner has reset their password.
+func (g *Guard) Clear(ctx context.Context, email string) error {
+	return g.repo.Reset(ctx, accountKey(email))
+}
+
+func (g *Guard) fail(ctx context.Context, key string, threshold int, at time.Time) error {
+	failures, err := g.repo.RecordFailure(ctx, key, at, g.Window)
+	if err != nil {
+		return err
+	}
+	if d := LockDuration(failures, threshold, g.BaseLockout, g.MaxLockout); d > 0 {
+		if err := g.repo.Lock(ctx, key, at.Add(d)); err != nil {
+			return fmt.Errorf("failed to lock %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package lockout_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/lockout"
+)
+
+type memEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+	pending     int
+	pendingAt   time.Time
+}
+
+type memRepo struct {
+	entries  map[string]*memEntry
+	attempts []lockout.Attempt
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{entries: map[string]*memEntry{}}
+}
+
+func (m *memRepo) entry(key string) *memEntry {
+	e, ok := m.entries[key]
+	if !ok {
+		e = &memEntry{}
+		m.entries[key] = e
+	}
+	return e
+}
+
+func (m *memRepo) Reserve(ctx context.Context, key string, at time.Time, window, stale time.Duration) (lockout.Counts, error) {
+	e := m.entry(key)
+	if e.lastFailure.Before(at.Add(-window)) {
+		e.failures = 0
+	}
+	if !e.lockedUntil.After(at) {
+		if e.pendingAt.Before(at.Add(-stale)) {
+			e.pending = 0
+		}
+		e.pending++
+		e.pendingAt = at
+	}
+	return lockout.Counts{Failures: e.failures, Pending: e.pending, LockedUntil: e.lockedUntil}, nil
+}
+
+func (m *memRepo) Release(ctx context.Context, key string) error {
+	if e, ok := m.entries[key]; ok {
+		e.pending = max(e.pending-1, 0)
+	}
+	return nil
+}
+
+func (m *memRepo) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (int, error) {
+	e := m.entry(key)
+	if e.lastFailure.Before(at.Add(-window)) {
+		e.failures = 0
+	}
+	e.failures++
+	e.lastFailure = at
+	e.pending = max(e.pending-1, 0)
+	return e.failures, nil
+}
+
+func (m *memRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	m.entries[key].lockedUntil = until
+	return nil
+}
+
+func (m *memRepo) Reset(ctx context.Context, key string) error {
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *memRepo) RecordAttempt(ctx context.Context, a *lockout.Attempt) error {
+	a.ID = len(m.attempts) + 1
+	m.attempts = append(m.attempts, *a)
+	return nil
+}
+
+func TestLockDuration(t *testing.T) {
+	base, max := 30*time.Second, 10*time.Minute
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{5, 0},
+		{6, 30 * time.Second},
+		{7, time.Minute},
+		{8, 2 * time.Minute},
+		{10, 8 * time.Minute},
+		{11, max},
+		{100, max},
+	}
+	for _, tt := range tests {
+		if got := lockout.LockDuration(tt.failures, 5, base, max); got != tt.want {
+			t.Errorf("LockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func fail(t *testing.T, g *lockout.Guard, email, ip string) {
+	t.Helper()
+	if err := g.Record(context.Background(), lockout.Attempt{Email: email, IP: ip, Outcome: lockout.OutcomeInvalid}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+}
+
+func TestGuardLocksAccount(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemRepo()
+	g := lockout.NewGuard(repo)
+	g.AccountThreshold = 3
+
+	for i := 0; i < 3; i++ {
+		fail(t, g, "Alice@Example.com", "10.0.0.1")
+	}
+	if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("locked before threshold: %v", err)
+	}
+
+	fail(t, g, "alice@example.com ", "10.0.0.2")
+	wait, err := g.Check(ctx, "ALICE@example.com", "10.0.0.3")
+	if !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("Check = %v, want ErrLocked", err)
+	}
+	if wait <= 0 || wait > g.BaseLockout {
+		t.Errorf("wait = %v, want up to %v", wait, g.BaseLockout)
+	}
+
+	// Other accounts from the same IPs are unaffected
+	if _, err := g.Check(ctx, "bob@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("other account locked: %v", err)
+	}
+
+	if len(repo.attempts) != 4 || repo.attempts[0].Email != "alice@example.com" {
+		t.Errorf("attempts = %+v, want 4 audited under the normalized email", repo.attempts)
+	}
+}
+
+func TestGuardLocksIP(t *testing.T) {
+	ctx := context.Background()
+	g := lockout.NewGuard(newMemRepo())
+	g.IPThreshold = 2
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		fail(t, g, email, "10.0.0.1")
+	}
+	if _, err := g.Check(ctx, "d@example.com", "10.0.0.1"); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("Check = %v, want ErrLocked for the IP", err)
+	}
+	if _, err := g.Check(ctx, "d@example.com", "10.0.0.2"); err != nil {
+		t.Errorf("other IP locked: %v", err)
+	}
+}
+
+func TestGuardSuccessResetsAccount(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemRepo()
+	g := lockout.NewGuard(repo)
+	g.AccountThreshold = 2
+
+	fail(t, g, "alice@example.com", "10.0.0.1")
+	fail(t, g, "alice@example.com", "10.0.0.1")
+	userID := 7
+	if err := g.Record(ctx, lockout.Attempt{Email: "alice@example.com", IP: "10.0.0.1", UserID: &userID, Outcome: lockout.OutcomeSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	fail(t, g, "alice@example.com", "10.0.0.1")
+	if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Check after success = %v, want count restarted", err)
+	}
+	if got := repo.attempts[2]; got.Outcome != lockout.OutcomeSuccess || got.UserID == nil || *got.UserID != 7 {
+		t.Errorf("success attempt = %+v", got)
+	}
+}
+
+func TestGuardForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	g := lockout.NewGuard(newMemRepo())
+	g.AccountThreshold = 1
+
+	old := time.Now().Add(-2 * g.Window)
+	for i := 0; i < 3; i++ {
+		g.Record(ctx, lockout.Attempt{Email: "alice@example.com", IP: "10.0.0.1", Outcome: lockout.OutcomeInvalid, At: old})
+	}
+	fail(t, g, "alice@example.com", "10.0.0.1")
+	if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Check = %v, want failures outside the window forgotten", err)
+	}
+}
+
+func TestGuardLimitsParallelAttempts(t *testing.T) {
+	ctx := context.Background()
+	g := lockout.NewGuard(newMemRepo())
+	g.AccountThreshold = 2
+
+	// Three failures are allowed before a lockout, so three guesses may be
+	// in flight at once but not a fourth
+	for i := 0; i < 3; i++ {
+		if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Check %d = %v", i+1, err)
+		}
+	}
+	if _, err := g.Check(ctx, "alice@example.com", "10.0.0.2"); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("fourth parallel Check = %v, want ErrLocked", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		fail(t, g, "alice@example.com", "10.0.0.1")
+	}
+	if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("Check after failures = %v, want ErrLocked", err)
+	}
+}
+
+func TestGuardReleasesAttemptsThatDidNotFail(t *testing.T) {
+	ctx := context.Background()
+	g := lockout.NewGuard(newMemRepo())
+	g.AccountThreshold = 0
+
+	// With no failures to spare, attempts go one at a time
+	for i, outcome := range []string{lockout.OutcomeMFARequired, lockout.OutcomeMFAVerified, lockout.OutcomeDisabled, lockout.OutcomeMFARequired} {
+		if _, err := g.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Check %d = %v", i+1, err)
+		}
+		if err := g.Record(ctx, lockout.Attempt{Email: "alice@example.com", IP: "10.0.0.1", Outcome: outcome}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
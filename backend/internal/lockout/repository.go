@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) Reserve(ctx context.Context, key string, at time.Time, window, stale time.Duration) (Counts, error) {
+	var c Counts
+	var until *time.Time
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO login_failures (key, failures, last_failure_at, pending, pending_at) VALUES ($1, 0, $2, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_failures.last_failure_at < $2 - $3 * INTERVAL '1 second' THEN 0
+				ELSE login_failures.failures
+			END,
+			pending = CASE
+				WHEN login_failures.locked_until > $2 THEN login_failures.pending
+				WHEN login_failures.pending_at < $2 - $4 * INTERVAL '1 second' THEN 1
+				ELSE login_failures.pending + 1
+			END,
+			pending_at = CASE
+				WHEN login_failures.locked_until > $2 THEN login_failures.pending_at
+				ELSE $2
+			END
+		RETURNING failures, pending, locked_until`, key, at, window.Seconds(), stale.Seconds()).Scan(&c.Failures, &c.Pending, &until)
+	if err != nil {
+		return Counts{}, fmt.Errorf("failed to check lockout: %w", err)
+	}
+	if until != nil {
+		c.LockedUntil = *until
+	}
+	return c, nil
+}
+
+func (r *PostgresRepository) Release(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, "UPDATE login_failures SET pending = GREATEST(pending - 1, 0) WHERE key = $1", key); err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO login_failures (key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_failures.last_failure_at < $2 - $3 * INTERVAL '1 second' THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failure_at = $2,
+			pending = GREATEST(login_failures.pending - 1, 0)
+		RETURNING failures`, key, at, window.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *PostgresRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE login_failures SET locked_until = $2 WHERE key = $1", key, until)
+	return err
+}
+
+func (r *PostgresRepository) Reset(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM login_failures WHERE key = $1", key); err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RecordAttempt(ctx context.Context, a *Attempt) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO login_attempts (email, user_id, ip, user_agent, outcome, at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		a.Email, a.UserID, a.IP, a.UserAgent, a.Outcome, a.At).Scan(&a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package market
+
+import (
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+)
+
+// NewYork is the exchange timezone for NYSE and NASDAQ.
+var NewYork = mustLoadLocation("America/New_York")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// Session boundaries, New York local time.
+const (
+	preMarketOpen  = 4 * time.Hour
+	regularOpen    = 9*time.Hour + 30*time.Minute
+	regularClose   = 16 * time.Hour
+	earlyClose     = 13 * time.Hour
+	postMarketEnd  = 20 * time.Hour
+	earlyPostClose = 17 * time.Hour
+)
+
+// Date returns midnight New York time on the exchange day containing t.
+func Date(t time.Time) time.Time {
+	y, m, d := t.In(NewYork).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, NewYork)
+}
+
+// Holiday reports whether the exchange is closed all day on the New York
+// date of t, and which holiday it is.
+func Holiday(t time.Time) (string, bool) {
+	day := Date(t)
+	for _, h := range holidays(day.Year()) {
+		if h.date.Equal(day) {
+			return h.name, true
+		}
+	}
+	return "", false
+}
+
+// IsTradingDay reports whether the exchange has a session on the New York
+// date of t.
+func IsTradingDay(t time.Time) bool {
+	day := Date(t)
+	if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, closed := Holiday(day)
+	return !closed
+}
+
+// PreviousTradingDay returns the last trading day strictly before t's date.
+func PreviousTradingDay(t time.Time) time.Time {
+	day := Date(t).AddDate(0, 0, -1)
+	for !IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// NextTradingDay returns the first trading day strictly after t's date.
+func NextTradingDay(t time.Time) time.Time {
+	day := Date(t).AddDate(0, 0, 1)
+	for !IsTradingDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// EarlyClose reports whether the regular session on day's New York date
+// ends at 13:00: July 3 before a weekday Independence Day, the day after
+// Thanksgiving, and Christmas Eve.
+func EarlyClose(day time.Time) bool {
+	day = Date(day)
+	if !IsTradingDay(day) {
+		return false
+	}
+	switch m, d := day.Month(), day.Day(); {
+	case m == time.July && d == 3:
+		return day.Weekday() != time.Friday
+	case m == time.November:
+		return day.AddDate(0, 0, -1).Equal(nthWeekday(day.Year(), time.November, time.Thursday, 4))
+	case m == time.December && d == 24:
+		return true
+	}
+	return false
+}
+
+// Open returns the regular-session open on day's New York date.
+func Open(day time.Time) time.Time {
+	return Date(day).Add(regularOpen)
+}
+
+// Close returns the regular-session close on day's New York date, taking
+// early closes into account.
+func Close(day time.Time) time.Time {
+	if EarlyClose(day) {
+		return Date(day).Add(earlyClose)
+	}
+	return Date(day).Add(regularClose)
+}
+
+type holiday struct {
+	name string
+	date time.Time
+}
+
+// holidays lists the full-day NYSE closures for year. NASDAQ follows the
+// same schedule. Fixed-date holidays falling on a weekend are observed on
+// the nearest weekday, except that New Year's Day on a Saturday is not
+// observed (the exchange does not close on the last day of the year).
+func holidays(year int) []holiday {
+	date := func(m time.Month, d int) time.Time { return time.Date(year, m, d, 0, 0, 0, 0, NewYork) }
+
+	list := []holiday{
+		{"Martin Luther King Jr. Day", nthWeekday(year, time.January, time.Monday, 3)},
+		{"Washington's Birthday", nthWeekday(year, time.February, time.Monday, 3)},
+		{"Good Friday", easter(year).AddDate(0, 0, -2)},
+		{"Memorial Day", lastWeekday(year, time.May, time.Monday)},
+		{"Independence Day", observed(date(time.July, 4))},
+		{"Labor Day", nthWeekday(year, time.September, time.Monday, 1)},
+		{"Thanksgiving Day", nthWeekday(year, time.November, time.Thursday, 4)},
+		{"Christmas Day", observed(date(time.December, 25))},
+	}
+	if newYear := date(time.January, 1); newYear.Weekday() != time.Saturday {
+		list = append(list, holiday{"New Year's Day", observed(newYear)})
+	}
+	if year >= 2022 {
+		list = append(list, holiday{"Juneteenth", observed(date(time.June, 19))})
+	}
+	return list
+}
+
+// observed moves a Saturday holiday to Friday and a Sunday one to Monday.
+func observed(d time.Time) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+func nthWeekday(year int, month time.Month, wd time.Weekday, n int) time.Time {
+	d := time.Date(year, month, 1, 0, 0, 0, 0, NewYork)
+	for d.Weekday() != wd {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d.AddDate(0, 0, 7*(n-1))
+}
+
+func lastWeekday(year int, month time.Month, wd time.Weekday) time.Time {
+	d := time.Date(year, month+1, 0, 0, 0, 0, 0, NewYork)
+	for d.Weekday() != wd {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// easter returns Western Easter Sunday (anonymous Gregorian algorithm).
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, NewYork)
+}
@@ -0,0 +1,119 @@
+package market_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/market"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 12, 0, 0, 0, market.NewYork)
+}
+
+func TestHolidays(t *testing.T) {
+	closed := []time.Time{
+		day(2025, time.January, 1),
+		day(2025, time.January, 20),  // MLK
+		day(2025, time.April, 18),    // Good Friday
+		day(2025, time.May, 26),      // Memorial Day
+		day(2025, time.June, 19),     // Juneteenth
+		day(2026, time.July, 3),      // Independence Day observed (Sat)
+		day(2026, time.November, 26), // Thanksgiving
+		day(2022, time.December, 26), // Christmas observed (Sun)
+		day(2026, time.April, 3),     // Good Friday
+	}
+	for _, d := range closed {
+		if market.IsTradingDay(d) {
+			t.Errorf("%s should be a holiday", d.Format("2006-01-02"))
+		}
+	}
+
+	open := []time.Time{
+		day(2021, time.December, 31), // New Year's 2022 falls on Saturday: not observed
+		day(2021, time.June, 18),     // before Juneteenth was adopted
+		day(2025, time.November, 28), // day after Thanksgiving
+	}
+	for _, d := range open {
+		if !market.IsTradingDay(d) {
+			t.Errorf("%s should be a trading day", d.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestAdjacentTradingDays(t *testing.T) {
+	// Thursday 2026-04-02 -> Good Friday -> weekend -> Monday 2026-04-06.
+	next := market.NextTradingDay(day(2026, time.April, 2))
+	if want := time.Date(2026, time.April, 6, 0, 0, 0, 0, market.NewYork); !next.Equal(want) {
+		t.Errorf("next trading day: got %s, want %s", next, want)
+	}
+	prev := market.PreviousTradingDay(next)
+	if want := time.Date(2026, time.April, 2, 0, 0, 0, 0, market.NewYork); !prev.Equal(want) {
+		t.Errorf("previous trading day: got %s, want %s", prev, want)
+	}
+}
+
+func at(y int, m time.Month, d, hh, mm int) time.Time {
+	return time.Date(y, m, d, hh, mm, 0, 0, market.NewYork)
+}
+
+func TestEarlyCloses(t *testing.T) {
+	early := []time.Time{
+		day(2025, time.July, 3),
+		day(2025, time.November, 28),
+		day(2025, time.December, 24),
+	}
+	for _, d := range early {
+		if !market.EarlyClose(d) {
+			t.Errorf("%s should close early", d.Format("2006-01-02"))
+		}
+		if c := market.Close(d); c.Hour() != 13 {
+			t.Errorf("%s: expected 13:00 close, got %s", d.Format("2006-01-02"), c)
+		}
+	}
+	// July 3, 2026 is the observed holiday itself; Christmas Eve 2027 is a
+	// Friday and observed Christmas.
+	for _, d := range []time.Time{day(2026, time.July, 3), day(2027, time.December, 24), day(2025, time.July, 2)} {
+		if market.EarlyClose(d) {
+			t.Errorf("%s should not be an early close", d.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestSessionAt(t *testing.T) {
+	cases := []struct {
+		t    time.Time
+		want market.Session
+	}{
+		{at(2026, time.March, 4, 3, 59), market.SessionClosed},
+		{at(2026, time.March, 4, 4, 0), market.SessionPreMarket},
+		{at(2026, time.March, 4, 9, 30), market.SessionRegular},
+		{at(2026, time.March, 4, 15, 59), market.SessionRegular},
+		{at(2026, time.March, 4, 16, 0), market.SessionPostMarket},
+		{at(2026, time.March, 4, 20, 0), market.SessionClosed},
+		{at(2026, time.March, 7, 12, 0), market.SessionClosed},          // Saturday
+		{at(2025, time.November, 28, 13, 30), market.SessionPostMarket}, // early close
+		{at(2025, time.November, 28, 17, 30), market.SessionClosed},
+		// Across the DST change: 09:30 local on the Monday after it.
+		{at(2026, time.March, 9, 9, 30), market.SessionRegular},
+	}
+	for _, c := range cases {
+		if got := market.SessionAt(c.t); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.t.Format(time.RFC3339), got, c.want)
+		}
+	}
+}
+
+func TestStatusAcrossWeekend(t *testing.T) {
+	friday := at(2026, time.March, 6, 17, 0).UTC()
+	st := market.StatusAt(friday)
+	if st.IsOpen || st.Session != market.SessionPostMarket {
+		t.Errorf("unexpected session %+v", st)
+	}
+	if want := at(2026, time.March, 9, 9, 30); !st.NextOpen.Equal(want) {
+		t.Errorf("next open: got %s, want %s", st.NextOpen, want)
+	}
+	if want := at(2026, time.March, 6, 16, 0); !market.LastClose(friday).Equal(want) {
+		t.Errorf("last close: got %s, want %s", market.LastClose(friday), want)
+	}
+}
@@ -0,0 +1,107 @@
+package market
+
+import "time"
+
+// Exchanges covered by the calendar. NYSE and NASDAQ share sessions and
+// holidays.
+var Exchanges = []string{"NYSE", "NASDAQ"}
+
+type Session string
+
+const (
+	SessionPreMarket  Session = "pre-market"
+	SessionRegular    Session = "regular"
+	SessionPostMarket Session = "post-market"
+	SessionClosed     Session = "closed"
+)
+
+// SessionAt returns the trading session in progress at t.
+func SessionAt(t time.Time) Session {
+	if !IsTradingDay(t) {
+		return SessionClosed
+	}
+	day := Date(t)
+	postEnd := postMarketEnd
+	if EarlyClose(day) {
+		postEnd = earlyPostClose
+	}
+	switch {
+	case t.Before(day.Add(preMarketOpen)):
+		return SessionClosed
+	case t.Before(Open(day)):
+		return SessionPreMarket
+	case t.Before(Close(day)):
+		return SessionRegular
+	case t.Before(day.Add(postEnd)):
+		return SessionPostMarket
+	}
+	return SessionClosed
+}
+
+// IsOpen reports whether the regular session is in progress at t.
+func IsOpen(t time.Time) bool {
+	return SessionAt(t) == SessionRegular
+}
+
+// NextOpen returns the first regular-session open after t.
+func NextOpen(t time.Time) time.Time {
+	if IsTradingDay(t) && t.Before(Open(t)) {
+		return Open(t)
+	}
+	return Open(NextTradingDay(t))
+}
+
+// NextClose returns the first regular-session close after t.
+func NextClose(t time.Time) time.Time {
+	if IsTradingDay(t) && t.Before(Close(t)) {
+		return Close(t)
+	}
+	return Close(NextTradingDay(t))
+}
+
+// LastClose returns the most recent regular-session close at or before t.
+func LastClose(t time.Time) time.Time {
+	if IsTradingDay(t) && !t.Before(Close(t)) {
+		return Close(t)
+	}
+	return Close(PreviousTradingDay(t))
+}
+
+// Status describes the market at a moment, as served by the API.
+type Status struct {
+	Exchanges    []string   `json:"exchanges"`
+	Timezone     string     `json:"timezone"`
+	Now          time.Time  `json:"now"`
+	Session      Session    `json:"session"`
+	IsOpen       bool       `json:"is_open"`
+	IsTradingDay bool       `json:"is_trading_day"`
+	Holiday      string     `json:"holiday,omitempty"`
+	EarlyClose   bool       `json:"early_close"`
+	Open         *time.Time `json:"open,omitempty"`
+	Close        *time.Time `json:"close,omitempty"`
+	NextOpen     time.Time  `json:"next_open"`
+	NextClose    time.Time  `json:"next_close"`
+}
+
+// StatusAt returns the market status at t. Times are in New York time.
+func StatusAt(t time.Time) Status {
+	t = t.In(NewYork)
+	session := SessionAt(t)
+	st := Status{
+		Exchanges:    Exchanges,
+		Timezone:     NewYork.String(),
+		Now:          t,
+		Session:      session,
+		IsOpen:       session == SessionRegular,
+		IsTradingDay: IsTradingDay(t),
+		EarlyClose:   EarlyClose(t),
+		NextOpen:     NextOpen(t),
+		NextClose:    NextClose(t),
+	}
+	st.Holiday, _ = Holiday(t)
+	if st.IsTradingDay {
+		open, close := Open(t), Close(t)
+		st.Open, st.Close = &open, &close
+	}
+	return st
+}
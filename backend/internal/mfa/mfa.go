@@ -0,0 +1,81 @@
+// Package mfa adds optional TOTP two-factor authentication. Users enroll
+// with an authenticator app, confirm with a code and receive single-use
+// recovery codes; logins for enrolled users then go through a short-lived
+// challenge that is exchanged, together with a code, for a session.
+package mfa
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotEnrolled      = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrInvalidCode      = errors.New("invalid code")
+	ErrInvalidChallenge = errors.New("invalid or expired challenge")
+)
+
+// TOTP is a user's authenticator secret. It is pending until ConfirmedAt
+// is set.
+type TOTP struct {
+	UserID       int
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// Status is what a user sees about their own 2FA setup.
+type Status struct {
+	Enabled                bool       `json:"enabled"`
+	Pending                bool       `json:"pending"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// Enrollment is returned when a user starts enrolling. The secret is shown
+// once, for apps that can't scan the URI.
+type Enrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Challenge is a pending second login step. Only its hash is stored.
+type Challenge struct {
+	Hash      []byte
+	UserID    int
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+type Repository interface {
+	// SetPending stores a new unconfirmed secret, replacing any earlier
+	// pending one. It returns ErrAlreadyEnabled if 2FA is confirmed.
+	SetPending(ctx context.Context, userID int, secret string) error
+	// GetTOTP returns ErrNotEnrolled if the user has no secret.
+	GetTOTP(ctx context.Context, userID int) (*TOTP, error)
+	// Confirm enables a pending secret and stores its recovery codes.
+	Confirm(ctx context.Context, userID int, step int64, codeHashes [][]byte, at time.Time) error
+	// UseStep records step as used, reporting false if it or a later step
+	// already was.
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error
+	// UseRecoveryCode marks a matching unused code used.
+	UseRecoveryCode(ctx context.Context, userID int, codeHash []byte, at time.Time) (bool, error)
+	RemainingRecoveryCodes(ctx context.Context, userID int) (int, error)
+	// Delete removes the user's secret and recovery codes.
+	Delete(ctx context.Context, userID int) error
+
+	CreateChallenge(ctx context.Context, c *Challenge) error
+	// GetChallenge returns ErrInvalidChallenge unless an unexpired
+	// challenge matches.
+	GetChallenge(ctx context.Context, hash []byte, now time.Time) (*Challenge, error)
+	// FailChallenge counts a wrong code, discarding the challenge after
+	// maxAttempts.
+	FailChallenge(ctx context.Context, hash []byte, maxAttempts int) error
+	// DeleteChallenge reports whether the challenge was still there, so only
+	// one redemption can win.
+	DeleteChallenge(ctx context.Context, hash []byte) (bool, error)
+}
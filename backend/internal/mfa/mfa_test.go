@@ -0,0 +1,306 @@
+package mfa_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/mfa"
+)
+
+// memRepo is an in-memory mfa.Repository.
+type memRepo struct {
+	totp       map[int]*mfa.TOTP
+	recovery   map[int][]*memCode
+	challenges []*mfa.Challenge
+}
+
+type memCode struct {
+	hash []byte
+	used bool
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{totp: map[int]*mfa.TOTP{}, recovery: map[int][]*memCode{}}
+}
+
+func (m *memRepo) SetPending(ctx context.Context, userID int, secret string) error {
+	if t, ok := m.totp[userID]; ok && t.ConfirmedAt != nil {
+		return mfa.ErrAlreadyEnabled
+	}
+	m.totp[userID] = &mfa.TOTP{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *memRepo) GetTOTP(ctx context.Context, userID int) (*mfa.TOTP, error) {
+	t, ok := m.totp[userID]
+	if !ok {
+		return nil, mfa.ErrNotEnrolled
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (m *memRepo) Confirm(ctx context.Context, userID int, step int64, codeHashes [][]byte, at time.Time) error {
+	t := m.totp[userID]
+	t.ConfirmedAt, t.LastUsedStep = &at, step
+	return m.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+}
+
+func (m *memRepo) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	t := m.totp[userID]
+	if t.LastUsedStep >= step {
+		return false, nil
+	}
+	t.LastUsedStep = step
+	return true, nil
+}
+
+func (m *memRepo) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error {
+	m.recovery[userID] = nil
+	for _, h := range codeHashes {
+		m.recovery[userID] = append(m.recovery[userID], &memCode{hash: h})
+	}
+	return nil
+}
+
+func (m *memRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash []byte, at time.Time) (bool, error) {
+	for _, c := range m.recovery[userID] {
+		if !c.used && bytes.Equal(c.hash, codeHash) {
+			c.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memRepo) RemainingRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	n := 0
+	for _, c := range m.recovery[userID] {
+		if !c.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memRepo) Delete(ctx context.Context, userID int) error {
+	delete(m.totp, userID)
+	delete(m.recovery, userID)
+	return nil
+}
+
+func (m *memRepo) CreateChallenge(ctx context.Context, c *mfa.Challenge) error {
+	m.challenges = append(m.challenges, c)
+	return nil
+}
+
+func (m *memRepo) find(hash []byte) (int, *mfa.Challenge) {
+	for i, c := range m.challenges {
+		if bytes.Equal(c.Hash, hash) {
+			return i, c
+		}
+	}
+	return -1, nil
+}
+
+func (m *memRepo) GetChallenge(ctx context.Context, hash []byte, now time.Time) (*mfa.Challenge, error) {
+	if _, c := m.find(hash); c != nil && c.ExpiresAt.After(now) {
+		return c, nil
+	}
+	return nil, mfa.ErrInvalidChallenge
+}
+
+func (m *memRepo) FailChallenge(ctx context.Context, hash []byte, maxAttempts int) error {
+	if _, c := m.find(hash); c != nil {
+		if c.Attempts++; c.Attempts >= maxAttempts {
+			m.DeleteChallenge(ctx, hash)
+		}
+	}
+	return nil
+}
+
+func (m *memRepo) DeleteChallenge(ctx context.Context, hash []byte) (bool, error) {
+	i, _ := m.find(hash)
+	if i < 0 {
+		return false, nil
+	}
+	m.challenges = append(m.challenges[:i], m.challenges[i+1:]...)
+	return true, nil
+}
+
+// TestCodeRFC6238 uses the SHA1 vectors from RFC 6238 appendix B, cut to
+// six digits.
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := mfa.Code(secret, mfa.Step(time.Unix(tt.unix, 0)))
+		if err != nil || got != tt.want {
+			t.Errorf("Code at %d = %q, %v; want %q", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateAllowsSkew(t *testing.T) {
+	secret, _ := mfa.GenerateSecret()
+	now := time.Unix(1_700_000_000, 0)
+	step := mfa.Step(now)
+
+	for _, d := range []int64{-1, 0, 1} {
+		code, _ := mfa.Code(secret, step+d)
+		if got, ok := mfa.Validate(secret, code, now); !ok || got != step+d {
+			t.Errorf("Validate code from step %+d = %d, %v", d, got, ok)
+		}
+	}
+	code, _ := mfa.Code(secret, step+2)
+	if _, ok := mfa.Validate(secret, code, now); ok {
+		t.Error("code two steps ahead accepted")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(mfa.ProvisioningURI("gostocks", "a@example.com", "ABCDEF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/gostocks:a@example.com" {
+		t.Errorf("unexpected uri %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "ABCDEF" || q.Get("issuer") != "gostocks" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected query %v", q)
+	}
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := mfa.Code(secret, mfa.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enroll enables 2FA for user 1 and returns its secret and recovery codes.
+func enroll(t *testing.T, svc *mfa.Service) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	e, err := svc.Enroll(ctx, 1, "a@example.com")
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if _, err := svc.Confirm(ctx, 1, "abcdef"); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("Confirm with bad code = %v", err)
+	}
+	codes, err := svc.Confirm(ctx, 1, currentCode(t, e.Secret))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return e.Secret, codes
+}
+
+func TestEnrollAndVerify(t *testing.T) {
+	ctx := context.Background()
+	svc := mfa.NewService(newMemRepo())
+
+	if on, _ := svc.Enabled(ctx, 1); on {
+		t.Fatal("enabled before enrolling")
+	}
+	secret, codes := enroll(t, svc)
+	if len(codes) != 10 {
+		t.Fatalf("got %d recovery codes", len(codes))
+	}
+	if on, _ := svc.Enabled(ctx, 1); !on {
+		t.Fatal("not enabled after confirming")
+	}
+	if _, err := svc.Enroll(ctx, 1, "a@example.com"); !errors.Is(err, mfa.ErrAlreadyEnabled) {
+		t.Errorf("re-Enroll = %v, want ErrAlreadyEnabled", err)
+	}
+
+	// The code used to confirm can't be replayed
+	if err := svc.Verify(ctx, 1, currentCode(t, secret)); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("replayed code = %v, want ErrInvalidCode", err)
+	}
+
+	// Recovery codes work once, with or without the dash and in any case
+	if err := svc.Verify(ctx, 1, codes[0]); err != nil {
+		t.Errorf("recovery code: %v", err)
+	}
+	if err := svc.Verify(ctx, 1, codes[0]); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("reused recovery code = %v", err)
+	}
+	loose := codes[1][:5] + " " + codes[1][6:]
+	if err := svc.Verify(ctx, 1, loose); err != nil {
+		t.Errorf("recovery code without dash: %v", err)
+	}
+	status, _ := svc.Status(ctx, 1)
+	if !status.Enabled || status.RecoveryCodesRemaining != 8 {
+		t.Errorf("status = %+v", status)
+	}
+
+	if err := svc.Disable(ctx, 1, "nope"); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("Disable with bad code = %v", err)
+	}
+	if err := svc.Disable(ctx, 1, codes[2]); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if on, _ := svc.Enabled(ctx, 1); on {
+		t.Error("still enabled after Disable")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	ctx := context.Background()
+	svc := mfa.NewService(newMemRepo())
+	svc.MaxChallengeAttempts = 2
+	_, codes := enroll(t, svc)
+
+	token, _, err := svc.Challenge(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := svc.ChallengeUser(ctx, token); err != nil || id != 1 {
+		t.Fatalf("ChallengeUser = %d, %v", id, err)
+	}
+	if _, err := svc.Redeem(ctx, "bogus", codes[0]); !errors.Is(err, mfa.ErrInvalidChallenge) {
+		t.Errorf("Redeem bogus = %v", err)
+	}
+	if id, err := svc.Redeem(ctx, token, codes[0]); err != nil || id != 1 {
+		t.Fatalf("Redeem = %d, %v", id, err)
+	}
+	if _, err := svc.Redeem(ctx, token, codes[1]); !errors.Is(err, mfa.ErrInvalidChallenge) {
+		t.Errorf("second Redeem = %v, want ErrInvalidChallenge", err)
+	}
+
+	// Too many wrong codes burn the challenge
+	token, _, _ = svc.Challenge(ctx, 1)
+	for range 2 {
+		if _, err := svc.Redeem(ctx, token, "abcdef"); !errors.Is(err, mfa.ErrInvalidCode) {
+			t.Fatalf("Redeem wrong code = %v", err)
+		}
+	}
+	if _, err := svc.Redeem(ctx, token, codes[1]); !errors.Is(err, mfa.ErrInvalidChallenge) {
+		t.Errorf("Redeem after max attempts = %v, want ErrInvalidChallenge", err)
+	}
+
+	svc.ChallengeTTL = -time.Second
+	token, _, _ = svc.Challenge(ctx, 1)
+	if _, err := svc.Redeem(ctx, token, codes[1]); !errors.Is(err, mfa.ErrInvalidChallenge) {
+		t.Errorf("Redeem expired = %v, want ErrInvalidChallenge", err)
+	}
+}
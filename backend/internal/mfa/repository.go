@@ -0,0 +1,197 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) SetPending(ctx context.Context, userID int, secret string) error {
+	var id int
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL
+		RETURNING user_id`, userID, secret).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyEnabled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetTOTP(ctx context.Context, userID int) (*TOTP, error) {
+	t := TOTP{UserID: userID}
+	err := r.db.QueryRow(ctx,
+		"SELECT secret, confirmed_at, last_used_step, created_at FROM user_totp WHERE user_id = $1",
+		userID).Scan(&t.Secret, &t.ConfirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *PostgresRepository) Confirm(ctx context.Context, userID int, step int64, codeHashes [][]byte, at time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE user_totp SET confirmed_at = $2, last_used_step = $3 WHERE user_id = $1 AND confirmed_at IS NULL",
+		userID, at, step)
+	if err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyEnabled
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		"UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2",
+		userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp use: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int, codeHashes [][]byte) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(ctx, "INSERT INTO mfa_recovery_codes (user_id, hash) VALUES ($1, $2)", userID, h); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash []byte, at time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		"UPDATE mfa_recovery_codes SET used_at = $3 WHERE user_id = $1 AND hash = $2 AND used_at IS NULL",
+		userID, codeHash, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresRepository) RemainingRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx,
+		"SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, userID int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete totp secret: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) CreateChallenge(ctx context.Context, c *Challenge) error {
+	// Expired challenges are only ever looked up by their owner's logins, so
+	// clear them out here
+	if _, err := r.db.Exec(ctx, "DELETE FROM mfa_challenges WHERE user_id = $1 AND expires_at < NOW()", c.UserID); err != nil {
+		return fmt.Errorf("failed to prune challenges: %w", err)
+	}
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO mfa_challenges (hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		c.Hash, c.UserID, c.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetChallenge(ctx context.Context, hash []byte, now time.Time) (*Challenge, error) {
+	c := Challenge{Hash: hash}
+	err := r.db.QueryRow(ctx,
+		"SELECT user_id, attempts, expires_at FROM mfa_challenges WHERE hash = $1 AND expires_at > $2",
+		hash, now).Scan(&c.UserID, &c.Attempts, &c.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get challenge: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *PostgresRepository) FailChallenge(ctx context.Context, hash []byte, maxAttempts int) error {
+	var attempts int
+	err := r.db.QueryRow(ctx,
+		"UPDATE mfa_challenges SET attempts = attempts + 1 WHERE hash = $1 RETURNING attempts",
+		hash).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record challenge attempt: %w", err)
+	}
+	if attempts >= maxAttempts {
+		_, err := r.DeleteChallenge(ctx, hash)
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteChallenge(ctx context.Context, hash []byte) (bool, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM mfa_challenges WHERE hash = $1", hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete challenge: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,238 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	repo Repository
+	// Issuer labels the account in authenticator apps.
+	Issuer string
+	// ChallengeTTL is how long a login challenge can be redeemed for, and
+	// MaxChallengeAttempts how many wrong codes it survives.
+	ChallengeTTL         time.Duration
+	MaxChallengeAttempts int
+	RecoveryCodes        int
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{
+		repo:                 repo,
+		Issuer:               "gostocks",
+		ChallengeTTL:         5 * time.Minute,
+		MaxChallengeAttempts: 5,
+		RecoveryCodes:        10,
+	}
+}
+
+func hash(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+// normalizeCode strips the spaces and dashes people type into codes.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// newRecoveryCodes returns codes formatted XXXXX-XXXXX and their hashes.
+func (s *Service) newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, s.RecoveryCodes)
+	hashes := make([][]byte, s.RecoveryCodes)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := b32.EncodeToString(raw)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hash(code)
+	}
+	return codes, hashes, nil
+}
+
+func (s *Service) Status(ctx context.Context, userID int) (*Status, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return &Status{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Enabled: t.ConfirmedAt != nil, Pending: t.ConfirmedAt == nil, ConfirmedAt: t.ConfirmedAt}
+	if st.Enabled {
+		if st.RecoveryCodesRemaining, err = s.repo.RemainingRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Enabled reports whether logins for userID need a second factor.
+func (s *Service) Enabled(ctx context.Context, userID int) (bool, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.ConfirmedAt != nil, nil
+}
+
+// Enroll starts enrollment with a fresh secret. 2FA stays off until Confirm.
+func (s *Service) Enroll(ctx context.Context, userID int, account string) (*Enrollment, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPending(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+	return &Enrollment{Secret: secret, ProvisioningURI: ProvisioningURI(s.Issuer, account, secret)}, nil
+}
+
+// Confirm enables 2FA once the user proves their app produces codes for the
+// pending secret, and returns their recovery codes. They are not stored and
+// can't be shown again.
+func (s *Service) Confirm(ctx context.Context, userID int, code string) ([]string, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := Validate(t.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Confirm(ctx, userID, step, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a code from an enrolled user: either a current TOTP code,
+// each usable once, or an unused recovery code.
+func (s *Service) Verify(ctx context.Context, userID int, code string) error {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if t.ConfirmedAt == nil {
+		return ErrNotEnrolled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == Digits {
+		step, ok := Validate(t.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidCode
+		}
+		used, err := s.repo.UseStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hash(code), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// Disable turns 2FA off, given a valid code.
+func (s *Service) Disable(ctx context.Context, userID int, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, given a valid code.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Challenge starts the second login step for a user whose password checked
+// out. The token is exchanged with Redeem.
+func (s *Service) Challenge(ctx context.Context, userID int) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	c := &Challenge{Hash: hash(token), UserID: userID, ExpiresAt: time.Now().Add(s.ChallengeTTL)}
+	if err := s.repo.CreateChallenge(ctx, c); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, c.ExpiresAt, nil
+}
+
+// ChallengeUser returns whose login a challenge token belongs to.
+func (s *Service) ChallengeUser(ctx context.Context, token string) (int, error) {
+	c, err := s.repo.GetChallenge(ctx, hash(token), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return c.UserID, nil
+}
+
+// Redeem completes a login challenge. The challenge is used up on success,
+// and discarded after MaxChallengeAttempts wrong codes.
+func (s *Service) Redeem(ctx context.Context, token, code string) (int, error) {
+	h := hash(token)
+	c, err := s.repo.GetChallenge(ctx, h, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := s.Verify(ctx, c.UserID, code); err != nil {
+		if errors.Is(err, ErrNotEnrolled) {
+			// 2FA was turned off after the challenge was issued
+			return 0, ErrInvalidChallenge
+		}
+		if errors.Is(err, ErrInvalidCode) {
+			if ferr := s.repo.FailChallenge(ctx, h, s.MaxChallengeAttempts); ferr != nil {
+				return 0, ferr
+			}
+		}
+		return 0, err
+	}
+	ok, err := s.repo.DeleteChallenge(ctx, h)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidChallenge
+	}
+	return c.UserID, nil
+}
@@ -0,0 +1,83 @@
+// Package oidc signs users in through an external OpenID Connect identity
+// provider using the authorization code flow with PKCE. Users are matched
+// to a users.User by the provider's subject, or on first login by verified
+// email, and then get the same sessions as a password login.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/pkg/config"
+)
+
+var (
+	ErrInvalidState    = errors.New("invalid or expired login state")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+	ErrEmailUnverified = errors.New("identity provider has not verified the email")
+	ErrExchange        = errors.New("authorization code exchange failed")
+)
+
+// Config identifies this app to the provider.
+type Config struct {
+	// Issuer is the provider's issuer URL; its discovery document is at
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the browser back to. It is
+	// the frontend's callback page, which posts the code to the API.
+	RedirectURL string
+	Scopes      []string
+	// Name is shown on the login button.
+	Name string
+}
+
+// ConfigFromEnv reads the OIDC_* settings. It returns nil when OIDC_ISSUER
+// is unset, leaving single sign-on off.
+func ConfigFromEnv() *Config {
+	issuer := config.GetenvDefault("OIDC_ISSUER", "")
+	if issuer == "" {
+		return nil
+	}
+	return &Config{
+		Issuer:       issuer,
+		ClientID:     config.GetenvDefault("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.GetenvDefault("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  config.GetenvDefault("OIDC_REDIRECT_URL", "http://localhost:5173/oidc/callback"),
+		Scopes:       []string{"openid", "email", "profile"},
+		Name:         config.GetenvDefault("OIDC_PROVIDER_NAME", "SSO"),
+	}
+}
+
+// Claims are the ID token claims sign-in relies on.
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// State is a login in progress, stored between the redirect to the
+// provider and the callback. Only the state parameter's hash is kept.
+type State struct {
+	Hash []byte
+	// BindingHash is the hash of the secret held in the cookie of the
+	// browser that began the sign-in.
+	BindingHash  []byte
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+type Repository interface {
+	CreateState(ctx context.Context, s *State) error
+	// ConsumeState deletes and returns an unexpired state, or returns
+	// ErrInvalidState.
+	ConsumeState(ctx context.Context, hash []byte, now time.Time) (*State, error)
+	// IdentityUser returns the user linked to the provider subject, or
+	// users.ErrUserNotFound.
+	IdentityUser(ctx context.Context, issuer, subject string) (int, error)
+	LinkIdentity(ctx context.Context, issuer, subject string, userID int, email string) error
+}
@@ -0,0 +1,314 @@
+package oidc_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/oidc"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+const (
+	clientID     = "gostocks"
+	clientSecret = "s3cret"
+	redirectURL  = "http://localhost:5173/oidc/callback"
+)
+
+// stubIdP is a minimal OpenID provider: it signs in whoever is in next
+// without asking, and checks PKCE and client credentials at the token
+// endpoint.
+type stubIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	next  map[string]any
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp := &stubIdP{key: key, codes: map[string]pendingCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		signer, _ := auth.NewSignerKey("k1", key)
+		ks, _ := auth.NewKeySet("k1", signer)
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// signInAs sets the claims the next sign-in gets.
+func (idp *stubIdP) signInAs(claims map[string]any) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.next = claims
+}
+
+func (idp *stubIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != clientID || q.Get("redirect_uri") != redirectURL || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	claims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   clientID,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	idp.mu.Lock()
+	for k, v := range idp.next {
+		claims[k] = v
+	}
+	code := rand.Text()
+	idp.codes[code] = pendingCode{challenge: q.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	back, _ := url.Parse(q.Get("redirect_uri"))
+	back.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (idp *stubIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	if id != clientID || secret != clientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	idp.mu.Lock()
+	pending, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, pending.claims)
+	tok.Header["kid"] = "k1"
+	signed, _ := tok.SignedString(idp.key)
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": signed})
+}
+
+// follow visits the authorization URL and returns the code and state the
+// provider redirected back with.
+func follow(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if err != nil {
+		t.Fatalf("authorize did not redirect: %s", resp.Status)
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+type memRepo struct {
+	states     []*oidc.State
+	identities map[string]int
+}
+
+func (m *memRepo) CreateState(ctx context.Context, s *oidc.State) error {
+	m.states = append(m.states, s)
+	return nil
+}
+
+func (m *memRepo) ConsumeState(ctx context.Context, hash []byte, now time.Time) (*oidc.State, error) {
+	for i, s := range m.states {
+		if bytes.Equal(s.Hash, hash) {
+			m.states = append(m.states[:i], m.states[i+1:]...)
+			if s.ExpiresAt.After(now) {
+				return s, nil
+			}
+		}
+	}
+	return nil, oidc.ErrInvalidState
+}
+
+func (m *memRepo) IdentityUser(ctx context.Context, issuer, subject string) (int, error) {
+	if id, ok := m.identities[issuer+" "+subject]; ok {
+		return id, nil
+	}
+	return 0, users.ErrUserNotFound
+}
+
+func (m *memRepo) LinkIdentity(ctx context.Context, issuer, subject string, userID int, email string) error {
+	m.identities[issuer+" "+subject] = userID
+	return nil
+}
+
+type userTable struct {
+	byEmail map[string]*users.User
+}
+
+func (u *userTable) GetUser(ctx context.Context, userID int) (*users.User, error) {
+	for _, user := range u.byEmail {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, users.ErrUserNotFound
+}
+
+func (u *userTable) EnsureVerifiedUser(ctx context.Context, email string) (*users.User, error) {
+	if user, ok := u.byEmail[email]; ok {
+		return user, nil
+	}
+	user := &users.User{ID: len(u.byEmail) + 1, Email: email}
+	u.byEmail[email] = user
+	return user, nil
+}
+
+func newService(t *testing.T) (*oidc.Service, *stubIdP, *userTable) {
+	idp := newStubIdP(t)
+	table := &userTable{byEmail: map[string]*users.User{"existing@example.com": {ID: 1, Email: "existing@example.com"}}}
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer: idp.URL, ClientID: clientID, ClientSecret: clientSecret,
+		RedirectURL: redirectURL, Scopes: []string{"openid", "email"},
+	})
+	return oidc.NewService(&memRepo{identities: map[string]int{}}, provider, table), idp, table
+}
+
+func TestLoginLinksAndCreatesUsers(t *testing.T) {
+	ctx := context.Background()
+	svc, idp, table := newService(t)
+
+	// A verified email matching a local account signs into it
+	idp.signInAs(map[string]any{"sub": "alice", "email": "existing@example.com", "email_verified": true})
+	u, binding, _ := svc.Begin(ctx)
+	code, state := follow(t, u)
+	user, err := svc.Complete(ctx, code, state, binding)
+	if err != nil || user.ID != 1 {
+		t.Fatalf("Complete = %+v, %v; want existing user", user, err)
+	}
+
+	// The state can't be replayed
+	if _, err := svc.Complete(ctx, code, state, binding); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("replayed state = %v, want ErrInvalidState", err)
+	}
+
+	// Or finished in another browser
+	u, _, _ = svc.Begin(ctx)
+	code, state = follow(t, u)
+	if _, err := svc.Complete(ctx, code, state, binding); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("state from another browser = %v, want ErrInvalidState", err)
+	}
+
+	// The subject stays linked after its email changes at the provider
+	idp.signInAs(map[string]any{"sub": "alice", "email": "alice@new.example.com", "email_verified": "true"})
+	u, binding, _ = svc.Begin(ctx)
+	code, state = follow(t, u)
+	if user, err := svc.Complete(ctx, code, state, binding); err != nil || user.ID != 1 {
+		t.Errorf("Complete after email change = %+v, %v; want user 1", user, err)
+	}
+
+	// New verified emails get a new user
+	idp.signInAs(map[string]any{"sub": "bob", "email": "bob@example.com", "email_verified": true})
+	u, binding, _ = svc.Begin(ctx)
+	code, state = follow(t, u)
+	if user, err := svc.Complete(ctx, code, state, binding); err != nil || user.Email != "bob@example.com" {
+		t.Errorf("Complete new = %+v, %v", user, err)
+	}
+	if len(table.byEmail) != 2 {
+		t.Errorf("users = %v, want 2", table.byEmail)
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	svc, idp, _ := newService(t)
+
+	idp.signInAs(map[string]any{"sub": "mallory", "email": "existing@example.com", "email_verified": false})
+	u, binding, _ := svc.Begin(ctx)
+	code, state := follow(t, u)
+	if _, err := svc.Complete(ctx, code, state, binding); !errors.Is(err, oidc.ErrEmailUnverified) {
+		t.Errorf("Complete unverified = %v, want ErrEmailUnverified", err)
+	}
+}
+
+func TestProviderRejectsBadTokens(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t)
+	cfg := oidc.Config{Issuer: idp.URL, ClientID: clientID, ClientSecret: clientSecret, RedirectURL: redirectURL}
+	p := oidc.NewProvider(cfg)
+
+	idp.signInAs(map[string]any{"sub": "alice"})
+	u, _ := p.AuthCodeURL(ctx, "state", "nonce-1", "wrong-challenge")
+	code, _ := follow(t, u)
+	if _, err := p.Exchange(ctx, code, "verifier"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("Exchange with wrong PKCE verifier = %v, want ErrExchange", err)
+	}
+
+	verifier := "correct-verifier"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	exchange := func(claims map[string]any) string {
+		idp.signInAs(claims)
+		u, _ := p.AuthCodeURL(ctx, "state", "nonce-1", challenge)
+		code, _ := follow(t, u)
+		tok, err := p.Exchange(ctx, code, verifier)
+		if err != nil {
+			t.Fatalf("Exchange: %v", err)
+		}
+		return tok
+	}
+
+	tok := exchange(map[string]any{"sub": "alice"})
+	if claims, err := p.VerifyIDToken(ctx, tok, "nonce-1"); err != nil || claims.Subject != "alice" {
+		t.Errorf("VerifyIDToken = %+v, %v", claims, err)
+	}
+	if _, err := p.VerifyIDToken(ctx, tok, "nonce-2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("wrong nonce = %v, want ErrInvalidIDToken", err)
+	}
+	for name, claims := range map[string]map[string]any{
+		"audience": {"sub": "alice", "aud": "someone-else"},
+		"issuer":   {"sub": "alice", "iss": "https://evil.example.com"},
+		"expired":  {"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()},
+		"azp":      {"sub": "alice", "aud": []string{clientID, "other"}, "azp": "other"},
+	} {
+		if _, err := p.VerifyIDToken(ctx, exchange(claims), "nonce-1"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("%s: VerifyIDToken = %v, want ErrInvalidIDToken", name, err)
+		}
+	}
+}
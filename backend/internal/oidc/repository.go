@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) CreateState(ctx context.Context, s *State) error {
+	// Abandoned sign-ins are cleared out as new ones start
+	if _, err := r.db.Exec(ctx, "DELETE FROM oidc_states WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("failed to prune oidc states: %w", err)
+	}
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO oidc_states (hash, binding_hash, nonce, code_verifier, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		s.Hash, s.BindingHash, s.Nonce, s.CodeVerifier, s.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oidc state: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ConsumeState(ctx context.Context, hash []byte, now time.Time) (*State, error) {
+	s := State{Hash: hash}
+	err := r.db.QueryRow(ctx,
+		"DELETE FROM oidc_states WHERE hash = $1 RETURNING binding_hash, nonce, code_verifier, expires_at",
+		hash).Scan(&s.BindingHash, &s.Nonce, &s.CodeVerifier, &s.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oidc state: %w", err)
+	}
+	if !s.ExpiresAt.After(now) {
+		return nil, ErrInvalidState
+	}
+	return &s, nil
+}
+
+func (r *PostgresRepository) IdentityUser(ctx context.Context, issuer, subject string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(ctx,
+		"SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, subject).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, users.ErrUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return userID, nil
+}
+
+func (r *PostgresRepository) LinkIdentity(ctx context.Context, issuer, subject string, userID int, email string) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO user_identities (issuer, subject, user_id, email) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (issuer, subject) DO NOTHING`,
+		issuer, subject, userID, email)
+	return err
+}
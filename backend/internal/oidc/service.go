@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// UserStore is the part of users.Service sign-in needs.
+type UserStore interface {
+	GetUser(ctx context.Context, userID int) (*users.User, error)
+	EnsureVerifiedUser(ctx context.Context, email string) (*users.User, error)
+}
+
+type Service struct {
+	repo     Repository
+	provider *Provider
+	users    UserStore
+	// StateTTL is how long the user has to finish signing in at the
+	// provider.
+	StateTTL time.Duration
+}
+
+func NewService(repo Repository, provider *Provider, users UserStore) *Service {
+	return &Service{repo: repo, provider: provider, users: users, StateTTL: 10 * time.Minute}
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hash(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+// codeChallenge is the RFC 7636 S256 challenge for verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Begin starts a sign-in and returns the provider URL to send the browser
+// to, and a binding secret the browser must keep, in a cookie, and present
+// to Complete. Without it anyone could send a victim's browser to the
+// callback with the code from their own sign-in and log the victim in as
+// themselves.
+func (s *Service) Begin(ctx context.Context) (authURL, binding string, err error) {
+	var state, nonce, verifier string
+	for _, v := range []*string{&state, &nonce, &verifier, &binding} {
+		if *v, err = randomString(); err != nil {
+			return "", "", err
+		}
+	}
+	authURL, err = s.provider.AuthCodeURL(ctx, state, nonce, codeChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+	st := &State{
+		Hash: hash(state), BindingHash: hash(binding), Nonce: nonce,
+		CodeVerifier: verifier, ExpiresAt: time.Now().Add(s.StateTTL),
+	}
+	if err := s.repo.CreateState(ctx, st); err != nil {
+		return "", "", err
+	}
+	return authURL, binding, nil
+}
+
+// Complete finishes a sign-in with the code and state the provider
+// redirected back with, returning the signed-in user. A provider subject
+// seen before maps to the same user even if its email changed; a new one
+// is linked to the user with its verified email, who is created if needed.
+// binding must be the one Begin returned for state.
+func (s *Service) Complete(ctx context.Context, code, state, binding string) (*users.User, error) {
+	st, err := s.repo.ConsumeState(ctx, hash(state), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(st.BindingHash, hash(binding)) != 1 {
+		return nil, ErrInvalidState
+	}
+	idToken, err := s.provider.Exchange(ctx, code, st.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.provider.VerifyIDToken(ctx, idToken, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.repo.IdentityUser(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return s.users.GetUser(ctx, userID)
+	}
+	if !errors.Is(err, users.ErrUserNotFound) {
+		return nil, err
+	}
+
+	if !claims.EmailVerified || claims.Email == "" {
+		return nil, ErrEmailUnverified
+	}
+	user, err := s.users.EnsureVerifiedUser(ctx, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.LinkIdentity(ctx, claims.Issuer, claims.Subject, user.ID, claims.Email); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,90 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// Matcher fills resting orders when polled prices cross them. Like the
+// alert evaluator it is fed each ticker batch and works in its own
+// goroutine.
+type Matcher struct {
+	service *Service
+	quotes  chan map[string]*stocks.Quote
+}
+
+func NewMatcher(service *Service) *Matcher {
+	return &Matcher{service: service, quotes: make(chan map[string]*stocks.Quote, 1)}
+}
+
+// Symbols returns every symbol with a resting order, so the ticker polls
+// them even when nobody is subscribed.
+func (m *Matcher) Symbols(ctx context.Context) ([]string, error) {
+	return m.service.repo.OpenOrderSymbols(ctx)
+}
+
+// Feed hands a batch of quotes to the matcher without blocking, replacing
+// any batch still queued.
+func (m *Matcher) Feed(quotes map[string]*stocks.Quote) {
+	for {
+		select {
+		case m.quotes <- quotes:
+			return
+		default:
+		}
+		select {
+		case <-m.quotes:
+		default:
+		}
+	}
+}
+
+// Run matches fed quotes until ctx is cancelled.
+func (m *Matcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case quotes := <-m.quotes:
+			m.Match(ctx, quotes, time.Now())
+		}
+	}
+}
+
+// Match executes every open order in the quoted symbols that the quote
+// triggers. Orders that can no longer be covered are rejected.
+func (m *Matcher) Match(ctx context.Context, quotes map[string]*stocks.Quote, now time.Time) {
+	if len(quotes) == 0 {
+		return
+	}
+	symbols := make([]string, 0, len(quotes))
+	for sym := range quotes {
+		symbols = append(symbols, sym)
+	}
+	orders, err := m.service.repo.OpenOrders(ctx, symbols)
+	if err != nil {
+		log.Printf("paper: %v", err)
+		return
+	}
+
+	for i := range orders {
+		o := &orders[i]
+		q := quotes[o.Symbol]
+		if q == nil || q.Price <= 0 || !o.Triggered(q.Price) {
+			continue
+		}
+		_, err := m.service.execute(ctx, o, q.Price, now)
+		switch {
+		case errors.Is(err, ErrInsufficientCash), errors.Is(err, ErrInsufficientShares):
+			log.Printf("paper: order %d rejected: %v", o.ID, err)
+		case errors.Is(err, ErrOrderNotFound):
+			// cancelled, or filled by another replica, since it was listed
+		case err != nil:
+			log.Printf("paper: order %d: %v", o.ID, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package paper simulates brokerage accounts: users start with cash and
+// trade against live quotes without real money.
+package paper
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAccountExists      = errors.New("paper account already exists")
+	ErrNoAccount          = errors.New("no paper account")
+	ErrInvalidOrder       = errors.New("invalid order")
+	ErrOrderNotFound      = errors.New("open order not found")
+	ErrInsufficientCash   = errors.New("insufficient cash")
+	ErrInsufficientShares = errors.New("insufficient shares")
+	ErrQuoteUnavailable   = errors.New("quote unavailable")
+)
+
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+type OrderType string
+
+const (
+	Market OrderType = "market"
+	Limit  OrderType = "limit"
+	Stop   OrderType = "stop"
+)
+
+// Order statuses.
+const (
+	StatusOpen      = "open"
+	StatusFilled    = "filled"
+	StatusCancelled = "cancelled"
+	StatusRejected  = "rejected"
+)
+
+type Account struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	StartingCash float64   `json:"starting_cash"`
+	Cash         float64   `json:"cash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Order struct {
+	ID           int        `json:"id"`
+	AccountID    int        `json:"account_id"`
+	UserID       int        `json:"user_id"`
+	Symbol       string     `json:"symbol"`
+	Side         Side       `json:"side"`
+	Type         OrderType  `json:"type"`
+	Quantity     int        `json:"quantity"`
+	LimitPrice   float64    `json:"limit_price,omitempty"`
+	StopPrice    float64    `json:"stop_price,omitempty"`
+	Status       string     `json:"status"`
+	RejectReason string     `json:"reject_reason,omitempty"`
+	FillPrice    float64    `json:"fill_price,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+type Fill struct {
+	ID        int       `json:"id"`
+	OrderID   int       `json:"order_id"`
+	AccountID int       `json:"account_id"`
+	UserID    int       `json:"user_id"`
+	Symbol    string    `json:"symbol"`
+	Side      Side      `json:"side"`
+	Quantity  int       `json:"quantity"`
+	Price     float64   `json:"price"`
+	FilledAt  time.Time `json:"filled_at"`
+}
+
+type Position struct {
+	Symbol   string  `json:"symbol"`
+	Quantity int     `json:"quantity"`
+	AvgCost  float64 `json:"avg_cost"`
+}
+
+// Triggered reports whether a resting order executes at price. Buy limits
+// fill at or below the limit, sell limits at or above; buy stops trigger at
+// or above the stop, sell stops at or below. Market orders always execute.
+func (o *Order) Triggered(price float64) bool {
+	switch o.Type {
+	case Limit:
+		if o.Side == Buy {
+			return price <= o.LimitPrice
+		}
+		return price >= o.LimitPrice
+	case Stop:
+		if o.Side == Buy {
+			return price >= o.StopPrice
+		}
+		return price <= o.StopPrice
+	}
+	return true
+}
+
+// Settle applies a fill of o at price to an account's cash and position in
+// o.Symbol, returning the new values. Accounts are long only.
+func Settle(cash float64, pos Position, o *Order, price float64) (float64, Position, error) {
+	qty := float64(o.Quantity)
+	if o.Side == Buy {
+		cost := qty * price
+		if cost > cash {
+			return cash, pos, ErrInsufficientCash
+		}
+		total := float64(pos.Quantity) + qty
+		pos.AvgCost = (float64(pos.Quantity)*pos.AvgCost + cost) / total
+		pos.Quantity += o.Quantity
+		return cash - cost, pos, nil
+	}
+	if o.Quantity > pos.Quantity {
+		return cash, pos, ErrInsufficientShares
+	}
+	pos.Quantity -= o.Quantity
+	if pos.Quantity == 0 {
+		pos.AvgCost = 0
+	}
+	return cash + qty*price, pos, nil
+}
+
+type Repository interface {
+	CreateAccount(ctx context.Context, userID int, cash float64) (*Account, error)
+	GetAccount(ctx context.Context, userID int) (*Account, error)
+	CreateOrder(ctx context.Context, o *Order) error
+	ListOrders(ctx context.Context, userID int, status string, limit int) ([]Order, error)
+	CancelOrder(ctx context.Context, userID, orderID int) error
+	// OpenOrders returns resting orders in symbols, oldest first.
+	OpenOrders(ctx context.Context, symbols []string) ([]Order, error)
+	OpenOrderSymbols(ctx context.Context) ([]string, error)
+	// ExecuteOrder fills an open order at price using Settle, atomically
+	// with the account's cash and position. If Settle refuses, the order
+	// is rejected and its error returned alongside the updated order.
+	ExecuteOrder(ctx context.Context, orderID int, price float64, at time.Time) (*Order, *Fill, error)
+	Positions(ctx context.Context, userID int) ([]Position, error)
+	ListFills(ctx context.Context, userID, limit int) ([]Fill, error)
+}
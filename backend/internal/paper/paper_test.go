@@ -0,0 +1,330 @@
+package paper_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/paper"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+func TestTriggered(t *testing.T) {
+	cases := []struct {
+		order paper.Order
+		price float64
+		want  bool
+	}{
+		{paper.Order{Type: paper.Limit, Side: paper.Buy, LimitPrice: 100}, 100.5, false},
+		{paper.Order{Type: paper.Limit, Side: paper.Buy, LimitPrice: 100}, 100, true},
+		{paper.Order{Type: paper.Limit, Side: paper.Sell, LimitPrice: 100}, 99, false},
+		{paper.Order{Type: paper.Limit, Side: paper.Sell, LimitPrice: 100}, 101, true},
+		{paper.Order{Type: paper.Stop, Side: paper.Buy, StopPrice: 100}, 99, false},
+		{paper.Order{Type: paper.Stop, Side: paper.Buy, StopPrice: 100}, 100, true},
+		{paper.Order{Type: paper.Stop, Side: paper.Sell, StopPrice: 100}, 101, false},
+		{paper.Order{Type: paper.Stop, Side: paper.Sell, StopPrice: 100}, 95, true},
+		{paper.Order{Type: paper.Market, Side: paper.Buy}, 1, true},
+	}
+	for _, c := range cases {
+		if got := c.order.Triggered(c.price); got != c.want {
+			t.Errorf("%s %s at %.2f: expected %v, got %v", c.order.Type, c.order.Side, c.price, c.want, got)
+		}
+	}
+}
+
+func TestSettle(t *testing.T) {
+	cash, pos, err := paper.Settle(1000, paper.Position{Symbol: "AAPL"}, &paper.Order{Side: paper.Buy, Quantity: 4}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cash, pos, err = paper.Settle(cash, pos, &paper.Order{Side: paper.Buy, Quantity: 4}, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cash != 0 || pos.Quantity != 8 || pos.AvgCost != 125 {
+		t.Errorf("after buys: cash %.2f, position %+v", cash, pos)
+	}
+
+	if _, _, err := paper.Settle(cash, pos, &paper.Order{Side: paper.Buy, Quantity: 1}, 1); !errors.Is(err, paper.ErrInsufficientCash) {
+		t.Errorf("expected ErrInsufficientCash, got %v", err)
+	}
+	if _, _, err := paper.Settle(cash, pos, &paper.Order{Side: paper.Sell, Quantity: 9}, 1); !errors.Is(err, paper.ErrInsufficientShares) {
+		t.Errorf("expected ErrInsufficientShares, got %v", err)
+	}
+
+	cash, pos, err = paper.Settle(cash, pos, &paper.Order{Side: paper.Sell, Quantity: 8}, 110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cash != 880 || pos.Quantity != 0 || pos.AvgCost != 0 {
+		t.Errorf("after sell: cash %.2f, position %+v", cash, pos)
+	}
+}
+
+// memRepo is an in-memory Repository for a single account.
+type memRepo struct {
+	acct      *paper.Account
+	orders    []*paper.Order
+	positions map[string]paper.Position
+	fills     []paper.Fill
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{positions: map[string]paper.Position{}}
+}
+
+func (m *memRepo) CreateAccount(ctx context.Context, userID int, cash float64) (*paper.Account, error) {
+	if m.acct != nil {
+		return nil, paper.ErrAccountExists
+	}
+	m.acct = &paper.Account{ID: 1, UserID: userID, StartingCash: cash, Cash: cash}
+	return m.acct, nil
+}
+
+func (m *memRepo) GetAccount(ctx context.Context, userID int) (*paper.Account, error) {
+	if m.acct == nil || m.acct.UserID != userID {
+		return nil, paper.ErrNoAccount
+	}
+	acct := *m.acct
+	return &acct, nil
+}
+
+func (m *memRepo) CreateOrder(ctx context.Context, o *paper.Order) error {
+	o.ID = len(m.orders) + 1
+	stored := *o
+	m.orders = append(m.orders, &stored)
+	return nil
+}
+
+func (m *memRepo) ListOrders(ctx context.Context, userID int, status string, limit int) ([]paper.Order, error) {
+	var out []paper.Order
+	for _, o := range m.orders {
+		if status == "" || o.Status == status {
+			out = append(out, *o)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) CancelOrder(ctx context.Context, userID, orderID int) error {
+	for _, o := range m.orders {
+		if o.ID == orderID && o.UserID == userID && o.Status == paper.StatusOpen {
+			o.Status = paper.StatusCancelled
+			return nil
+		}
+	}
+	return paper.ErrOrderNotFound
+}
+
+func (m *memRepo) OpenOrders(ctx context.Context, symbols []string) ([]paper.Order, error) {
+	var out []paper.Order
+	for _, o := range m.orders {
+		for _, s := range symbols {
+			if o.Symbol == s && o.Status == paper.StatusOpen {
+				out = append(out, *o)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) OpenOrderSymbols(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, o := range m.orders {
+		if o.Status == paper.StatusOpen && !seen[o.Symbol] {
+			seen[o.Symbol] = true
+			out = append(out, o.Symbol)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (m *memRepo) ExecuteOrder(ctx context.Context, orderID int, price float64, at time.Time) (*paper.Order, *paper.Fill, error) {
+	var o *paper.Order
+	for _, candidate := range m.orders {
+		if candidate.ID == orderID && candidate.Status == paper.StatusOpen {
+			o = candidate
+		}
+	}
+	if o == nil {
+		return nil, nil, paper.ErrOrderNotFound
+	}
+	pos := m.positions[o.Symbol]
+	pos.Symbol = o.Symbol
+	cash, pos, err := paper.Settle(m.acct.Cash, pos, o, price)
+	if err != nil {
+		o.Status, o.RejectReason = paper.StatusRejected, err.Error()
+		updated := *o
+		return &updated, nil, err
+	}
+	m.acct.Cash = cash
+	if pos.Quantity == 0 {
+		delete(m.positions, o.Symbol)
+	} else {
+		m.positions[o.Symbol] = pos
+	}
+	o.Status, o.FillPrice = paper.StatusFilled, price
+	fill := paper.Fill{ID: len(m.fills) + 1, OrderID: o.ID, UserID: o.UserID, Symbol: o.Symbol,
+		Side: o.Side, Quantity: o.Quantity, Price: price, FilledAt: at}
+	m.fills = append(m.fills, fill)
+	updated := *o
+	return &updated, &fill, nil
+}
+
+func (m *memRepo) Positions(ctx context.Context, userID int) ([]paper.Position, error) {
+	var out []paper.Position
+	for _, p := range m.positions {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (m *memRepo) ListFills(ctx context.Context, userID, limit int) ([]paper.Fill, error) {
+	return m.fills, nil
+}
+
+type fixedProvider map[string]float64
+
+func (p fixedProvider) Quote(ctx context.Context, symbol string) (*stocks.Quote, error) {
+	price, ok := p[symbol]
+	if !ok {
+		return nil, errors.New("unknown symbol")
+	}
+	return &stocks.Quote{Symbol: symbol, Price: price}, nil
+}
+
+func (p fixedProvider) Intraday(ctx context.Context, symbol, interval string, limit int) ([]stocks.Candle, error) {
+	return nil, nil
+}
+
+func TestMarketOrderFillsAtQuote(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemRepo()
+	svc := paper.NewService(repo, fixedProvider{"AAPL": 200})
+	var fills []*paper.Fill
+	svc.OnFill(func(ctx context.Context, f *paper.Fill) { fills = append(fills, f) })
+
+	if _, err := svc.PlaceOrder(ctx, 7, paper.Order{Symbol: "AAPL", Side: paper.Buy, Type: paper.Market, Quantity: 1}); !errors.Is(err, paper.ErrNoAccount) {
+		t.Fatalf("expected ErrNoAccount before opening, got %v", err)
+	}
+	if _, err := svc.OpenAccount(ctx, 7, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := svc.PlaceOrder(ctx, 7, paper.Order{Symbol: " aapl ", Side: paper.Buy, Type: paper.Market, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != paper.StatusFilled || o.FillPrice != 200 {
+		t.Errorf("expected a fill at 200, got %+v", o)
+	}
+	if len(fills) != 1 || fills[0].Symbol != "AAPL" {
+		t.Errorf("expected one AAPL fill notification, got %v", fills)
+	}
+	acct, _ := svc.Account(ctx, 7)
+	if acct.Cash != 400 {
+		t.Errorf("expected 400 cash left, got %.2f", acct.Cash)
+	}
+
+	o, err = svc.PlaceOrder(ctx, 7, paper.Order{Symbol: "AAPL", Side: paper.Buy, Type: paper.Market, Quantity: 3})
+	if !errors.Is(err, paper.ErrInsufficientCash) || o.Status != paper.StatusRejected {
+		t.Errorf("expected a rejected order, got %+v, %v", o, err)
+	}
+	if len(fills) != 1 {
+		t.Error("rejected order should not notify")
+	}
+}
+
+func TestPlaceOrderValidates(t *testing.T) {
+	ctx := context.Background()
+	svc := paper.NewService(newMemRepo(), fixedProvider{})
+	if _, err := svc.OpenAccount(ctx, 1, math.NaN()); !errors.Is(err, paper.ErrInvalidOrder) {
+		t.Errorf("expected NaN starting cash to be rejected, got %v", err)
+	}
+	svc.OpenAccount(ctx, 1, 1000)
+
+	bad := []paper.Order{
+		{Symbol: "AAPL", Side: paper.Buy, Type: paper.Market},
+		{Symbol: "$(rm)", Side: paper.Buy, Type: paper.Market, Quantity: 1},
+		{Symbol: "AAPL", Side: "short", Type: paper.Market, Quantity: 1},
+		{Symbol: "AAPL", Side: paper.Buy, Type: paper.Limit, Quantity: 1},
+		{Symbol: "AAPL", Side: paper.Sell, Type: paper.Stop, Quantity: 1, LimitPrice: 5},
+		{Symbol: "AAPL", Side: paper.Buy, Type: "trailing", Quantity: 1},
+	}
+	for _, o := range bad {
+		if _, err := svc.PlaceOrder(ctx, 1, o); !errors.Is(err, paper.ErrInvalidOrder) {
+			t.Errorf("%+v: expected ErrInvalidOrder, got %v", o, err)
+		}
+	}
+
+	resting := paper.Order{Symbol: "AAPL", Side: paper.Buy, Type: paper.Limit, Quantity: 1, LimitPrice: 1}
+	for i := 0; i < 50; i++ {
+		if _, err := svc.PlaceOrder(ctx, 1, resting); err != nil {
+			t.Fatalf("order %d: %v", i+1, err)
+		}
+	}
+	if _, err := svc.PlaceOrder(ctx, 1, resting); !errors.Is(err, paper.ErrInvalidOrder) {
+		t.Errorf("expected the open order cap to apply, got %v", err)
+	}
+}
+
+func TestMatcherFillsRestingOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemRepo()
+	svc := paper.NewService(repo, fixedProvider{"MSFT": 400})
+	svc.OpenAccount(ctx, 1, 10000)
+	var fills []*paper.Fill
+	svc.OnFill(func(ctx context.Context, f *paper.Fill) { fills = append(fills, f) })
+
+	limit, _ := svc.PlaceOrder(ctx, 1, paper.Order{Symbol: "MSFT", Side: paper.Buy, Type: paper.Limit, Quantity: 10, LimitPrice: 390})
+	stop, _ := svc.PlaceOrder(ctx, 1, paper.Order{Symbol: "MSFT", Side: paper.Sell, Type: paper.Stop, Quantity: 10, StopPrice: 370})
+	if limit.Status != paper.StatusOpen || stop.Status != paper.StatusOpen {
+		t.Fatalf("resting orders should stay open, got %s and %s", limit.Status, stop.Status)
+	}
+
+	m := paper.NewMatcher(svc)
+	if symbols, _ := m.Symbols(ctx); len(symbols) != 1 || symbols[0] != "MSFT" {
+		t.Errorf("expected MSFT to be watched, got %v", symbols)
+	}
+
+	now := time.Now()
+	m.Match(ctx, map[string]*stocks.Quote{"MSFT": {Symbol: "MSFT", Price: 395}}, now)
+	if len(fills) != 0 {
+		t.Fatalf("nothing should fill at 395, got %d fills", len(fills))
+	}
+	m.Match(ctx, map[string]*stocks.Quote{"MSFT": {Symbol: "MSFT", Price: 389.5}}, now)
+	if len(fills) != 1 || fills[0].OrderID != limit.ID || fills[0].Price != 389.5 {
+		t.Fatalf("expected the limit to fill at 389.50, got %v", fills)
+	}
+	m.Match(ctx, map[string]*stocks.Quote{"MSFT": {Symbol: "MSFT", Price: 365}}, now)
+	if len(fills) != 2 || fills[1].OrderID != stop.ID {
+		t.Fatalf("expected the stop to fill, got %v", fills)
+	}
+
+	acct, _ := svc.Account(ctx, 1)
+	if want := 10000 - 3895.0 + 3650; acct.Cash != want {
+		t.Errorf("expected cash %.2f, got %.2f", want, acct.Cash)
+	}
+	if positions, _ := svc.Positions(ctx, 1); len(positions) != 0 {
+		t.Errorf("expected a flat account, got %v", positions)
+	}
+}
+
+func TestMatcherRejectsUncoveredSell(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemRepo()
+	svc := paper.NewService(repo, fixedProvider{})
+	svc.OpenAccount(ctx, 1, 1000)
+	svc.PlaceOrder(ctx, 1, paper.Order{Symbol: "TSLA", Side: paper.Sell, Type: paper.Limit, Quantity: 1, LimitPrice: 100})
+
+	paper.NewMatcher(svc).Match(ctx, map[string]*stocks.Quote{"TSLA": {Price: 120}}, time.Now())
+	orders, _ := svc.Orders(ctx, 1, "", 0)
+	if len(orders) != 1 || orders[0].Status != paper.StatusRejected {
+		t.Errorf("expected the sell to be rejected, got %+v", orders)
+	}
+}
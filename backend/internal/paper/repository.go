@@ -0,0 +1,259 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+const orderColumns = `id, account_id, user_id, symbol, side, type, quantity, limit_price, stop_price,
+	status, reject_reason, fill_price, created_at, closed_at`
+
+func scanOrder(row pgx.Row, o *Order) error {
+	return row.Scan(&o.ID, &o.AccountID, &o.UserID, &o.Symbol, &o.Side, &o.Type, &o.Quantity,
+		&o.LimitPrice, &o.StopPrice, &o.Status, &o.RejectReason, &o.FillPrice, &o.CreatedAt, &o.ClosedAt)
+}
+
+func (r *PostgresRepository) CreateAccount(ctx context.Context, userID int, cash float64) (*Account, error) {
+	acct := Account{UserID: userID, StartingCash: cash, Cash: cash}
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO paper_accounts (user_id, starting_cash, cash) VALUES ($1, $2, $2)
+		 ON CONFLICT (user_id) DO NOTHING RETURNING id, created_at`,
+		userID, cash).Scan(&acct.ID, &acct.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAccountExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create paper account: %w", err)
+	}
+	return &acct, nil
+}
+
+func (r *PostgresRepository) GetAccount(ctx context.Context, userID int) (*Account, error) {
+	var acct Account
+	err := r.db.QueryRow(ctx,
+		"SELECT id, user_id, starting_cash, cash, created_at FROM paper_accounts WHERE user_id = $1", userID).
+		Scan(&acct.ID, &acct.UserID, &acct.StartingCash, &acct.Cash, &acct.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoAccount
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paper account: %w", err)
+	}
+	return &acct, nil
+}
+
+func (r *PostgresRepository) CreateOrder(ctx context.Context, o *Order) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO paper_orders (account_id, user_id, symbol, side, type, quantity, limit_price, stop_price, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`,
+		o.AccountID, o.UserID, o.Symbol, o.Side, o.Type, o.Quantity, o.LimitPrice, o.StopPrice, o.Status).
+		Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListOrders(ctx context.Context, userID int, status string, limit int) ([]Order, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders
+		 WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		 ORDER BY created_at DESC LIMIT $3`, userID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return collectOrders(rows)
+}
+
+func (r *PostgresRepository) OpenOrders(ctx context.Context, symbols []string) ([]Order, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders
+		 WHERE status = 'open' AND symbol = ANY($1) ORDER BY created_at`, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open orders: %w", err)
+	}
+	return collectOrders(rows)
+}
+
+func collectOrders(rows pgx.Rows) ([]Order, error) {
+	defer rows.Close()
+	var orders []Order
+	for rows.Next() {
+		var o Order
+		if err := scanOrder(rows, &o); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *PostgresRepository) OpenOrderSymbols(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, "SELECT DISTINCT symbol FROM paper_orders WHERE status = 'open'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open order symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("failed to scan symbol: %w", err)
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, rows.Err()
+}
+
+func (r *PostgresRepository) CancelOrder(ctx context.Context, userID, orderID int) error {
+	tag, err := r.db.Exec(ctx,
+		"UPDATE paper_orders SET status = 'cancelled', closed_at = NOW() WHERE id = $1 AND user_id = $2 AND status = 'open'",
+		orderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ExecuteOrder(ctx context.Context, orderID int, price float64, at time.Time) (*Order, *Fill, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the order before the account so concurrent executions of the
+	// same order (another replica's matcher) serialize on it.
+	var o Order
+	err = scanOrder(tx.QueryRow(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders WHERE id = $1 AND status = 'open' FOR UPDATE`, orderID), &o)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	var cash float64
+	if err := tx.QueryRow(ctx, "SELECT cash FROM paper_accounts WHERE id = $1 FOR UPDATE", o.AccountID).Scan(&cash); err != nil {
+		return nil, nil, fmt.Errorf("failed to lock paper account: %w", err)
+	}
+	pos := Position{Symbol: o.Symbol}
+	err = tx.QueryRow(ctx,
+		"SELECT quantity, avg_cost FROM paper_positions WHERE account_id = $1 AND symbol = $2",
+		o.AccountID, o.Symbol).Scan(&pos.Quantity, &pos.AvgCost)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to get position: %w", err)
+	}
+
+	closed := at
+	o.ClosedAt = &closed
+	cash, pos, settleErr := Settle(cash, pos, &o, price)
+	if settleErr != nil {
+		o.Status, o.RejectReason = StatusRejected, settleErr.Error()
+		_, err := tx.Exec(ctx,
+			"UPDATE paper_orders SET status = $2, reject_reason = $3, closed_at = $4 WHERE id = $1",
+			o.ID, o.Status, o.RejectReason, at)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to reject order: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to commit rejection: %w", err)
+		}
+		return &o, nil, settleErr
+	}
+
+	o.Status, o.FillPrice = StatusFilled, price
+	if _, err := tx.Exec(ctx,
+		"UPDATE paper_orders SET status = $2, fill_price = $3, closed_at = $4 WHERE id = $1",
+		o.ID, o.Status, price, at); err != nil {
+		return nil, nil, fmt.Errorf("failed to fill order: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE paper_accounts SET cash = $2 WHERE id = $1", o.AccountID, cash); err != nil {
+		return nil, nil, fmt.Errorf("failed to update cash: %w", err)
+	}
+	if pos.Quantity == 0 {
+		_, err = tx.Exec(ctx, "DELETE FROM paper_positions WHERE account_id = $1 AND symbol = $2", o.AccountID, o.Symbol)
+	} else {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO paper_positions (account_id, symbol, quantity, avg_cost) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (account_id, symbol) DO UPDATE SET quantity = EXCLUDED.quantity, avg_cost = EXCLUDED.avg_cost`,
+			o.AccountID, o.Symbol, pos.Quantity, pos.AvgCost)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update position: %w", err)
+	}
+
+	fill := Fill{OrderID: o.ID, AccountID: o.AccountID, UserID: o.UserID, Symbol: o.Symbol,
+		Side: o.Side, Quantity: o.Quantity, Price: price, FilledAt: at}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO paper_fills (order_id, account_id, user_id, symbol, side, quantity, price, filled_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		fill.OrderID, fill.AccountID, fill.UserID, fill.Symbol, fill.Side, fill.Quantity, fill.Price, fill.FilledAt).
+		Scan(&fill.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to record fill: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit fill: %w", err)
+	}
+	return &o, &fill, nil
+}
+
+func (r *PostgresRepository) Positions(ctx context.Context, userID int) ([]Position, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT p.symbol, p.quantity, p.avg_cost FROM paper_positions p
+		 JOIN paper_accounts a ON a.id = p.account_id
+		 WHERE a.user_id = $1 ORDER BY p.symbol`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []Position
+	for rows.Next() {
+		var p Position
+		if err := rows.Scan(&p.Symbol, &p.Quantity, &p.AvgCost); err != nil {
+			return nil, fmt.Errorf("failed to scan position: %w", err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, rows.Err()
+}
+
+func (r *PostgresRepository) ListFills(ctx context.Context, userID, limit int) ([]Fill, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, order_id, account_id, user_id, symbol, side, quantity, price, filled_at
+		 FROM paper_fills WHERE user_id = $1 ORDER BY filled_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fills: %w", err)
+	}
+	defer rows.Close()
+
+	var fills []Fill
+	for rows.Next() {
+		var f Fill
+		if err := rows.Scan(&f.ID, &f.OrderID, &f.AccountID, &f.UserID, &f.Symbol, &f.Side, &f.Quantity, &f.Price, &f.FilledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan fill: %w", err)
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
@@ -0,0 +1,151 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+// maxOpenOrders caps how many orders one user may have resting. The
+// Matcher polls every resting order's symbol upstream.
+const maxOpenOrders = 50
+
+// FillFunc is called for every executed order.
+type FillFunc func(ctx context.Context, f *Fill)
+
+type Service struct {
+	repo     Repository
+	provider stocks.Provider
+	onFill   []FillFunc
+}
+
+func NewService(repo Repository, provider stocks.Provider) *Service {
+	return &Service{repo: repo, provider: provider}
+}
+
+// OnFill registers fn to be told about every fill, including those made by
+// the Matcher.
+func (s *Service) OnFill(fn FillFunc) {
+	s.onFill = append(s.onFill, fn)
+}
+
+func (s *Service) OpenAccount(ctx context.Context, userID int, startingCash float64) (*Account, error) {
+	if startingCash <= 0 || math.IsInf(startingCash, 0) || math.IsNaN(startingCash) {
+		return nil, fmt.Errorf("%w: starting cash must be positive", ErrInvalidOrder)
+	}
+	return s.repo.CreateAccount(ctx, userID, startingCash)
+}
+
+func (s *Service) Account(ctx context.Context, userID int) (*Account, error) {
+	return s.repo.GetAccount(ctx, userID)
+}
+
+func (s *Service) Positions(ctx context.Context, userID int) ([]Position, error) {
+	return s.repo.Positions(ctx, userID)
+}
+
+func (s *Service) Orders(ctx context.Context, userID int, status string, limit int) ([]Order, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.ListOrders(ctx, userID, status, limit)
+}
+
+func (s *Service) Fills(ctx context.Context, userID, limit int) ([]Fill, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.ListFills(ctx, userID, limit)
+}
+
+func (s *Service) CancelOrder(ctx context.Context, userID, orderID int) error {
+	return s.repo.CancelOrder(ctx, userID, orderID)
+}
+
+// PlaceOrder validates and stores o for userID. Market orders execute at
+// once against the current quote; limit and stop orders rest until the
+// Matcher sees a price that triggers them. The returned error is
+// ErrInsufficientCash or ErrInsufficientShares when a market order was
+// rejected; the order is returned either way.
+func (s *Service) PlaceOrder(ctx context.Context, userID int, o Order) (*Order, error) {
+	acct, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(&o); err != nil {
+		return nil, err
+	}
+	if o.Type != Market {
+		open, err := s.repo.ListOrders(ctx, userID, StatusOpen, maxOpenOrders)
+		if err != nil {
+			return nil, err
+		}
+		if len(open) >= maxOpenOrders {
+			return nil, fmt.Errorf("%w: at most %d open orders", ErrInvalidOrder, maxOpenOrders)
+		}
+	}
+
+	var quote *stocks.Quote
+	if o.Type == Market {
+		// Quote first so a provider outage doesn't leave an open market order
+		if quote, err = s.provider.Quote(ctx, o.Symbol); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrQuoteUnavailable, err)
+		}
+	}
+
+	o.AccountID, o.UserID, o.Status = acct.ID, userID, StatusOpen
+	if err := s.repo.CreateOrder(ctx, &o); err != nil {
+		return nil, err
+	}
+	if quote == nil {
+		return &o, nil
+	}
+	return s.execute(ctx, &o, quote.Price, time.Now())
+}
+
+func validate(o *Order) error {
+	symbol, ok := stocks.NormalizeSymbol(o.Symbol)
+	if !ok || o.Quantity <= 0 {
+		return fmt.Errorf("%w: a valid symbol and a positive quantity are required", ErrInvalidOrder)
+	}
+	o.Symbol = symbol
+	if o.Side != Buy && o.Side != Sell {
+		return fmt.Errorf("%w: side must be buy or sell", ErrInvalidOrder)
+	}
+	switch o.Type {
+	case Market:
+		o.LimitPrice, o.StopPrice = 0, 0
+	case Limit:
+		if o.LimitPrice <= 0 {
+			return fmt.Errorf("%w: limit orders need a positive limit_price", ErrInvalidOrder)
+		}
+		o.StopPrice = 0
+	case Stop:
+		if o.StopPrice <= 0 {
+			return fmt.Errorf("%w: stop orders need a positive stop_price", ErrInvalidOrder)
+		}
+		o.LimitPrice = 0
+	default:
+		return fmt.Errorf("%w: type must be market, limit or stop", ErrInvalidOrder)
+	}
+	return nil
+}
+
+// execute fills o at price and notifies OnFill callbacks.
+func (s *Service) execute(ctx context.Context, o *Order, price float64, at time.Time) (*Order, error) {
+	updated, fill, err := s.repo.ExecuteOrder(ctx, o.ID, price, at)
+	if errors.Is(err, ErrInsufficientCash) || errors.Is(err, ErrInsufficientShares) {
+		return updated, err
+	}
+	if err != nil {
+		return o, err
+	}
+	for _, fn := range s.onFill {
+		fn(ctx, fill)
+	}
+	return updated, nil
+}
@@ -0,0 +1,186 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// denyQuery adds an ID to the denylist, keeping the later expiry if it is
+// already there.
+const denyQuery = `
+	INSERT INTO revoked_tokens (id, user_id, expires_at) VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+`
+
+func (r *PostgresRepository) CreateRefreshToken(ctx context.Context, t *RefreshToken) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO refresh_tokens (user_id, session_id, token_hash, user_agent, ip, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		t.UserID, t.SessionID, t.Hash, t.UserAgent, t.IP, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RotateRefreshToken(ctx context.Context, oldHash []byte, next *RefreshToken, denyUntil time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		oldID             int
+		expiresAt         time.Time
+		usedAt, revokedAt *time.Time
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT id, user_id, session_id, expires_at, used_at, revoked_at
+		 FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, oldHash).
+		Scan(&oldID, &next.UserID, &next.SessionID, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	switch {
+	case revokedAt != nil, time.Now().After(expiresAt):
+		return ErrInvalidToken
+	case usedAt != nil:
+		if _, err := tx.Exec(ctx,
+			"UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = $1 AND revoked_at IS NULL",
+			next.SessionID); err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if _, err := tx.Exec(ctx, denyQuery, next.SessionID, next.UserID, denyUntil); err != nil {
+			return fmt.Errorf("failed to deny session: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit revocation: %w", err)
+		}
+		return ErrTokenReused
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", oldID); err != nil {
+		return fmt.Errorf("failed to retire refresh token: %w", err)
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO refresh_tokens (user_id, session_id, token_hash, user_agent, ip, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		next.UserID, next.SessionID, next.Hash, next.UserAgent, next.IP, next.ExpiresAt).Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit refresh: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RevokeSession(ctx context.Context, userID int, sessionID string, denyUntil time.Time) error {
+	n, err := r.revoke(ctx, userID, sessionID, denyUntil)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RevokeAllSessions(ctx context.Context, userID int, denyUntil time.Time) error {
+	_, err := r.revoke(ctx, userID, "", denyUntil)
+	return err
+}
+
+// revoke revokes userID's session, or all of them if sessionID is empty,
+// returning how many sessions were live.
+func (r *PostgresRepository) revoke(ctx context.Context, userID int, sessionID string, denyUntil time.Time) (int64, error) {
+	query := `
+		WITH revoked AS (
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE user_id = $1 AND ($2 = '' OR session_id = $2) AND revoked_at IS NULL
+			RETURNING session_id
+		)
+		INSERT INTO revoked_tokens (id, user_id, expires_at)
+		SELECT DISTINCT session_id, $1, $3::timestamptz FROM revoked
+		ON CONFLICT (id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`
+	tag, err := r.db.Exec(ctx, query, userID, sessionID, denyUntil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *PostgresRepository) Deny(ctx context.Context, userID int, id string, until time.Time) error {
+	if _, err := r.db.Exec(ctx, denyQuery, id, userID, until); err != nil {
+		return fmt.Errorf("failed to deny token: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) IsRevoked(ctx context.Context, ids []string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE id = ANY($1) AND expires_at > NOW())", ids).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check denylist: %w", err)
+	}
+	return revoked, nil
+}
+
+func (r *PostgresRepository) ListSessions(ctx context.Context, userID int) ([]Session, error) {
+	query := `
+		SELECT session_id,
+		       (ARRAY_AGG(user_agent ORDER BY created_at DESC))[1],
+		       (ARRAY_AGG(ip ORDER BY created_at DESC))[1],
+		       MIN(created_at), MAX(created_at), MAX(expires_at)
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		GROUP BY session_id
+		HAVING BOOL_OR(used_at IS NULL AND expires_at > NOW())
+		ORDER BY MAX(created_at) DESC
+	`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *PostgresRepository) Prune(ctx context.Context) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM revoked_tokens WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("failed to prune denylist: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("failed to prune refresh tokens: %w", err)
+	}
+	return nil
+}
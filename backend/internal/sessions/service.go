@@ -0,0 +1,159 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// UserSource looks up the current role and status of a session's user.
+type UserSource interface {
+	GetUser(ctx context.Context, userID int) (*users.User, error)
+}
+
+type Service struct {
+	repo  Repository
+	users UserSource
+	// RefreshTTL bounds how long a session may sit idle before the user
+	// must log in again; every refresh extends it.
+	RefreshTTL time.Duration
+	// PruneInterval is how often Run clears expired rows.
+	PruneInterval time.Duration
+}
+
+func NewService(repo Repository, users UserSource) *Service {
+	return &Service{repo: repo, users: users, RefreshTTL: 30 * 24 * time.Hour, PruneInterval: time.Hour}
+}
+
+// Start begins a new session for user.
+func (s *Service) Start(ctx context.Context, user *users.User, client Client) (*Tokens, error) {
+	refresh, token := s.newRefreshToken(client)
+	refresh.UserID = user.ID
+	refresh.SessionID = auth.NewTokenID()
+	if err := s.repo.CreateRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+	return s.tokens(user.ID, user.Role, refresh.SessionID, token)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// The user is looked up again, so role changes apply from the next
+// refresh and disabled users lose the session.
+func (s *Service) Refresh(ctx context.Context, token string, client Client) (*Tokens, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	next, nextToken := s.newRefreshToken(client)
+	if err := s.repo.RotateRefreshToken(ctx, hashToken(token), next, s.denyUntil()); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetUser(ctx, next.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		if err := s.repo.RevokeSession(ctx, user.ID, next.SessionID, s.denyUntil()); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+	return s.tokens(user.ID, user.Role, next.SessionID, nextToken)
+}
+
+// Logout ends the session the access token belongs to and revokes the
+// token itself.
+func (s *Service) Logout(ctx context.Context, claims *auth.Claims) error {
+	if claims.SessionID != "" {
+		err := s.repo.RevokeSession(ctx, claims.UserID, claims.SessionID, s.denyUntil())
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	until := time.Now().Add(auth.AccessTokenTTL)
+	if claims.ExpiresAt != nil {
+		until = claims.ExpiresAt.Time
+	}
+	return s.repo.Deny(ctx, claims.UserID, claims.ID, until)
+}
+
+// LogoutAll revokes every session userID has.
+func (s *Service) LogoutAll(ctx context.Context, userID int) error {
+	return s.repo.RevokeAllSessions(ctx, userID, s.denyUntil())
+}
+
+// RevokeSession ends one of userID's sessions, e.g. on a lost device.
+func (s *Service) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	return s.repo.RevokeSession(ctx, userID, sessionID, s.denyUntil())
+}
+
+// List returns userID's live sessions, marking the one currentID names.
+func (s *Service) List(ctx context.Context, userID int, currentID string) ([]Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// IsRevoked implements auth.Denylist.
+func (s *Service) IsRevoked(ctx context.Context, ids []string) (bool, error) {
+	return s.repo.IsRevoked(ctx, ids)
+}
+
+// Run prunes expired tokens every PruneInterval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.Prune(ctx); err != nil {
+				log.Printf("sessions: %v", err)
+			}
+		}
+	}
+}
+
+// denyUntil is how long a revoked session ID must stay denylisted: until
+// the last access token it could have issued expires.
+func (s *Service) denyUntil() time.Time {
+	return time.Now().Add(auth.AccessTokenTTL)
+}
+
+func (s *Service) newRefreshToken(client Client) (*RefreshToken, string) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return &RefreshToken{
+		Hash:      hashToken(token),
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(s.RefreshTTL),
+	}, token
+}
+
+func (s *Service) tokens(userID int, role, sessionID, refresh string) (*Tokens, error) {
+	access, _, err := auth.IssueAccessToken(userID, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,83 @@
+// Package sessions issues rotating refresh tokens and revokes sessions.
+//
+// Each login starts a session: a family of refresh tokens sharing an ID
+// that is also stamped into every access token as the sid claim. Using a
+// refresh token retires it and issues the next one in the family;
+// presenting a retired token again means it was stolen, so the whole
+// family is revoked. Revoking a session puts its ID on the denylist until
+// any access tokens it issued have expired.
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid refresh token")
+	ErrTokenReused     = errors.New("refresh token reused")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// RefreshToken is the stored form of a refresh token; only its hash is kept.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	SessionID string
+	Hash      []byte
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Session summarises a live refresh token family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// Tokens is what login and refresh hand back to the client.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Client describes where a session is being used from.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+type Repository interface {
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+	// RotateRefreshToken retires the token with oldHash and stores next in
+	// its family, copying UserID and SessionID into next. If the old token
+	// was already used its family is revoked (denylisted until denyUntil)
+	// and ErrTokenReused returned.
+	RotateRefreshToken(ctx context.Context, oldHash []byte, next *RefreshToken, denyUntil time.Time) error
+	// RevokeSession revokes one of userID's sessions and denylists its ID.
+	RevokeSession(ctx context.Context, userID int, sessionID string, denyUntil time.Time) error
+	// RevokeAllSessions does the same for every live session of userID.
+	RevokeAllSessions(ctx context.Context, userID int, denyUntil time.Time) error
+	// Deny adds a single token ID (a jti) to the denylist.
+	Deny(ctx context.Context, userID int, id string, until time.Time) error
+	IsRevoked(ctx context.Context, ids []string) (bool, error)
+	ListSessions(ctx context.Context, userID int) ([]Session, error)
+	// Prune deletes expired refresh tokens and denylist entries.
+	Prune(ctx context.Context) error
+}
@@ -0,0 +1,240 @@
+package sessions_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/sessions"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// memRepo follows the rotation rules the Postgres repository implements.
+type memRepo struct {
+	tokens  []*memToken
+	denied  map[string]time.Time
+	revoked map[string]bool
+}
+
+type memToken struct {
+	sessions.RefreshToken
+	used bool
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{denied: map[string]time.Time{}, revoked: map[string]bool{}}
+}
+
+func (m *memRepo) CreateRefreshToken(ctx context.Context, t *sessions.RefreshToken) error {
+	m.tokens = append(m.tokens, &memToken{RefreshToken: *t})
+	return nil
+}
+
+func (m *memRepo) RotateRefreshToken(ctx context.Context, oldHash []byte, next *sessions.RefreshToken, denyUntil time.Time) error {
+	for _, t := range m.tokens {
+		if !bytes.Equal(t.Hash, oldHash) {
+			continue
+		}
+		if m.revoked[t.SessionID] {
+			return sessions.ErrInvalidToken
+		}
+		if t.used {
+			m.RevokeSession(ctx, t.UserID, t.SessionID, denyUntil)
+			return sessions.ErrTokenReused
+		}
+		t.used = true
+		next.UserID, next.SessionID = t.UserID, t.SessionID
+		return m.CreateRefreshToken(ctx, next)
+	}
+	return sessions.ErrInvalidToken
+}
+
+func (m *memRepo) RevokeSession(ctx context.Context, userID int, sessionID string, denyUntil time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.SessionID == sessionID && !m.revoked[sessionID] {
+			m.revoked[sessionID] = true
+			m.denied[sessionID] = denyUntil
+			return nil
+		}
+	}
+	return sessions.ErrSessionNotFound
+}
+
+func (m *memRepo) RevokeAllSessions(ctx context.Context, userID int, denyUntil time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			m.RevokeSession(ctx, userID, t.SessionID, denyUntil)
+		}
+	}
+	return nil
+}
+
+func (m *memRepo) Deny(ctx context.Context, userID int, id string, until time.Time) error {
+	m.denied[id] = until
+	return nil
+}
+
+func (m *memRepo) IsRevoked(ctx context.Context, ids []string) (bool, error) {
+	for _, id := range ids {
+		if until, ok := m.denied[id]; ok && until.After(time.Now()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memRepo) ListSessions(ctx context.Context, userID int) ([]sessions.Session, error) {
+	var out []sessions.Session
+	for _, t := range m.tokens {
+		if t.UserID == userID && !t.used && !m.revoked[t.SessionID] {
+			out = append(out, sessions.Session{ID: t.SessionID})
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) Prune(ctx context.Context) error { return nil }
+
+// userTable serves users by ID; users not in it are active plain users.
+type userTable map[int]*users.User
+
+func (u userTable) GetUser(ctx context.Context, userID int) (*users.User, error) {
+	if user, ok := u[userID]; ok {
+		return user, nil
+	}
+	return &users.User{ID: userID, Role: auth.RoleUser}, nil
+}
+
+func setup(t *testing.T) (*sessions.Service, context.Context) {
+	t.Helper()
+	return setupWithUsers(t, userTable{})
+}
+
+func setupWithUsers(t *testing.T, table userTable) (*sessions.Service, context.Context) {
+	t.Helper()
+	svc := sessions.NewService(newMemRepo(), table)
+	auth.SetDenylist(svc)
+	t.Cleanup(func() { auth.SetDenylist(nil) })
+	return svc, context.Background()
+}
+
+func TestRefreshRotatesWithinSession(t *testing.T) {
+	svc, ctx := setup(t)
+	first, err := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := auth.Authenticate(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 5 || claims.SessionID == "" || claims.ID == "" {
+		t.Fatalf("expected user, session and token IDs, got %+v", claims)
+	}
+
+	second, err := svc.Refresh(ctx, first.RefreshToken, sessions.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	next, err := auth.Authenticate(ctx, second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.SessionID != claims.SessionID || next.ID == claims.ID {
+		t.Errorf("expected a new jti in the same session, got %+v after %+v", next, claims)
+	}
+
+	if _, err := svc.Refresh(ctx, "not-a-token", sessions.Client{}); !errors.Is(err, sessions.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestReusedRefreshTokenRevokesSession(t *testing.T) {
+	svc, ctx := setup(t)
+	first, _ := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{})
+	second, _ := svc.Refresh(ctx, first.RefreshToken, sessions.Client{})
+
+	if _, err := svc.Refresh(ctx, first.RefreshToken, sessions.Client{}); !errors.Is(err, sessions.ErrTokenReused) {
+		t.Fatalf("expected ErrTokenReused, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, second.RefreshToken, sessions.Client{}); err == nil {
+		t.Error("the legitimate refresh token should be revoked along with its session")
+	}
+	if _, err := auth.Authenticate(ctx, second.AccessToken); !errors.Is(err, auth.ErrRevoked) {
+		t.Errorf("expected the session's access token to be revoked, got %v", err)
+	}
+}
+
+func TestLogoutRevokesOnlyThatSession(t *testing.T) {
+	svc, ctx := setup(t)
+	laptop, _ := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{})
+	phone, _ := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{})
+
+	claims, _ := auth.Authenticate(ctx, laptop.AccessToken)
+	if err := svc.Logout(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(ctx, laptop.AccessToken); !errors.Is(err, auth.ErrRevoked) {
+		t.Errorf("expected the logged out token to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, laptop.RefreshToken, sessions.Client{}); err == nil {
+		t.Error("expected the logged out refresh token to be rejected")
+	}
+	if _, err := auth.Authenticate(ctx, phone.AccessToken); err != nil {
+		t.Errorf("other sessions should survive: %v", err)
+	}
+
+	list, _ := svc.List(ctx, 5, "")
+	if len(list) != 1 {
+		t.Errorf("expected one live session, got %v", list)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	svc, ctx := setup(t)
+	a, _ := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{})
+	b, _ := svc.Start(ctx, &users.User{ID: 5}, sessions.Client{})
+	other, _ := svc.Start(ctx, &users.User{ID: 6}, sessions.Client{})
+
+	if err := svc.LogoutAll(ctx, 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, tokens := range []*sessions.Tokens{a, b} {
+		if _, err := auth.Authenticate(ctx, tokens.AccessToken); !errors.Is(err, auth.ErrRevoked) {
+			t.Errorf("expected ErrRevoked, got %v", err)
+		}
+	}
+	if _, err := auth.Authenticate(ctx, other.AccessToken); err != nil {
+		t.Errorf("another user's session should survive: %v", err)
+	}
+}
+
+func TestRefreshPicksUpRoleChangesAndDisabling(t *testing.T) {
+	table := userTable{5: {ID: 5, Role: auth.RoleUser}}
+	svc, ctx := setupWithUsers(t, table)
+	first, _ := svc.Start(ctx, table[5], sessions.Client{})
+
+	table[5].Role = auth.RoleAnalyst
+	second, err := svc.Refresh(ctx, first.RefreshToken, sessions.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := auth.Authenticate(ctx, second.AccessToken); claims.Role != auth.RoleAnalyst {
+		t.Errorf("expected the refreshed token to carry the new role, got %q", claims.Role)
+	}
+
+	now := time.Now()
+	table[5].DisabledAt = &now
+	if _, err := svc.Refresh(ctx, second.RefreshToken, sessions.Client{}); !errors.Is(err, sessions.ErrInvalidToken) {
+		t.Errorf("expected a disabled user's refresh to fail, got %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, second.AccessToken); !errors.Is(err, auth.ErrRevoked) {
+		t.Errorf("expected the disabled user's session to be revoked, got %v", err)
+	}
+}
@@ -113,11 +113,19 @@ func (a *AlphaVantage) Quote(ctx context.Context, symbol string) (*Quote, error)
 	return qp, nil
 }
 
+// compactBars is how many bars AlphaVantage returns for outputsize=compact;
+// asking for more needs the full series, e.g. for a 200-day average.
+const compactBars = 100
+
 func (a *AlphaVantage) Intraday(ctx context.Context, symbol, interval string, limit int) ([]Candle, error) {
+	outputSize := "compact"
+	if limit > compactBars {
+		outputSize = "full"
+	}
 	q := url.Values{
 		"function":   {"TIME_SERIES_DAILY"},
 		"symbol":     {symbol},
-		"outputsize": {"compact"},
+		"outputsize": {outputSize},
 		"datatype":   {"json"},
 		"apikey":     {a.apiKey},
 	}
@@ -0,0 +1,37 @@
+package stocks_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestAlphaVantageRequestsFullSeriesForLongHistories(t *testing.T) {
+	var outputSize string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		outputSize = r.URL.Query().Get("outputsize")
+		body := `{"Time Series (Daily)": {"2024-01-02": {"4. close": "10.0"}}}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}
+	av := stocks.NewAlphaVantage("key", client)
+
+	for _, tc := range []struct {
+		limit int
+		want  string
+	}{{50, "compact"}, {100, "compact"}, {200, "full"}} {
+		if _, err := av.Intraday(context.Background(), "AAPL", "daily", tc.limit); err != nil {
+			t.Fatal(err)
+		}
+		if outputSize != tc.want {
+			t.Errorf("limit %d: outputsize = %q, want %q", tc.limit, outputSize, tc.want)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package stocks
 import (
 	"context"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/jamesfulreader/gostocks/internal/database"
+	"github.com/jamesfulreader/gostocks/internal/market"
 )
 
 type DatabaseService interface {
@@ -18,6 +20,13 @@ type CachedProvider struct {
 	Upstream Provider
 	DB       DatabaseService
 	CacheTTL time.Duration
+	// ClosedTTL, when set, replaces CacheTTL outside the regular session.
+	// Prices don't move while the market is closed, so a quote cached
+	// after the last close stays fresh for this long.
+	ClosedTTL time.Duration
+
+	// purgedAt is when Purge was last called, as Unix nanoseconds
+	purgedAt atomic.Int64
 }
 
 func NewCachedProvider(upstream Provider, db DatabaseService, ttl time.Duration) *CachedProvider {
@@ -35,7 +44,7 @@ func (c *CachedProvider) Quote(ctx context.Context, symbol string) (*Quote, erro
 	// 1. Check DB for fresh data
 	latest, err := c.DB.GetLatestStockPrice(ctx, symbol)
 	if err == nil && latest != nil {
-		if time.Since(latest.Timestamp) < c.CacheTTL {
+		if c.fresh(latest.Timestamp, time.Now()) {
 			log.Printf("Create Cache Hit for %s", symbol)
 			return &Quote{
 				Symbol: latest.Symbol,
@@ -100,6 +109,23 @@ func (c *CachedProvider) Intraday(ctx context.Context, symbol, interval string,
 	return c.Upstream.Intraday(ctx, symbol, interval, limit)
 }
 
+// Purge stops prices cached so far from being served; the next request
+// for each symbol goes upstream. Stored prices are kept as history.
+func (c *CachedProvider) Purge() {
+	c.purgedAt.Store(time.Now().UnixNano())
+}
+
+// fresh reports whether a price cached at ts can still be served at now.
+func (c *CachedProvider) fresh(ts, now time.Time) bool {
+	if !ts.After(time.Unix(0, c.purgedAt.Load())) {
+		return false
+	}
+	if c.ClosedTTL > 0 && !market.IsOpen(now) {
+		return !ts.Before(market.LastClose(now)) && now.Sub(ts) < c.ClosedTTL
+	}
+	return now.Sub(ts) < c.CacheTTL
+}
+
 func stringPointer(s string) *string {
 	return &s
 }
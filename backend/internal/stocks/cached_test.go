@@ -102,3 +102,20 @@ func TestCachedProvider(t *testing.T) {
 		t.Errorf("Expected fresh price 102.0, got %f", q3.Price)
 	}
 }
+
+func TestCachedProviderPurge(t *testing.T) {
+	upstream := &MockUpstream{}
+	db := &MockDB{Store: map[string]database.StockPrice{
+		"TEST": {Symbol: "TEST", Price: 50, Timestamp: time.Now().Add(-time.Second)},
+	}}
+	provider := stocks.NewCachedProvider(upstream, db, time.Minute)
+	ctx := context.Background()
+
+	if q, _ := provider.Quote(ctx, "TEST"); q.Price != 50 || upstream.Count != 0 {
+		t.Fatalf("expected a cache hit, got price %.2f after %d upstream calls", q.Price, upstream.Count)
+	}
+	provider.Purge()
+	if q, _ := provider.Quote(ctx, "TEST"); q.Price != 101 || upstream.Count != 1 {
+		t.Errorf("expected purge to force an upstream call, got price %.2f after %d calls", q.Price, upstream.Count)
+	}
+}
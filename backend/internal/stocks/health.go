@@ -0,0 +1,99 @@
+package stocks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProviderHealth summarises recent calls to one upstream provider.
+type ProviderHealth struct {
+	Name         string     `json:"name"`
+	Healthy      bool       `json:"healthy"`
+	Requests     int64      `json:"requests"`
+	Failures     int64      `json:"failures"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// HealthReporter is implemented by providers that can report on the
+// upstreams behind them.
+type HealthReporter interface {
+	Health() []ProviderHealth
+}
+
+// Monitored wraps a provider and records the outcome of every call.
+type Monitored struct {
+	Name     string
+	Upstream Provider
+
+	mu      sync.Mutex
+	health  ProviderHealth
+	latency time.Duration
+}
+
+func NewMonitored(name string, upstream Provider) *Monitored {
+	return &Monitored{Name: name, Upstream: upstream}
+}
+
+func (m *Monitored) Quote(ctx context.Context, symbol string) (*Quote, error) {
+	start := time.Now()
+	q, err := m.Upstream.Quote(ctx, symbol)
+	m.record(start, err)
+	return q, err
+}
+
+func (m *Monitored) Intraday(ctx context.Context, symbol, interval string, limit int) ([]Candle, error) {
+	start := time.Now()
+	c, err := m.Upstream.Intraday(ctx, symbol, interval, limit)
+	m.record(start, err)
+	return c, err
+}
+
+func (m *Monitored) record(start time.Time, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health.Requests++
+	m.latency += now.Sub(start)
+	if err != nil {
+		m.health.Failures++
+		m.health.LastFailure = &now
+		m.health.LastError = err.Error()
+		return
+	}
+	m.health.LastSuccess = &now
+}
+
+// Health reports the provider as healthy until a call fails, and again
+// once a later call succeeds.
+func (m *Monitored) Health() []ProviderHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.health
+	h.Name = m.Name
+	h.Healthy = h.LastFailure == nil || (h.LastSuccess != nil && h.LastSuccess.After(*h.LastFailure))
+	if h.Requests > 0 {
+		h.AvgLatencyMs = float64(m.latency.Microseconds()) / 1000 / float64(h.Requests)
+	}
+	return []ProviderHealth{h}
+}
+
+// Health reports on both the primary and secondary providers.
+func (f *Fallback) Health() []ProviderHealth {
+	return append(providerHealth(f.Primary), providerHealth(f.Secondary)...)
+}
+
+// Health reports on the upstream provider.
+func (c *CachedProvider) Health() []ProviderHealth {
+	return providerHealth(c.Upstream)
+}
+
+func providerHealth(p Provider) []ProviderHealth {
+	if hr, ok := p.(HealthReporter); ok {
+		return hr.Health()
+	}
+	return nil
+}
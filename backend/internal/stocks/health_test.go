@@ -0,0 +1,54 @@
+package stocks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jamesfulreader/gostocks/internal/database"
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+type flakyUpstream struct {
+	MockUpstream
+	fail bool
+}
+
+func (f *flakyUpstream) Quote(ctx context.Context, symbol string) (*stocks.Quote, error) {
+	if f.fail {
+		return nil, errors.New("rate limited")
+	}
+	return f.MockUpstream.Quote(ctx, symbol)
+}
+
+func TestMonitoredHealth(t *testing.T) {
+	primary := &flakyUpstream{}
+	secondary := &MockUpstream{}
+	monitored := stocks.NewMonitored("primary", primary)
+	provider := stocks.NewCachedProvider(
+		stocks.NewFallback(monitored, stocks.NewMonitored("secondary", secondary)),
+		&MockDB{Store: map[string]database.StockPrice{}}, 0)
+	ctx := context.Background()
+
+	monitored.Quote(ctx, "AAPL")
+	primary.fail = true
+	monitored.Quote(ctx, "AAPL")
+
+	health := provider.Health()
+	if len(health) != 2 || health[0].Name != "primary" || health[1].Name != "secondary" {
+		t.Fatalf("expected both providers, got %+v", health)
+	}
+	h := health[0]
+	if h.Healthy || h.Requests != 2 || h.Failures != 1 || h.LastError != "rate limited" {
+		t.Errorf("expected an unhealthy primary after a failure, got %+v", h)
+	}
+	if !health[1].Healthy {
+		t.Error("an unused provider should report healthy")
+	}
+
+	primary.fail = false
+	monitored.Quote(ctx, "AAPL")
+	if h := monitored.Health()[0]; !h.Healthy {
+		t.Errorf("expected recovery after a success, got %+v", h)
+	}
+}
@@ -0,0 +1,21 @@
+package stocks
+
+import "strings"
+
+// maxSymbolLength matches the symbol columns in the schema.
+const maxSymbolLength = 10
+
+// NormalizeSymbol trims and upper-cases a ticker, reporting false unless
+// the result is 1-10 letters, digits, dots or dashes, e.g. "BRK.B".
+func NormalizeSymbol(s string) (string, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" || len(s) > maxSymbolLength {
+		return "", false
+	}
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			return "", false
+		}
+	}
+	return s, true
+}
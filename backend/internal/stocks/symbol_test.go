@@ -0,0 +1,20 @@
+package stocks_test
+
+import (
+	"testing"
+
+	"github.com/jamesfulreader/gostocks/internal/stocks"
+)
+
+func TestNormalizeSymbol(t *testing.T) {
+	for in, want := range map[string]string{" aapl ": "AAPL", "brk.b": "BRK.B", "BF-B": "BF-B"} {
+		if got, ok := stocks.NormalizeSymbol(in); !ok || got != want {
+			t.Errorf("NormalizeSymbol(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "  ", "TOOLONGSYMBOL", "AA PL", "AAPL;", "ÄAPL"} {
+		if got, ok := stocks.NormalizeSymbol(in); ok {
+			t.Errorf("NormalizeSymbol(%q) = %q, want rejection", in, got)
+		}
+	}
+}
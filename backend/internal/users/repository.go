@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,40 +18,155 @@ func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
-func (r *PostgresRepository) CreateUser(ctx context.Context, email, passwordHash string) (*User, error) {
+const userColumns = "id, email, password_hash, role, disabled_at, email_verified_at, created_at"
+
+func scanUser(row pgx.Row) (*User, error) {
 	var user User
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.DisabledAt, &user.EmailVerifiedAt, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresRepository) CreateUser(ctx context.Context, email, passwordHash, role string) (*User, error) {
+	user := User{Email: email, PasswordHash: passwordHash, Role: role}
 	err := r.db.QueryRow(ctx,
-		"INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id, email, created_at",
-		email, passwordHash).Scan(&user.ID, &user.Email, &user.CreatedAt)
+		`INSERT INTO users (email, password_hash, role) VALUES ($1, $2, $3)
+		 ON CONFLICT (email) DO NOTHING RETURNING id, created_at`,
+		email, passwordHash, role).Scan(&user.ID, &user.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
-	user.PasswordHash = passwordHash
 	return &user, nil
 }
 
 func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	var user User
-	err := r.db.QueryRow(ctx, "SELECT id, email, password_hash, created_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	return scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", email))
+}
+
+func (r *PostgresRepository) GetUserByID(ctx context.Context, id int) (*User, error) {
+	return scanUser(r.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (r *PostgresRepository) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT "+userColumns+" FROM users ORDER BY id LIMIT $1 OFFSET $2", limit, offset)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("user not found")
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var list []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		list = append(list, *user)
 	}
-	return &user, nil
+	return list, rows.Err()
 }
 
-func (r *PostgresRepository) AddToPortfolio(ctx context.Context, userID int, symbol string) error {
-	_, err := r.db.Exec(ctx, "INSERT INTO user_portfolios (user_id, symbol) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, symbol)
+func (r *PostgresRepository) SetRole(ctx context.Context, id int, role string) (*User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		"UPDATE users SET role = $2 WHERE id = $1 RETURNING "+userColumns, id, role))
+}
+
+func (r *PostgresRepository) SetDisabled(ctx context.Context, id int, disabled bool) (*User, error) {
+	// Keep the original timestamp when disabling twice
+	return scanUser(r.db.QueryRow(ctx, `
+		UPDATE users SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END
+		WHERE id = $1 RETURNING `+userColumns, id, disabled))
+}
+
+func (r *PostgresRepository) PromoteAdmins(ctx context.Context, emails []string) error {
+	_, err := r.db.Exec(ctx, "UPDATE users SET role = 'admin' WHERE LOWER(email) = ANY($1) AND role <> 'admin'", emails)
 	if err != nil {
-		return fmt.Errorf("failed to add to portfolio: %w", err)
+		return fmt.Errorf("failed to promote admins: %w", err)
 	}
 	return nil
 }
 
-func (r *PostgresRepository) GetPortfolio(ctx context.Context, userID int) ([]string, error) {
-	rows, err := r.db.Query(ctx, "SELECT symbol FROM user_portfolios WHERE user_id = $1 ORDER BY added_at DESC", userID)
+// portfolioRole looks up actorID's role on ownerID's portfolio. Inside a
+// transaction the membership is locked so it can't be revoked mid-change.
+func portfolioRole(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...any) pgx.Row
+}, actorID, ownerID int) (string, error) {
+	if actorID == ownerID {
+		return PortfolioOwner, nil
+	}
+	var role string
+	err := q.QueryRow(ctx,
+		"SELECT role FROM portfolio_members WHERE owner_id = $1 AND member_id = $2 FOR SHARE",
+		ownerID, actorID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrPortfolioNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check portfolio access: %w", err)
+	}
+	return role, nil
+}
+
+// changePortfolio runs a portfolio change for actorID once they are
+// allowed to make it, reporting whether query touched any rows.
+func (r *PostgresRepository) changePortfolio(ctx context.Context, actorID, ownerID int, query string, args ...any) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	role, err := portfolioRole(ctx, tx, actorID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if role == PortfolioViewer {
+		return false, ErrPortfolioReadOnly
+	}
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) AddToPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	// Only log the event when the symbol wasn't already held
+	changed, err := r.changePortfolio(ctx, actorID, ownerID, `
+		WITH added AS (
+			INSERT INTO user_portfolios (user_id, symbol) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+			RETURNING user_id, symbol, added_at
+		)
+		INSERT INTO portfolio_events (user_id, actor_id, symbol, action, at)
+		SELECT user_id, $3, symbol, 'add', added_at FROM added`, ownerID, symbol, actorID)
+	if err != nil && !errors.Is(err, ErrPortfolioNotFound) && !errors.Is(err, ErrPortfolioReadOnly) {
+		return false, fmt.Errorf("failed to add to portfolio: %w", err)
+	}
+	return changed, err
+}
+
+func (r *PostgresRepository) GetPortfolio(ctx context.Context, actorID, ownerID int) ([]string, error) {
+	// The role check and the read share a transaction so the FOR SHARE lock
+	// holds until the symbols are read.
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := portfolioRole(ctx, tx, actorID, ownerID); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, "SELECT symbol FROM user_portfolios WHERE user_id = $1 ORDER BY added_at DESC", ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get portfolio: %w", err)
 	}
@@ -64,13 +180,200 @@ func (r *PostgresRepository) GetPortfolio(ctx context.Context, userID int) ([]st
 		}
 		symbols = append(symbols, symbol)
 	}
-	return symbols, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+	rows.Close()
+	return symbols, tx.Commit(ctx)
 }
 
-func (r *PostgresRepository) RemoveFromPortfolio(ctx context.Context, userID int, symbol string) error {
-	_, err := r.db.Exec(ctx, "DELETE FROM user_portfolios WHERE user_id = $1 AND symbol = $2", userID, symbol)
+func (r *PostgresRepository) RemoveFromPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	changed, err := r.changePortfolio(ctx, actorID, ownerID, `
+		WITH removed AS (
+			DELETE FROM user_portfolios WHERE user_id = $1 AND symbol = $2
+			RETURNING user_id, symbol
+		)
+		INSERT INTO portfolio_events (user_id, actor_id, symbol, action)
+		SELECT user_id, $3, symbol, 'remove' FROM removed`, ownerID, symbol, actorID)
+	if err != nil && !errors.Is(err, ErrPortfolioNotFound) && !errors.Is(err, ErrPortfolioReadOnly) {
+		return false, fmt.Errorf("failed to remove from portfolio: %w", err)
+	}
+	return changed, err
+}
+
+// PortfolioHistory returns userID's add and remove events, oldest first.
+// Holdings that predate the event log count as added when they were.
+func (r *PostgresRepository) PortfolioHistory(ctx context.Context, userID int) ([]PortfolioEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT symbol, action, COALESCE(actor_id, 0), at FROM portfolio_events WHERE user_id = $1
+		UNION ALL
+		SELECT p.symbol, 'add', 0, p.added_at FROM user_portfolios p
+		WHERE p.user_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM portfolio_events e WHERE e.user_id = p.user_id AND e.symbol = p.symbol)
+		ORDER BY at`, userID)
 	if err != nil {
-		return fmt.Errorf("failed to remove from portfolio: %w", err)
+		return nil, fmt.Errorf("failed to get portfolio history: %w", err)
+	}
+	defer rows.Close()
+
+	var events []PortfolioEvent
+	for rows.Next() {
+		var e PortfolioEvent
+		if err := rows.Scan(&e.Symbol, &e.Action, &e.ActorID, &e.At); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *PostgresRepository) PortfolioRole(ctx context.Context, actorID, ownerID int) (string, error) {
+	return portfolioRole(ctx, r.db, actorID, ownerID)
+}
+
+func (r *PostgresRepository) SetPortfolioMember(ctx context.Context, ownerID, memberID int, role string) (*PortfolioMember, error) {
+	m := PortfolioMember{UserID: memberID, Role: role}
+	err := r.db.QueryRow(ctx, `
+		WITH m AS (
+			INSERT INTO portfolio_members (owner_id, member_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (owner_id, member_id) DO UPDATE SET role = EXCLUDED.role
+			RETURNING member_id, created_at
+		)
+		SELECT u.email, m.created_at FROM m JOIN users u ON u.id = m.member_id`,
+		ownerID, memberID, role).Scan(&m.Email, &m.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share portfolio: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *PostgresRepository) RemovePortfolioMember(ctx context.Context, ownerID, memberID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM portfolio_members WHERE owner_id = $1 AND member_id = $2", ownerID, memberID)
+	if err != nil {
+		return fmt.Errorf("failed to remove portfolio member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
 	}
 	return nil
 }
+
+func (r *PostgresRepository) PortfolioMembers(ctx context.Context, ownerID int) ([]PortfolioMember, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.member_id, u.email, m.role, m.created_at
+		FROM portfolio_members m JOIN users u ON u.id = m.member_id
+		WHERE m.owner_id = $1 ORDER BY m.created_at`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list portfolio members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []PortfolioMember{}
+	for rows.Next() {
+		var m PortfolioMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio member: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *PostgresRepository) SharedPortfolios(ctx context.Context, memberID int) ([]SharedPortfolio, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.owner_id, u.email, m.role, m.created_at
+		FROM portfolio_members m JOIN users u ON u.id = m.owner_id
+		WHERE m.member_id = $1 ORDER BY m.created_at`, memberID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared portfolios: %w", err)
+	}
+	defer rows.Close()
+
+	shared := []SharedPortfolio{}
+	for rows.Next() {
+		var p SharedPortfolio
+		if err := rows.Scan(&p.OwnerID, &p.OwnerEmail, &p.Role, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shared portfolio: %w", err)
+		}
+		shared = append(shared, p)
+	}
+	return shared, rows.Err()
+}
+
+func (r *PostgresRepository) MarkEmailVerified(ctx context.Context, id int, at time.Time) (*User, error) {
+	return scanUser(r.db.QueryRow(ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2) WHERE id = $1 RETURNING "+userColumns,
+		id, at))
+}
+
+func (r *PostgresRepository) CreateToken(ctx context.Context, t *Token) error {
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO user_tokens (hash, user_id, purpose, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)",
+		t.Hash, t.UserID, t.Purpose, t.ExpiresAt, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	return nil
+}
+
+// useToken marks an unused, unexpired token as used and returns its user.
+func useToken(ctx context.Context, tx pgx.Tx, hash []byte, purpose string, now time.Time) (int, error) {
+	var userID int
+	err := tx.QueryRow(ctx, `
+		UPDATE user_tokens SET used_at = $3
+		WHERE hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING user_id`, hash, purpose, now).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to use token: %w", err)
+	}
+	return userID, nil
+}
+
+func (r *PostgresRepository) VerifyEmail(ctx context.Context, tokenHash []byte, now time.Time) (*User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	userID, err := useToken(ctx, tx, tokenHash, PurposeVerifyEmail, now)
+	if err != nil {
+		return nil, err
+	}
+	user, err := scanUser(tx.QueryRow(ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2) WHERE id = $1 RETURNING "+userColumns,
+		userID, now))
+	if err != nil {
+		return nil, err
+	}
+	return user, tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string, now time.Time) (*User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	userID, err := useToken(ctx, tx, tokenHash, PurposePasswordReset, now)
+	if err != nil {
+		return nil, err
+	}
+	// Receiving the reset email also proves the address
+	user, err := scanUser(tx.QueryRow(ctx, `
+		UPDATE users SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, $3)
+		WHERE id = $1 RETURNING `+userColumns, userID, passwordHash, now))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		"DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userID, PurposePasswordReset); err != nil {
+		return nil, fmt.Errorf("failed to discard reset tokens: %w", err)
+	}
+	return user, tx.Commit(ctx)
+}
@@ -3,46 +3,229 @@ package users
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/jamesfulreader/gostocks/internal/auth"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Service struct {
 	repo Repository
+	// AdminEmails are given the admin role when they register, and by
+	// PromoteAdmins, so a fresh install has someone who can use the admin API.
+	AdminEmails    []string
+	PasswordPolicy PasswordPolicy
+	VerifyTokenTTL time.Duration
+	ResetTokenTTL  time.Duration
 }
 
 func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+	return &Service{
+		repo:           repo,
+		PasswordPolicy: DefaultPasswordPolicy(),
+		VerifyTokenTTL: 48 * time.Hour,
+		ResetTokenTTL:  time.Hour,
+	}
 }
 
+// Register creates an unverified user. It returns ErrInvalidEmail,
+// ErrWeakPassword or ErrEmailTaken for bad input.
 func (s *Service) Register(ctx context.Context, email, password string) (*User, error) {
+	email = NormalizeEmail(email)
+	if err := ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := s.PasswordPolicy.Validate(password); err != nil {
+		return nil, err
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
-	return s.repo.CreateUser(ctx, email, string(hashedPassword))
+	role := auth.RoleUser
+	if s.isAdminEmail(email) {
+		role = auth.RoleAdmin
+	}
+	return s.repo.CreateUser(ctx, email, string(hashedPassword), role)
 }
 
-func (s *Service) Login(ctx context.Context, email, password string) (*User, error) {
+func (s *Service) isAdminEmail(email string) bool {
+	for _, admin := range s.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// PromoteAdmins grants the admin role to existing users in AdminEmails.
+func (s *Service) PromoteAdmins(ctx context.Context) error {
+	if len(s.AdminEmails) == 0 {
+		return nil
+	}
+	emails := make([]string, len(s.AdminEmails))
+	for i, email := range s.AdminEmails {
+		emails[i] = strings.ToLower(email)
+	}
+	return s.repo.PromoteAdmins(ctx, emails)
+}
+
+// EnsureVerifiedUser returns the user with email, creating them if needed,
+// and marks the address verified. It is for sign-ins where an identity
+// provider has vouched for the email; users it creates have no password.
+//
+// It returns ErrUnverifiedAccount rather than adopt an unverified password
+// account: anyone could have registered that before the address's owner
+// signed in, and would keep its password, sessions and API keys.
+func (s *Service) EnsureVerifiedUser(ctx context.Context, email string) (*User, error) {
+	email = NormalizeEmail(email)
 	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		role := auth.RoleUser
+		if s.isAdminEmail(email) {
+			role = auth.RoleAdmin
+		}
+		user, err = s.repo.CreateUser(ctx, email, "", role)
+		if errors.Is(err, ErrEmailTaken) {
+			// Created by a concurrent sign-in
+			user, err = s.repo.GetUserByEmail(ctx, email)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if user.EmailVerifiedAt != nil {
+		return user, nil
+	}
+	if user.PasswordHash != "" {
+		return nil, ErrUnverifiedAccount
+	}
+	// Passwordless, so created by a concurrent sign-in not yet verified
+	return s.repo.MarkEmailVerified(ctx, user.ID, time.Now())
+}
+
+// dummyHash is compared against when the email is unknown or has no
+// password, so a miss costs as long as a wrong password and response times
+// don't reveal which accounts exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
 
+// Login returns ErrInvalidCredentials for both an unknown email and a wrong
+// password.
+func (s *Service) Login(ctx context.Context, email, password string) (*User, error) {
+	user, err := s.repo.GetUserByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Single sign-on users have no password; check one anyway so they
+	// answer as slowly as everyone else
+	if user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled() {
+		return nil, ErrUserDisabled
 	}
 	return user, nil
 }
 
+func (s *Service) GetUser(ctx context.Context, userID int) (*User, error) {
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+func (s *Service) ListUsers(ctx context.Context, limit, offset int) ([]User, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListUsers(ctx, limit, offset)
+}
+
+func (s *Service) SetRole(ctx context.Context, userID int, role string) (*User, error) {
+	if !auth.ValidRole(role) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	return s.repo.SetRole(ctx, userID, role)
+}
+
+func (s *Service) SetDisabled(ctx context.Context, userID int, disabled bool) (*User, error) {
+	return s.repo.SetDisabled(ctx, userID, disabled)
+}
+
+// GetPortfolio returns userID's own portfolio.
 func (s *Service) GetPortfolio(ctx context.Context, userID int) ([]string, error) {
-	return s.repo.GetPortfolio(ctx, userID)
+	return s.repo.GetPortfolio(ctx, userID, userID)
+}
+
+// ViewPortfolio returns ownerID's portfolio if actorID may see it.
+func (s *Service) ViewPortfolio(ctx context.Context, actorID, ownerID int) ([]string, error) {
+	return s.repo.GetPortfolio(ctx, actorID, ownerID)
+}
+
+// AddToPortfolio reports whether symbol was newly added.
+func (s *Service) AddToPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	return s.repo.AddToPortfolio(ctx, actorID, ownerID, symbol)
+}
+
+// RemoveFromPortfolio reports whether symbol was held.
+func (s *Service) RemoveFromPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	return s.repo.RemoveFromPortfolio(ctx, actorID, ownerID, symbol)
+}
+
+// ViewPortfolioHistory returns ownerID's portfolio changes, and who made
+// them, if actorID may see the portfolio.
+func (s *Service) ViewPortfolioHistory(ctx context.Context, actorID, ownerID int) ([]PortfolioEvent, error) {
+	if _, err := s.repo.PortfolioRole(ctx, actorID, ownerID); err != nil {
+		return nil, err
+	}
+	return s.repo.PortfolioHistory(ctx, ownerID)
+}
+
+// SharePortfolio gives the user with email a role on ownerID's portfolio,
+// or changes the role they already have.
+func (s *Service) SharePortfolio(ctx context.Context, ownerID int, email, role string) (*PortfolioMember, error) {
+	if role != PortfolioViewer && role != PortfolioEditor {
+		return nil, fmt.Errorf("%w: role must be %q or %q", ErrInvalidMember, PortfolioViewer, PortfolioEditor)
+	}
+	member, err := s.repo.GetUserByEmail(ctx, NormalizeEmail(email))
+	if err != nil {
+		return nil, err
+	}
+	if member.ID == ownerID {
+		return nil, fmt.Errorf("%w: can't share a portfolio with its owner", ErrInvalidMember)
+	}
+	return s.repo.SetPortfolioMember(ctx, ownerID, member.ID, role)
+}
+
+// UnsharePortfolio removes memberID from ownerID's portfolio. Owners use
+// it to revoke access and members to leave.
+func (s *Service) UnsharePortfolio(ctx context.Context, ownerID, memberID int) error {
+	return s.repo.RemovePortfolioMember(ctx, ownerID, memberID)
+}
+
+func (s *Service) PortfolioMembers(ctx context.Context, ownerID int) ([]PortfolioMember, error) {
+	return s.repo.PortfolioMembers(ctx, ownerID)
 }
 
-func (s *Service) AddToPortfolio(ctx context.Context, userID int, symbol string) error {
-	return s.repo.AddToPortfolio(ctx, userID, symbol)
+func (s *Service) SharedPortfolios(ctx context.Context, userID int) ([]SharedPortfolio, error) {
+	return s.repo.SharedPortfolios(ctx, userID)
 }
 
-func (s *Service) RemoveFromPortfolio(ctx context.Context, userID int, symbol string) error {
-	return s.repo.RemoveFromPortfolio(ctx, userID, symbol)
+func (s *Service) PortfolioHistory(ctx context.Context, userID int) ([]PortfolioEvent, error) {
+	return s.repo.PortfolioHistory(ctx, userID)
 }
@@ -0,0 +1,99 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token purposes.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposePasswordReset = "password_reset"
+)
+
+// Token is a single-use emailed token. Only its hash is stored.
+type Token struct {
+	Hash      []byte
+	UserID    int
+	Purpose   string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// IssuedToken is a freshly created token and the user it was sent for.
+type IssuedToken struct {
+	User      *User
+	Token     string
+	ExpiresAt time.Time
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func (s *Service) issueToken(ctx context.Context, user *User, purpose string, ttl time.Duration) (*IssuedToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	now := time.Now()
+	t := &Token{Hash: hashToken(token), UserID: user.ID, Purpose: purpose, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	if err := s.repo.CreateToken(ctx, t); err != nil {
+		return nil, err
+	}
+	return &IssuedToken{User: user, Token: token, ExpiresAt: t.ExpiresAt}, nil
+}
+
+// RequestVerification issues a verify-email token for userID. It returns
+// nil, nil if the address is already verified.
+func (s *Service) RequestVerification(ctx context.Context, userID int) (*IssuedToken, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil, nil
+	}
+	return s.issueToken(ctx, user, PurposeVerifyEmail, s.VerifyTokenTTL)
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, token string) (*User, error) {
+	return s.repo.VerifyEmail(ctx, hashToken(token), time.Now())
+}
+
+// RequestPasswordReset issues a reset token for email. Unknown and disabled
+// accounts get nil, nil so callers answer the same way for every address.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) (*IssuedToken, error) {
+	user, err := s.repo.GetUserByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		return nil, nil
+	}
+	return s.issueToken(ctx, user, PurposePasswordReset, s.ResetTokenTTL)
+}
+
+// ResetPassword sets a new password using a reset token. The token, and
+// any others outstanding for the user, can't be used again.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) (*User, error) {
+	if err := s.PasswordPolicy.Validate(password); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ResetPassword(ctx, hashToken(token), string(hash), time.Now())
+}
@@ -2,20 +2,113 @@ package users
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserDisabled       = errors.New("user disabled")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrWeakPassword       = errors.New("password does not meet policy")
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrUnverifiedAccount  = errors.New("an unverified account already uses this email")
+	ErrPortfolioNotFound  = errors.New("portfolio not found")
+	ErrPortfolioReadOnly  = errors.New("portfolio is read-only")
+	ErrInvalidMember      = errors.New("invalid portfolio member")
+	ErrMemberNotFound     = errors.New("portfolio member not found")
+)
+
 type User struct {
-	ID           int       `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	PasswordHash string     `json:"-"`
+	Role         string     `json:"role"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	// EmailVerifiedAt is set once the user follows their verification link.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// Portfolio event actions.
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+)
+
+// PortfolioEvent records a symbol being added to or removed from a
+// portfolio. ActorID is who made the change; it is zero for changes made
+// before portfolios could be shared.
+type PortfolioEvent struct {
+	Symbol  string    `json:"symbol"`
+	Action  string    `json:"action"`
+	ActorID int       `json:"actor_id,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Portfolio roles. Every user owns one portfolio and can share it with
+// others as viewers, who can read it, or editors, who can also change it.
+const (
+	PortfolioOwner  = "owner"
+	PortfolioEditor = "editor"
+	PortfolioViewer = "viewer"
+)
+
+// PortfolioMember is a user a portfolio is shared with.
+type PortfolioMember struct {
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SharedPortfolio is a portfolio another user has shared.
+type SharedPortfolio struct {
+	OwnerID    int       `json:"owner_id"`
+	OwnerEmail string    `json:"owner_email"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type Repository interface {
-	CreateUser(ctx context.Context, email, passwordHash string) (*User, error)
+	CreateUser(ctx context.Context, email, passwordHash, role string) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
-	AddToPortfolio(ctx context.Context, userID int, symbol string) error
-	GetPortfolio(ctx context.Context, userID int) ([]string, error)
-	RemoveFromPortfolio(ctx context.Context, userID int, symbol string) error
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	ListUsers(ctx context.Context, limit, offset int) ([]User, error)
+	SetRole(ctx context.Context, id int, role string) (*User, error)
+	// SetDisabled disables or re-enables a user.
+	SetDisabled(ctx context.Context, id int, disabled bool) (*User, error)
+	// PromoteAdmins makes the users with the given lower-cased emails
+	// admins, matching emails case-insensitively.
+	PromoteAdmins(ctx context.Context, emails []string) error
+	// AddToPortfolio, GetPortfolio and RemoveFromPortfolio act for actorID
+	// on ownerID's portfolio, returning ErrPortfolioNotFound unless actorID
+	// is the owner or a member, and ErrPortfolioReadOnly for viewers'
+	// changes. Changes report false if the symbol was already held or
+	// missing.
+	AddToPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error)
+	GetPortfolio(ctx context.Context, actorID, ownerID int) ([]string, error)
+	RemoveFromPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error)
+	PortfolioHistory(ctx context.Context, userID int) ([]PortfolioEvent, error)
+	// PortfolioRole returns actorID's role on ownerID's portfolio, or
+	// ErrPortfolioNotFound if they have none.
+	PortfolioRole(ctx context.Context, actorID, ownerID int) (string, error)
+	SetPortfolioMember(ctx context.Context, ownerID, memberID int, role string) (*PortfolioMember, error)
+	RemovePortfolioMember(ctx context.Context, ownerID, memberID int) error
+	PortfolioMembers(ctx context.Context, ownerID int) ([]PortfolioMember, error)
+	SharedPortfolios(ctx context.Context, memberID int) ([]SharedPortfolio, error)
+	MarkEmailVerified(ctx context.Context, id int, at time.Time) (*User, error)
+	CreateToken(ctx context.Context, t *Token) error
+	// VerifyEmail uses up a verify-email token and marks its user verified.
+	VerifyEmail(ctx context.Context, tokenHash []byte, now time.Time) (*User, error)
+	// ResetPassword uses up a password-reset token, sets the new hash and
+	// discards the user's other reset tokens.
+	ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string, now time.Time) (*User, error)
 }
@@ -0,0 +1,437 @@
+package users_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/auth"
+	"github.com/jamesfulreader/gostocks/internal/users"
+)
+
+// memRepo is an in-memory users.Repository covering accounts, tokens and
+// shared portfolios.
+type memRepo struct {
+	users      []*users.User
+	tokens     []*memToken
+	portfolios map[int][]string
+	members    map[[2]int]string // {owner, member} -> role
+	events     map[int][]users.PortfolioEvent
+}
+
+type memToken struct {
+	users.Token
+	used bool
+}
+
+func (m *memRepo) CreateUser(ctx context.Context, email, passwordHash, role string) (*users.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return nil, users.ErrEmailTaken
+		}
+	}
+	u := &users.User{ID: len(m.users) + 1, Email: email, PasswordHash: passwordHash, Role: role}
+	m.users = append(m.users, u)
+	return u, nil
+}
+
+func (m *memRepo) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, users.ErrUserNotFound
+}
+
+func (m *memRepo) GetUserByID(ctx context.Context, id int) (*users.User, error) {
+	if id < 1 || id > len(m.users) {
+		return nil, users.ErrUserNotFound
+	}
+	return m.users[id-1], nil
+}
+
+func (m *memRepo) ListUsers(ctx context.Context, limit, offset int) ([]users.User, error) {
+	return nil, nil
+}
+func (m *memRepo) SetRole(ctx context.Context, id int, role string) (*users.User, error) {
+	return nil, users.ErrUserNotFound
+}
+func (m *memRepo) SetDisabled(ctx context.Context, id int, disabled bool) (*users.User, error) {
+	return nil, users.ErrUserNotFound
+}
+func (m *memRepo) PromoteAdmins(ctx context.Context, emails []string) error {
+	for _, u := range m.users {
+		if slices.Contains(emails, strings.ToLower(u.Email)) {
+			u.Role = auth.RoleAdmin
+		}
+	}
+	return nil
+}
+func (m *memRepo) PortfolioRole(ctx context.Context, actorID, ownerID int) (string, error) {
+	if actorID == ownerID {
+		return users.PortfolioOwner, nil
+	}
+	role, ok := m.members[[2]int{ownerID, actorID}]
+	if !ok {
+		return "", users.ErrPortfolioNotFound
+	}
+	return role, nil
+}
+
+func (m *memRepo) changePortfolio(actorID, ownerID int, symbol, action string, change func() bool) (bool, error) {
+	role, err := m.PortfolioRole(context.Background(), actorID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if role == users.PortfolioViewer {
+		return false, users.ErrPortfolioReadOnly
+	}
+	if !change() {
+		return false, nil
+	}
+	if m.events == nil {
+		m.events = map[int][]users.PortfolioEvent{}
+	}
+	m.events[ownerID] = append(m.events[ownerID], users.PortfolioEvent{Symbol: symbol, Action: action, ActorID: actorID})
+	return true, nil
+}
+
+func (m *memRepo) AddToPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	return m.changePortfolio(actorID, ownerID, symbol, "added", func() bool {
+		if m.portfolios == nil {
+			m.portfolios = map[int][]string{}
+		}
+		if slices.Contains(m.portfolios[ownerID], symbol) {
+			return false
+		}
+		m.portfolios[ownerID] = append(m.portfolios[ownerID], symbol)
+		return true
+	})
+}
+
+func (m *memRepo) GetPortfolio(ctx context.Context, actorID, ownerID int) ([]string, error) {
+	if _, err := m.PortfolioRole(ctx, actorID, ownerID); err != nil {
+		return nil, err
+	}
+	return m.portfolios[ownerID], nil
+}
+
+func (m *memRepo) RemoveFromPortfolio(ctx context.Context, actorID, ownerID int, symbol string) (bool, error) {
+	return m.changePortfolio(actorID, ownerID, symbol, "removed", func() bool {
+		before := len(m.portfolios[ownerID])
+		m.portfolios[ownerID] = slices.DeleteFunc(m.portfolios[ownerID], func(s string) bool { return s == symbol })
+		return len(m.portfolios[ownerID]) < before
+	})
+}
+
+func (m *memRepo) SetPortfolioMember(ctx context.Context, ownerID, memberID int, role string) (*users.PortfolioMember, error) {
+	if m.members == nil {
+		m.members = map[[2]int]string{}
+	}
+	m.members[[2]int{ownerID, memberID}] = role
+	return &users.PortfolioMember{UserID: memberID, Email: m.users[memberID-1].Email, Role: role}, nil
+}
+
+func (m *memRepo) RemovePortfolioMember(ctx context.Context, ownerID, memberID int) error {
+	key := [2]int{ownerID, memberID}
+	if _, ok := m.members[key]; !ok {
+		return users.ErrMemberNotFound
+	}
+	delete(m.members, key)
+	return nil
+}
+
+func (m *memRepo) PortfolioMembers(ctx context.Context, ownerID int) ([]users.PortfolioMember, error) {
+	return nil, nil
+}
+
+func (m *memRepo) SharedPortfolios(ctx context.Context, memberID int) ([]users.SharedPortfolio, error) {
+	return nil, nil
+}
+
+func (m *memRepo) PortfolioHistory(ctx context.Context, userID int) ([]users.PortfolioEvent, error) {
+	return m.events[userID], nil
+}
+
+func (m *memRepo) MarkEmailVerified(ctx context.Context, id int, at time.Time) (*users.User, error) {
+	u, err := m.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	u.EmailVerifiedAt = &at
+	return u, nil
+}
+
+func (m *memRepo) CreateToken(ctx context.Context, t *users.Token) error {
+	m.tokens = append(m.tokens, &memToken{Token: *t})
+	return nil
+}
+
+func (m *memRepo) useToken(hash []byte, purpose string, now time.Time) (*users.User, error) {
+	for _, t := range m.tokens {
+		if bytes.Equal(t.Hash, hash) && t.Purpose == purpose && !t.used && t.ExpiresAt.After(now) {
+			t.used = true
+			return m.users[t.UserID-1], nil
+		}
+	}
+	return nil, users.ErrInvalidToken
+}
+
+func (m *memRepo) VerifyEmail(ctx context.Context, tokenHash []byte, now time.Time) (*users.User, error) {
+	u, err := m.useToken(tokenHash, users.PurposeVerifyEmail, now)
+	if err != nil {
+		return nil, err
+	}
+	u.EmailVerifiedAt = &now
+	return u, nil
+}
+
+func (m *memRepo) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string, now time.Time) (*users.User, error) {
+	u, err := m.useToken(tokenHash, users.PurposePasswordReset, now)
+	if err != nil {
+		return nil, err
+	}
+	u.PasswordHash = passwordHash
+	for _, t := range m.tokens {
+		if t.UserID == u.ID && t.Purpose == users.PurposePasswordReset {
+			t.used = true
+		}
+	}
+	return u, nil
+}
+
+func TestValidateEmail(t *testing.T) {
+	for _, email := range []string{"a@example.com", "first.last+tag@mail.example.co.uk"} {
+		if err := users.ValidateEmail(email); err != nil {
+			t.Errorf("ValidateEmail(%q) = %v", email, err)
+		}
+	}
+	for _, email := range []string{"", "plain", "a@", "@example.com", "a@localhost", "a@example.", "Bob <a@example.com>", "a b@example.com"} {
+		if err := users.ValidateEmail(email); !errors.Is(err, users.ErrInvalidEmail) {
+			t.Errorf("ValidateEmail(%q) = %v, want ErrInvalidEmail", email, err)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	p := users.PasswordPolicy{MinLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Short1!", false},
+		{"longenough1!", false},
+		{"Longenough!!", false},
+		{"Longenough11", false},
+		{"Longenough1!", true},
+		{"Ünïcödé-pässwörd-9", true},
+		{string(bytes.Repeat([]byte("Aa1!"), 20)), false},
+	}
+	for _, tt := range tests {
+		err := p.Validate(tt.password)
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%q) = %v", tt.password, err)
+		}
+		if !tt.ok && !errors.Is(err, users.ErrWeakPassword) {
+			t.Errorf("Validate(%q) = %v, want ErrWeakPassword", tt.password, err)
+		}
+	}
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	svc := users.NewService(&memRepo{})
+
+	if _, err := svc.Register(ctx, "not-an-email", "password123"); !errors.Is(err, users.ErrInvalidEmail) {
+		t.Errorf("Register bad email = %v", err)
+	}
+	if _, err := svc.Register(ctx, "a@example.com", "short"); !errors.Is(err, users.ErrWeakPassword) {
+		t.Errorf("Register short password = %v", err)
+	}
+	user, err := svc.Register(ctx, " Alice@Example.com ", "password123")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if user.Email != "alice@example.com" || user.EmailVerifiedAt != nil {
+		t.Errorf("registered %+v, want normalized unverified email", user)
+	}
+	if _, err := svc.Register(ctx, "alice@example.com", "password456"); !errors.Is(err, users.ErrEmailTaken) {
+		t.Errorf("duplicate Register = %v, want ErrEmailTaken", err)
+	}
+
+	if _, err := svc.Login(ctx, "ALICE@example.com", "password123"); err != nil {
+		t.Errorf("Login: %v", err)
+	}
+	// Unknown emails and wrong passwords look the same
+	if _, err := svc.Login(ctx, "alice@example.com", "wrong"); !errors.Is(err, users.ErrInvalidCredentials) {
+		t.Errorf("Login wrong password = %v", err)
+	}
+	if _, err := svc.Login(ctx, "nobody@example.com", "password123"); !errors.Is(err, users.ErrInvalidCredentials) {
+		t.Errorf("Login unknown email = %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	svc := users.NewService(&memRepo{})
+	user, _ := svc.Register(ctx, "a@example.com", "password123")
+
+	issued, err := svc.RequestVerification(ctx, user.ID)
+	if err != nil || issued == nil {
+		t.Fatalf("RequestVerification = %v, %v", issued, err)
+	}
+	if _, err := svc.VerifyEmail(ctx, "bogus"); !errors.Is(err, users.ErrInvalidToken) {
+		t.Errorf("VerifyEmail bogus = %v", err)
+	}
+	verified, err := svc.VerifyEmail(ctx, issued.Token)
+	if err != nil || verified.EmailVerifiedAt == nil {
+		t.Fatalf("VerifyEmail = %+v, %v", verified, err)
+	}
+	if _, err := svc.VerifyEmail(ctx, issued.Token); !errors.Is(err, users.ErrInvalidToken) {
+		t.Errorf("second VerifyEmail = %v, want ErrInvalidToken", err)
+	}
+	if again, err := svc.RequestVerification(ctx, user.ID); again != nil || err != nil {
+		t.Errorf("RequestVerification after verifying = %v, %v; want nil, nil", again, err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	svc := users.NewService(&memRepo{})
+	svc.Register(ctx, "a@example.com", "password123")
+
+	if issued, err := svc.RequestPasswordReset(ctx, "nobody@example.com"); issued != nil || err != nil {
+		t.Errorf("reset for unknown email = %v, %v; want nil, nil", issued, err)
+	}
+
+	first, _ := svc.RequestPasswordReset(ctx, "a@example.com")
+	second, err := svc.RequestPasswordReset(ctx, "A@example.com")
+	if err != nil || second == nil {
+		t.Fatalf("RequestPasswordReset = %v, %v", second, err)
+	}
+
+	if _, err := svc.ResetPassword(ctx, second.Token, "short"); !errors.Is(err, users.ErrWeakPassword) {
+		t.Errorf("ResetPassword weak = %v", err)
+	}
+	if _, err := svc.ResetPassword(ctx, second.Token, "new-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := svc.Login(ctx, "a@example.com", "new-password"); err != nil {
+		t.Errorf("Login with new password: %v", err)
+	}
+
+	// The used token and the other outstanding one are both spent
+	for _, tok := range []string{second.Token, first.Token} {
+		if _, err := svc.ResetPassword(ctx, tok, "another-password"); !errors.Is(err, users.ErrInvalidToken) {
+			t.Errorf("reusing reset token = %v, want ErrInvalidToken", err)
+		}
+	}
+
+	svc.ResetTokenTTL = -time.Minute
+	expired, _ := svc.RequestPasswordReset(ctx, "a@example.com")
+	if _, err := svc.ResetPassword(ctx, expired.Token, "another-password"); !errors.Is(err, users.ErrInvalidToken) {
+		t.Errorf("expired token = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestEnsureVerifiedUser(t *testing.T) {
+	ctx := context.Background()
+	repo := &memRepo{}
+	svc := users.NewService(repo)
+	local, _ := svc.Register(ctx, "a@example.com", "password123")
+
+	// Whoever registered the address may not own it
+	if _, err := svc.EnsureVerifiedUser(ctx, "a@example.com"); !errors.Is(err, users.ErrUnverifiedAccount) {
+		t.Fatalf("EnsureVerifiedUser unverified = %v, want ErrUnverifiedAccount", err)
+	}
+
+	repo.MarkEmailVerified(ctx, local.ID, time.Now())
+	linked, err := svc.EnsureVerifiedUser(ctx, "A@Example.com")
+	if err != nil || linked.ID != local.ID || linked.EmailVerifiedAt == nil {
+		t.Fatalf("EnsureVerifiedUser existing = %+v, %v", linked, err)
+	}
+
+	created, err := svc.EnsureVerifiedUser(ctx, "new@example.com")
+	if err != nil || created.ID == local.ID || created.EmailVerifiedAt == nil {
+		t.Fatalf("EnsureVerifiedUser new = %+v, %v", created, err)
+	}
+	// Users created this way have no password to log in with
+	if _, err := svc.Login(ctx, "new@example.com", ""); !errors.Is(err, users.ErrInvalidCredentials) {
+		t.Errorf("Login without password = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestPromoteAdminsIgnoresCase(t *testing.T) {
+	ctx := context.Background()
+	repo := &memRepo{}
+	svc := users.NewService(repo)
+	svc.AdminEmails = []string{"Boss@Example.com"}
+	// Stored before emails were normalized
+	boss, _ := repo.CreateUser(ctx, "boss@EXAMPLE.com", "", auth.RoleUser)
+
+	if err := svc.PromoteAdmins(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if boss.Role != auth.RoleAdmin {
+		t.Errorf("role = %q, want %q", boss.Role, auth.RoleAdmin)
+	}
+}
+
+func TestSharePortfolio(t *testing.T) {
+	ctx := context.Background()
+	svc := users.NewService(&memRepo{})
+	owner, _ := svc.Register(ctx, "owner@example.com", "Password1")
+	editor, _ := svc.Register(ctx, "editor@example.com", "Password1")
+	viewer, _ := svc.Register(ctx, "viewer@example.com", "Password1")
+	stranger, _ := svc.Register(ctx, "stranger@example.com", "Password1")
+
+	if _, err := svc.SharePortfolio(ctx, owner.ID, "owner@example.com", users.PortfolioViewer); !errors.Is(err, users.ErrInvalidMember) {
+		t.Fatalf("sharing with self: got %v, want ErrInvalidMember", err)
+	}
+	if _, err := svc.SharePortfolio(ctx, owner.ID, "editor@example.com", users.PortfolioOwner); !errors.Is(err, users.ErrInvalidMember) {
+		t.Fatalf("sharing as owner: got %v, want ErrInvalidMember", err)
+	}
+	if _, err := svc.SharePortfolio(ctx, owner.ID, "Editor@Example.com", users.PortfolioEditor); err != nil {
+		t.Fatalf("SharePortfolio(editor): %v", err)
+	}
+	if _, err := svc.SharePortfolio(ctx, owner.ID, "viewer@example.com", users.PortfolioViewer); err != nil {
+		t.Fatalf("SharePortfolio(viewer): %v", err)
+	}
+
+	if added, err := svc.AddToPortfolio(ctx, editor.ID, owner.ID, "AAPL"); err != nil || !added {
+		t.Fatalf("editor AddToPortfolio = %v, %v", added, err)
+	}
+	if added, err := svc.AddToPortfolio(ctx, owner.ID, owner.ID, "AAPL"); err != nil || added {
+		t.Fatalf("repeated AddToPortfolio = %v, %v; want no change", added, err)
+	}
+	if _, err := svc.AddToPortfolio(ctx, viewer.ID, owner.ID, "TSLA"); !errors.Is(err, users.ErrPortfolioReadOnly) {
+		t.Fatalf("viewer AddToPortfolio: got %v, want ErrPortfolioReadOnly", err)
+	}
+	if _, err := svc.ViewPortfolio(ctx, stranger.ID, owner.ID); !errors.Is(err, users.ErrPortfolioNotFound) {
+		t.Fatalf("stranger ViewPortfolio: got %v, want ErrPortfolioNotFound", err)
+	}
+	portfolio, err := svc.ViewPortfolio(ctx, viewer.ID, owner.ID)
+	if err != nil || len(portfolio) != 1 || portfolio[0] != "AAPL" {
+		t.Fatalf("viewer ViewPortfolio = %v, %v; want [AAPL]", portfolio, err)
+	}
+
+	events, err := svc.ViewPortfolioHistory(ctx, owner.ID, owner.ID)
+	if err != nil || len(events) != 1 || events[0].ActorID != editor.ID {
+		t.Fatalf("history = %+v, %v; want one change by the editor", events, err)
+	}
+
+	if err := svc.UnsharePortfolio(ctx, owner.ID, viewer.ID); err != nil {
+		t.Fatalf("UnsharePortfolio: %v", err)
+	}
+	if _, err := svc.ViewPortfolio(ctx, viewer.ID, owner.ID); !errors.Is(err, users.ErrPortfolioNotFound) {
+		t.Fatalf("after unshare: got %v, want ErrPortfolioNotFound", err)
+	}
+	if err := svc.UnsharePortfolio(ctx, owner.ID, viewer.ID); !errors.Is(err, users.ErrMemberNotFound) {
+		t.Fatalf("second unshare: got %v, want ErrMemberNotFound", err)
+	}
+}
@@ -0,0 +1,253 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Headers set on every delivery. Receivers verify SignatureHeader with
+// Verify using the secret they were given at registration.
+const (
+	EventHeader     = "X-Gostocks-Event"
+	DeliveryHeader  = "X-Gostocks-Delivery"
+	TimestampHeader = "X-Gostocks-Timestamp"
+	SignatureHeader = "X-Gostocks-Signature"
+)
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret,
+// prefixed with "sha256=". Including the timestamp lets receivers reject
+// replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign in constant time.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher sends queued deliveries, retrying failures with exponential
+// backoff until MaxAttempts is reached and the delivery is dead-lettered.
+type Dispatcher struct {
+	repo   Repository
+	client *http.Client
+
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// errBlockedAddress is returned for targets that resolve to addresses
+// webhooks may not reach.
+var errBlockedAddress = errors.New("address is not public")
+
+// blockedPrefixes are the non-public ranges net.IP's predicates miss.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddr reports whether addr is a public unicast address.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialPublicOnly is a net.Dialer Control hook. It runs on the resolved
+// address of every connection, so hostnames that resolve, or are rebound,
+// to internal addresses are refused too.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddr(ap.Addr()) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, address)
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. Users choose the
+// URLs, so it only connects to public addresses, never goes through a
+// proxy, and doesn't follow redirects.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NewDispatcher sends deliveries with client, or if it is nil with a
+// client that can only reach public addresses.
+func NewDispatcher(repo Repository, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = newClient()
+	}
+	return &Dispatcher{
+		repo:         repo,
+		client:       client,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		PollInterval: 2 * time.Second,
+		BatchSize:    20,
+	}
+}
+
+// Run delivers due webhooks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and attempts one batch of due deliveries.
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	// The lease must outlast a full HTTP attempt so nobody else picks the
+	// delivery up while it is in flight. The batch is sent concurrently,
+	// so that is also how long the whole batch takes.
+	lease := d.client.Timeout + 30*time.Second
+	due, err := d.repo.ClaimDue(ctx, d.BatchSize, lease)
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.attempt(ctx, &due[i])
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	now := time.Now()
+	delivery.Attempts++
+
+	code, err := d.send(ctx, delivery, now)
+	delivery.ResponseCode = code
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = StatusDead
+		delivery.LastError = deliveryError(err)
+		log.Printf("webhooks: delivery %d dead after %d attempts: %v", delivery.ID, delivery.Attempts, err)
+	default:
+		delivery.Status = StatusRetrying
+		delivery.LastError = deliveryError(err)
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	if err := d.repo.UpdateDelivery(ctx, delivery); errors.Is(err, ErrLeaseLost) {
+		log.Printf("webhooks: delivery %d: %v; the result of this attempt was dropped", delivery.ID, err)
+	} else if err != nil {
+		log.Printf("webhooks: %v", err)
+	}
+}
+
+// errStatus is a receiver answering with a non-2xx status.
+var errStatus = errors.New("receiver returned an error status")
+
+// deliveryError describes a failed attempt for the delivery log that users
+// read. Raw errors stay in the server log: they would tell users how hosts
+// they can't otherwise see respond.
+func deliveryError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errStatus):
+		return err.Error()
+	case errors.Is(err, errBlockedAddress):
+		return "target address is not allowed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	default:
+		return "connection failed"
+	}
+}
+
+// backoff doubles BaseBackoff for every failed attempt, up to MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.MaxBackoff)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gostocks-webhooks/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, ts, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w %d", errStatus, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,231 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jamesfulreader/gostocks/internal/webhooks"
+)
+
+// memRepo is an in-memory webhooks.Repository.
+type memRepo struct {
+	mu         sync.Mutex
+	hooks      []webhooks.Webhook
+	deliveries []webhooks.Delivery
+	claims     int
+}
+
+func (m *memRepo) CreateWebhook(ctx context.Context, hook *webhooks.Webhook) (*webhooks.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := *hook
+	h.ID = len(m.hooks) + 1
+	h.Active = true
+	m.hooks = append(m.hooks, h)
+	return &h, nil
+}
+func (m *memRepo) ListWebhooks(ctx context.Context, userID int) ([]webhooks.Webhook, error) {
+	return m.hooks, nil
+}
+func (m *memRepo) DeleteWebhook(ctx context.Context, userID, webhookID int) error { return nil }
+func (m *memRepo) ActiveWebhooks(ctx context.Context, userID int, event string) ([]webhooks.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []webhooks.Webhook
+	for _, h := range m.hooks {
+		for _, e := range h.Events {
+			if h.UserID == userID && e == event {
+				out = append(out, h)
+			}
+		}
+	}
+	return out, nil
+}
+func (m *memRepo) EnqueueDelivery(ctx context.Context, d *webhooks.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = len(m.deliveries) + 1
+	d.Status = webhooks.StatusPending
+	d.NextAttemptAt = time.Now()
+	m.deliveries = append(m.deliveries, *d)
+	return nil
+}
+func (m *memRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]webhooks.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []webhooks.Delivery
+	m.claims++
+	for i, d := range m.deliveries {
+		if (d.Status == webhooks.StatusPending || d.Status == webhooks.StatusRetrying) && !d.NextAttemptAt.After(time.Now()) {
+			hook := m.hooks[d.WebhookID-1]
+			d.URL, d.Secret = hook.URL, hook.Secret
+			d.ClaimToken = strconv.Itoa(m.claims)
+			m.deliveries[i].NextAttemptAt = time.Now().Add(lease)
+			m.deliveries[i].ClaimToken = d.ClaimToken
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+func (m *memRepo) UpdateDelivery(ctx context.Context, d *webhooks.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deliveries[d.ID-1].ClaimToken != d.ClaimToken {
+		return webhooks.ErrLeaseLost
+	}
+	m.deliveries[d.ID-1] = *d
+	return nil
+}
+func (m *memRepo) ListDeliveries(ctx context.Context, userID, webhookID int, status string, limit int) ([]webhooks.Delivery, error) {
+	return m.deliveries, nil
+}
+func (m *memRepo) RetryDelivery(ctx context.Context, userID, deliveryID int) error { return nil }
+
+func TestDispatcherSignsPayloads(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	repo := &memRepo{}
+	svc := webhooks.NewService(repo)
+
+	var secret string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(webhooks.TimestampHeader), 10, 64)
+		if !webhooks.Verify(secret, ts, body, r.Header.Get(webhooks.SignatureHeader)) {
+			t.Errorf("bad signature %q", r.Header.Get(webhooks.SignatureHeader))
+		}
+		var env struct {
+			Event string            `json:"event"`
+			Data  map[string]string `json:"data"`
+		}
+		json.Unmarshal(body, &env)
+		mu.Lock()
+		received = append(received, env.Event+":"+env.Data["symbol"])
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	hook, err := svc.Register(ctx, 1, receiver.URL, []string{webhooks.EventPortfolioAdded})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	secret = hook.Secret
+
+	svc.Publish(ctx, 1, webhooks.EventPortfolioAdded, map[string]string{"symbol": "AAPL"})
+	svc.Publish(ctx, 1, webhooks.EventPortfolioRemoved, map[string]string{"symbol": "AAPL"}) // not subscribed
+	svc.Publish(ctx, 2, webhooks.EventPortfolioAdded, map[string]string{"symbol": "MSFT"})   // other user
+
+	webhooks.NewDispatcher(repo, receiver.Client()).RunOnce(ctx)
+
+	if len(received) != 1 || received[0] != "portfolio.added:AAPL" {
+		t.Errorf("unexpected deliveries %v", received)
+	}
+	if repo.deliveries[0].Status != webhooks.StatusDelivered || repo.deliveries[0].DeliveredAt == nil {
+		t.Errorf("expected delivery to be marked delivered, got %+v", repo.deliveries[0])
+	}
+}
+
+func TestDispatcherRetriesThenDeadLetters(t *testing.T) {
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	repo := &memRepo{}
+	svc := webhooks.NewService(repo)
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, 1, receiver.URL, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svc.Publish(ctx, 1, webhooks.EventAlertFired, map[string]string{"symbol": "TSLA"})
+
+	d := webhooks.NewDispatcher(repo, receiver.Client())
+	d.MaxAttempts = 3
+	d.BaseBackoff = 0
+
+	d.RunOnce(ctx)
+	if got := repo.deliveries[0]; got.Status != webhooks.StatusRetrying || got.Attempts != 1 || got.ResponseCode != 503 {
+		t.Fatalf("expected retrying after first failure, got %+v", got)
+	}
+	d.RunOnce(ctx)
+	d.RunOnce(ctx)
+	if got := repo.deliveries[0]; got.Status != webhooks.StatusDead || got.Attempts != 3 {
+		t.Fatalf("expected dead letter after 3 attempts, got %+v", got)
+	}
+	d.RunOnce(ctx)
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, receiver saw %d", calls)
+	}
+}
+
+func TestRegisterRejectsBadInput(t *testing.T) {
+	svc := webhooks.NewService(&memRepo{})
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, 1, "ftp://example.com", nil); err == nil {
+		t.Error("expected error for non-http URL")
+	}
+	if _, err := svc.Register(ctx, 1, "https://example.com/hook", []string{"price.teleported"}); err == nil {
+		t.Error("expected error for unknown event")
+	}
+}
+
+func TestDispatcherRefusesInternalAddresses(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	repo := &memRepo{}
+	svc := webhooks.NewService(repo)
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, 1, receiver.URL, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svc.Publish(ctx, 1, webhooks.EventAlertFired, map[string]string{"symbol": "TSLA"})
+
+	// The default client must not reach loopback, where the receiver is
+	webhooks.NewDispatcher(repo, nil).RunOnce(ctx)
+	if called {
+		t.Error("delivery reached a loopback address")
+	}
+	if got := repo.deliveries[0]; got.Status != webhooks.StatusRetrying || got.LastError != "target address is not allowed" {
+		t.Errorf("expected a blocked attempt, got %+v", got)
+	}
+}
+
+func TestDispatcherDropsResultsAfterLosingLease(t *testing.T) {
+	repo := &memRepo{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Another replica claims the delivery while this attempt is in flight
+		repo.mu.Lock()
+		repo.deliveries[0].NextAttemptAt = time.Now()
+		repo.mu.Unlock()
+		repo.ClaimDue(context.Background(), 1, time.Minute)
+	}))
+	defer receiver.Close()
+
+	svc := webhooks.NewService(repo)
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, 1, receiver.URL, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svc.Publish(ctx, 1, webhooks.EventAlertFired, map[string]string{"symbol": "TSLA"})
+
+	webhooks.NewDispatcher(repo, receiver.Client()).RunOnce(ctx)
+	if got := repo.deliveries[0]; got.Status != webhooks.StatusPending || got.Attempts != 0 {
+		t.Errorf("stale attempt overwrote the new claim: %+v", got)
+	}
+}
@@ -0,0 +1,178 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func collectWebhooks(rows pgx.Rows) ([]Webhook, error) {
+	defer rows.Close()
+	var hooks []Webhook
+	for rows.Next() {
+		var h Webhook
+		if err := rows.Scan(&h.ID, &h.UserID, &h.URL, &h.Events, &h.Active, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *PostgresRepository) CreateWebhook(ctx context.Context, hook *Webhook) (*Webhook, error) {
+	created := *hook
+	err := r.db.QueryRow(ctx,
+		"INSERT INTO webhooks (user_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING id, active, created_at",
+		hook.UserID, hook.URL, hook.Secret, hook.Events).Scan(&created.ID, &created.Active, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &created, nil
+}
+
+func (r *PostgresRepository) ListWebhooks(ctx context.Context, userID int) ([]Webhook, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT id, user_id, url, events, active, created_at FROM webhooks WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return collectWebhooks(rows)
+}
+
+func (r *PostgresRepository) DeleteWebhook(ctx context.Context, userID, webhookID int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM webhooks WHERE id = $1 AND user_id = $2", webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ActiveWebhooks(ctx context.Context, userID int, event string) ([]Webhook, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT id, user_id, url, events, active, created_at FROM webhooks WHERE user_id = $1 AND active AND $2 = ANY(events)",
+		userID, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	return collectWebhooks(rows)
+}
+
+func (r *PostgresRepository) EnqueueDelivery(ctx context.Context, d *Delivery) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, user_id, event, payload, status)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, next_attempt_at, created_at`,
+		d.WebhookID, d.UserID, d.Event, d.Payload, StatusPending).Scan(&d.ID, &d.NextAttemptAt, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	d.Status = StatusPending
+	return nil
+}
+
+func (r *PostgresRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status IN ('pending', 'retrying') AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second', claim_token = $3
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.webhook_id, d.user_id, d.event, d.payload, d.status, d.attempts, w.url, w.secret, d.claim_token, d.created_at
+	`
+	rows, err := r.db.Query(ctx, query, limit, lease.Seconds(), rand.Text())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.UserID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.URL, &d.Secret, &d.ClaimToken, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateDelivery(ctx context.Context, d *Delivery) error {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = $3, last_error = $4, response_code = $5, next_attempt_at = $6, delivered_at = $7
+		 WHERE id = $1 AND claim_token = $8`,
+		d.ID, d.Status, d.Attempts, d.LastError, d.ResponseCode, d.NextAttemptAt, d.DeliveredAt, d.ClaimToken)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ListDeliveries(ctx context.Context, userID, webhookID int, status string, limit int) ([]Delivery, error) {
+	query := `
+		SELECT id, webhook_id, user_id, event, payload, status, attempts, last_error, response_code, next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE user_id = @user_id
+		  AND (@webhook_id = 0 OR webhook_id = @webhook_id)
+		  AND (@status = '' OR status = @status)
+		ORDER BY created_at DESC
+		LIMIT @limit
+	`
+	rows, err := r.db.Query(ctx, query, pgx.NamedArgs{
+		"user_id":    userID,
+		"webhook_id": webhookID,
+		"status":     status,
+		"limit":      limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.UserID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.LastError, &d.ResponseCode, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *PostgresRepository) RetryDelivery(ctx context.Context, userID, deliveryID int) error {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status = 'dead'`,
+		deliveryID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("dead delivery not found")
+	// ErrLeaseLost is returned when updating a delivery whose lease ran
+	// out and was claimed by someone else.
+	ErrLeaseLost = errors.New("webhook delivery lease lost")
+)
+
+// envelope is the JSON body POSTed to receivers.
+type envelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Register creates a webhook for userID. The returned webhook carries the
+// signing secret, which is not shown again.
+func (s *Service) Register(ctx context.Context, userID int, rawURL string, events []string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+	if len(events) == 0 {
+		events = Events
+	}
+	for _, e := range events {
+		if !slices.Contains(Events, e) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateWebhook(ctx, &Webhook{
+		UserID: userID,
+		URL:    u.String(),
+		Events: events,
+		Secret: hex.EncodeToString(secret),
+	})
+}
+
+func (s *Service) List(ctx context.Context, userID int) ([]Webhook, error) {
+	return s.repo.ListWebhooks(ctx, userID)
+}
+
+func (s *Service) Delete(ctx context.Context, userID, webhookID int) error {
+	return s.repo.DeleteWebhook(ctx, userID, webhookID)
+}
+
+// Deliveries returns userID's delivery log, newest first. webhookID and
+// status are optional filters.
+func (s *Service) Deliveries(ctx context.Context, userID, webhookID int, status string, limit int) ([]Delivery, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.ListDeliveries(ctx, userID, webhookID, status, limit)
+}
+
+// Retry re-queues a dead-lettered delivery.
+func (s *Service) Retry(ctx context.Context, userID, deliveryID int) error {
+	return s.repo.RetryDelivery(ctx, userID, deliveryID)
+}
+
+// Publish queues event for every active webhook of userID that subscribes
+// to it. Sending happens in the Dispatcher.
+func (s *Service) Publish(ctx context.Context, userID int, event string, data any) error {
+	hooks, err := s.repo.ActiveWebhooks(ctx, userID, event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(envelope{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		d := &Delivery{WebhookID: hook.ID, UserID: userID, Event: event, Payload: payload}
+		if err := s.repo.EnqueueDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
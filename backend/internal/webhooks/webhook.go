@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event names a webhook can subscribe to.
+const (
+	EventAlertFired       = "alert.fired"
+	EventPortfolioAdded   = "portfolio.added"
+	EventPortfolioRemoved = "portfolio.removed"
+)
+
+// Events lists every supported event.
+var Events = []string{EventAlertFired, EventPortfolioAdded, EventPortfolioRemoved}
+
+// Delivery states. A delivery that exhausts its attempts ends up dead.
+const (
+	StatusPending   = "pending"
+	StatusRetrying  = "retrying"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"` // only returned when the webhook is created
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Delivery struct {
+	ID            int             `json:"id"`
+	WebhookID     int             `json:"webhook_id"`
+	UserID        int             `json:"user_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+
+	// Target, filled in when a delivery is claimed for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+	// ClaimToken identifies the lease; UpdateDelivery only applies while
+	// it is still the delivery's latest claim.
+	ClaimToken string `json:"-"`
+}
+
+type Repository interface {
+	CreateWebhook(ctx context.Context, hook *Webhook) (*Webhook, error)
+	ListWebhooks(ctx context.Context, userID int) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, webhookID int) error
+	// ActiveWebhooks returns userID's active webhooks subscribed to event.
+	ActiveWebhooks(ctx context.Context, userID int, event string) ([]Webhook, error)
+
+	EnqueueDelivery(ctx context.Context, d *Delivery) error
+	// ClaimDue leases up to limit due deliveries so no other replica sends
+	// them until lease has passed.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// UpdateDelivery records an attempt, or returns ErrLeaseLost if the
+	// delivery has been claimed again since d was.
+	UpdateDelivery(ctx context.Context, d *Delivery) error
+	ListDeliveries(ctx context.Context, userID, webhookID int, status string, limit int) ([]Delivery, error)
+	// RetryDelivery puts a dead delivery back in the queue.
+	RetryDelivery(ctx context.Context, userID, deliveryID int) error
+}
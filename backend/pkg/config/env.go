@@ -3,7 +3,9 @@ package config
 import (
 	"bufio"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // LoadEnv reads a .env file in the project root (if present) and sets env vars.
@@ -39,3 +41,45 @@ func GetenvDefault(key, def string) string {
 	}
 	return def
 }
+
+// GetenvInt returns key parsed as an int, or def if unset or malformed.
+func GetenvInt(key string, def int) int {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// GetenvDuration returns key parsed with time.ParseDuration (e.g. "60s"),
+// or def if unset or malformed.
+func GetenvDuration(key string, def time.Duration) time.Duration {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// GetenvFloat returns key parsed as a float64, or def if unset or malformed.
+func GetenvFloat(key string, def float64) float64 {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// GetenvBool returns key parsed with strconv.ParseBool, or def if unset or
+// malformed.
+func GetenvBool(key string, def bool) bool {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
@@ -3,6 +3,10 @@ import { AuthProvider, useAuth } from './context/AuthContext'
 import LoginPage from './pages/LoginPage'
 import RegisterPage from './pages/RegisterPage'
 import DashboardPage from './pages/DashboardPage'
+import VerifyEmailPage from './pages/VerifyEmailPage'
+import ForgotPasswordPage from './pages/ForgotPasswordPage'
+import ResetPasswordPage from './pages/ResetPasswordPage'
+import OIDCCallbackPage from './pages/OIDCCallbackPage'
 import { useState, useEffect } from 'react'
 import { getQuote, getIntraday } from './services/api'
 import type { Quote, Candle } from './types'
@@ -107,6 +111,10 @@ export default function App() {
         <Routes>
           <Route path="/login" element={<LoginPage />} />
           <Route path="/register" element={<RegisterPage />} />
+          <Route path="/verify-email" element={<VerifyEmailPage />} />
+          <Route path="/forgot-password" element={<ForgotPasswordPage />} />
+          <Route path="/reset-password" element={<ResetPasswordPage />} />
+          <Route path="/oidc/callback" element={<OIDCCallbackPage />} />
           <Route path="/" element={<DashboardPage />} />
            <Route path="/quote/:symbol" element={
             <ProtectedRoute>
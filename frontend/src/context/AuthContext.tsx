@@ -1,10 +1,11 @@
 import { createContext, useContext, useState, type ReactNode } from 'react'
 import type { User } from '../types'
+import { logout as apiLogout } from '../services/api'
 
 interface AuthContextType {
   user: User | null
   token: string | null
-  login: (token: string, user: User) => void
+  login: (token: string, refreshToken: string, user: User) => void
   logout: () => void
   isAuthenticated: boolean
 }
@@ -24,17 +25,21 @@ export function AuthProvider({ children }: { children: ReactNode }) {
 
   // Removed useEffect for loading user since it's now synchronous
 
-  const login = (newToken: string, newUser: User) => {
+  const login = (newToken: string, refreshToken: string, newUser: User) => {
     setToken(newToken)
     setUser(newUser)
     localStorage.setItem('token', newToken)
+    localStorage.setItem('refresh_token', refreshToken)
     localStorage.setItem('user', JSON.stringify(newUser))
   }
 
   const logout = () => {
+    // Revoke the session server-side; clear local state regardless
+    apiLogout().catch(() => {})
     setToken(null)
     setUser(null)
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
   }
 
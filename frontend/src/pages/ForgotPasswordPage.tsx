@@ -0,0 +1,44 @@
+import { useState } from 'react'
+import { Link } from 'react-router-dom'
+import { forgotPassword } from '../services/api'
+
+export default function ForgotPasswordPage() {
+  const [email, setEmail] = useState('')
+  const [sent, setSent] = useState(false)
+  const [error, setError] = useState<string | null>(null)
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError(null)
+    try {
+      await forgotPassword(email)
+      setSent(true)
+    } catch (err: any) {
+      setError(err.message || 'Request failed')
+    }
+  }
+
+  return (
+    <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+      <h2>Forgot password</h2>
+      {sent ? (
+        <p>If {email} has an account, we sent it a link to reset the password.</p>
+      ) : (
+        <form onSubmit={handleSubmit} style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
+          <input
+            type="email"
+            placeholder="Email"
+            value={email}
+            onChange={e => setEmail(e.target.value)}
+            required
+          />
+          <button type="submit">Send reset link</button>
+        </form>
+      )}
+      {error && <p style={{ color: 'red' }}>{error}</p>}
+      <p>
+        <Link to="/login">Back to login</Link>
+      </p>
+    </div>
+  )
+}
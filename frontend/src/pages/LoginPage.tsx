@@ -1,51 +1,110 @@
-import { useState } from 'react'
-import { useNavigate, Link } from 'react-router-dom'
+import { useEffect, useState } from 'react'
+import { useNavigate, useLocation, Link } from 'react-router-dom'
 import { useAuth } from '../context/AuthContext'
-import { login as apiLogin } from '../services/api'
+import { login as apiLogin, loginMFA, getOIDCConfig } from '../services/api'
+import type { OIDCConfig } from '../types'
 
 export default function LoginPage() {
   const [email, setEmail] = useState('')
   const [password, setPassword] = useState('')
+  const location = useLocation()
+  // Single sign-on sends 2FA users back here with their challenge
+  const [challenge, setChallenge] = useState<string | null>(location.state?.challenge ?? null)
+  const [oidc, setOIDC] = useState<OIDCConfig | null>(null)
+  const [code, setCode] = useState('')
   const [error, setError] = useState<string | null>(null)
   const navigate = useNavigate()
   const { login } = useAuth()
 
+  useEffect(() => {
+    getOIDCConfig().then(setOIDC).catch(() => setOIDC(null))
+  }, [])
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault()
     setError(null)
     try {
       const resp = await apiLogin(email, password)
-      login(resp.token, resp.user)
+      if ('mfa_required' in resp) {
+        setChallenge(resp.challenge_token)
+        return
+      }
+      login(resp.token, resp.refresh_token, resp.user)
       navigate('/')
     } catch (err: any) {
       setError(err.message || 'Login failed')
     }
   }
 
+  const handleCode = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError(null)
+    try {
+      const resp = await loginMFA(challenge!, code)
+      login(resp.token, resp.refresh_token, resp.user)
+      navigate('/')
+    } catch (err: any) {
+      setError(err.message || 'Login failed')
+    }
+  }
+
+  if (challenge) {
+    return (
+      <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+        <h2>Two-factor authentication</h2>
+        <form onSubmit={handleCode} style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
+          <input
+            placeholder="Authenticator or recovery code"
+            value={code}
+            onChange={e => setCode(e.target.value)}
+            autoComplete="one-time-code"
+            required
+          />
+          <button type="submit">Verify</button>
+        </form>
+        {error && <p style={{ color: 'red' }}>{error}</p>}
+      </div>
+    )
+  }
+
+  const passwordLogin = oidc?.password_login ?? true
+
   return (
     <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
       <h2>Login</h2>
-      <form onSubmit={handleSubmit} style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
-        <input 
-          type="email" 
-          placeholder="Email" 
-          value={email} 
-          onChange={e => setEmail(e.target.value)} 
-          required 
-        />
-        <input 
-          type="password" 
-          placeholder="Password" 
-          value={password} 
-          onChange={e => setPassword(e.target.value)} 
-          required 
-        />
-        <button type="submit">Login</button>
-      </form>
+      {oidc?.enabled && (
+        <p>
+          <a href="/api/oidc/login">Sign in with {oidc.name}</a>
+        </p>
+      )}
+      {passwordLogin && (
+        <>
+          <form onSubmit={handleSubmit} style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
+            <input
+              type="email"
+              placeholder="Email"
+              value={email}
+              onChange={e => setEmail(e.target.value)}
+              required
+            />
+            <input
+              type="password"
+              placeholder="Password"
+              value={password}
+              onChange={e => setPassword(e.target.value)}
+              required
+            />
+            <button type="submit">Login</button>
+          </form>
+          <p>
+            <Link to="/forgot-password">Forgot password?</Link>
+          </p>
+          <p>
+            Don't have an account? <Link to="/register">Register</Link>
+          </p>
+        </>
+      )}
       {error && <p style={{ color: 'red' }}>{error}</p>}
-      <p>
-        Don't have an account? <Link to="/register">Register</Link>
-      </p>
     </div>
   )
 }
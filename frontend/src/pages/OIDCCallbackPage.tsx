@@ -0,0 +1,46 @@
+import { useEffect, useRef, useState } from 'react'
+import { Link, useNavigate, useSearchParams } from 'react-router-dom'
+import { useAuth } from '../context/AuthContext'
+import { oidcCallback } from '../services/api'
+
+export default function OIDCCallbackPage() {
+  const [params] = useSearchParams()
+  const [error, setError] = useState<string | null>(null)
+  const navigate = useNavigate()
+  const { login } = useAuth()
+  // The state is single-use, so don't post it twice under StrictMode
+  const sent = useRef(false)
+
+  useEffect(() => {
+    if (sent.current) return
+    sent.current = true
+    if (params.get('error')) {
+      setError(params.get('error_description') || params.get('error'))
+      return
+    }
+    oidcCallback(params.get('code') ?? '', params.get('state') ?? '')
+      .then(resp => {
+        if ('mfa_required' in resp) {
+          navigate('/login', { state: { challenge: resp.challenge_token } })
+          return
+        }
+        login(resp.token, resp.refresh_token, resp.user)
+        navigate('/')
+      })
+      .catch((err: any) => setError(err.message || 'Sign-in failed'))
+  }, [params, login, navigate])
+
+  return (
+    <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+      <h2>Signing in</h2>
+      {error ? (
+        <>
+          <p style={{ color: 'red' }}>{error}</p>
+          <p><Link to="/login">Back to login</Link></p>
+        </>
+      ) : (
+        <p>Finishing sign-in...</p>
+      )}
+    </div>
+  )
+}
@@ -1,24 +1,34 @@
 import { useState } from 'react'
-import { useNavigate, Link } from 'react-router-dom'
+import { Link } from 'react-router-dom'
 import { register as apiRegister } from '../services/api'
 
 export default function RegisterPage() {
   const [email, setEmail] = useState('')
   const [password, setPassword] = useState('')
   const [error, setError] = useState<string | null>(null)
-  const navigate = useNavigate()
+  const [registered, setRegistered] = useState(false)
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault()
     setError(null)
     try {
       await apiRegister(email, password)
-      navigate('/login')
+      setRegistered(true)
     } catch (err: any) {
       setError(err.message || 'Registration failed')
     }
   }
 
+  if (registered) {
+    return (
+      <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+        <h2>Check your email</h2>
+        <p>We sent a confirmation link to {email}.</p>
+        <p><Link to="/login">Login</Link></p>
+      </div>
+    )
+  }
+
   return (
     <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
       <h2>Register</h2>
@@ -0,0 +1,41 @@
+import { useState } from 'react'
+import { useNavigate, useSearchParams, Link } from 'react-router-dom'
+import { resetPassword } from '../services/api'
+
+export default function ResetPasswordPage() {
+  const [params] = useSearchParams()
+  const [password, setPassword] = useState('')
+  const [error, setError] = useState<string | null>(null)
+  const navigate = useNavigate()
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError(null)
+    try {
+      await resetPassword(params.get('token') ?? '', password)
+      navigate('/login')
+    } catch (err: any) {
+      setError(err.message || 'Reset failed')
+    }
+  }
+
+  return (
+    <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+      <h2>Choose a new password</h2>
+      <form onSubmit={handleSubmit} style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
+        <input
+          type="password"
+          placeholder="New password"
+          value={password}
+          onChange={e => setPassword(e.target.value)}
+          required
+        />
+        <button type="submit">Reset password</button>
+      </form>
+      {error && <p style={{ color: 'red' }}>{error}</p>}
+      <p>
+        <Link to="/login">Back to login</Link>
+      </p>
+    </div>
+  )
+}
@@ -0,0 +1,27 @@
+import { useEffect, useState } from 'react'
+import { Link, useSearchParams } from 'react-router-dom'
+import { verifyEmail } from '../services/api'
+
+export default function VerifyEmailPage() {
+  const [params] = useSearchParams()
+  const [status, setStatus] = useState<'pending' | 'done' | 'failed'>('pending')
+  const [error, setError] = useState<string | null>(null)
+
+  useEffect(() => {
+    verifyEmail(params.get('token') ?? '')
+      .then(() => setStatus('done'))
+      .catch((err: any) => {
+        setError(err.message || 'Verification failed')
+        setStatus('failed')
+      })
+  }, [params])
+
+  return (
+    <div style={{ maxWidth: 400, margin: '40px auto', padding: 20 }}>
+      <h2>Verify email</h2>
+      {status === 'pending' && <p>Verifying...</p>}
+      {status === 'done' && <p>Your email is confirmed. <Link to="/login">Login</Link></p>}
+      {status === 'failed' && <p style={{ color: 'red' }}>{error}</p>}
+    </div>
+  )
+}
@@ -1,4 +1,4 @@
-import type { Quote, Candle, User, AuthResponse } from '../types'
+import type { Quote, Candle, User, AuthResponse, MFAChallenge, OIDCConfig, TokenResponse, PortfolioMember, SharedPortfolio } from '../types'
 
 const json = async <T>(res: Response) => {
   if (!res.ok) throw new Error(await res.text())
@@ -19,17 +19,82 @@ const getHeaders = (): HeadersInit => {
   return { 'Content-Type': 'application/json' }
 }
 
+// Access tokens are short-lived; on a 401 trade the refresh token for a new
+// pair and retry once. Concurrent requests share one refresh.
+let refreshing: Promise<boolean> | null = null
+
+const refreshTokens = async () => {
+  const refreshToken = localStorage.getItem('refresh_token')
+  if (!refreshToken) return false
+  const res = await fetch('/api/refresh', { method: 'POST', body: JSON.stringify({ refresh_token: refreshToken }) })
+  if (!res.ok) {
+    localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
+    return false
+  }
+  const tokens = await res.json() as TokenResponse
+  localStorage.setItem('token', tokens.token)
+  localStorage.setItem('refresh_token', tokens.refresh_token)
+  return true
+}
+
+const authFetch = async (url: string, init: RequestInit = {}) => {
+  const res = await fetch(url, { ...init, headers: getHeaders() })
+  if (res.status !== 401) return res
+  refreshing ??= refreshTokens().finally(() => { refreshing = null })
+  if (!(await refreshing)) return res
+  return fetch(url, { ...init, headers: getHeaders() })
+}
+
 export const register = (email: string, pass: string) => 
   fetch('/api/register', { method: 'POST', body: JSON.stringify({ email, password: pass }) }).then(json<User>)
 
 export const login = (email: string, pass: string) => 
-  fetch('/api/login', { method: 'POST', body: JSON.stringify({ email, password: pass }) }).then(json<AuthResponse>)
+  fetch('/api/login', { method: 'POST', body: JSON.stringify({ email, password: pass }) }).then(json<AuthResponse | MFAChallenge>)
+
+export const getOIDCConfig = () =>
+  fetch('/api/oidc/config').then(json<OIDCConfig>)
+
+export const oidcCallback = (code: string, state: string) =>
+  fetch('/api/oidc/callback', { method: 'POST', body: JSON.stringify({ code, state }) }).then(json<AuthResponse | MFAChallenge>)
+
+export const loginMFA = (challengeToken: string, code: string) =>
+  fetch('/api/login/2fa', { method: 'POST', body: JSON.stringify({ challenge_token: challengeToken, code }) }).then(json<AuthResponse>)
+
+export const verifyEmail = (token: string) =>
+  fetch('/api/verify-email', { method: 'POST', body: JSON.stringify({ token }) }).then(json<User>)
+
+export const forgotPassword = (email: string) =>
+  fetch('/api/password/forgot', { method: 'POST', body: JSON.stringify({ email }) }).then(json<{status: string}>)
+
+export const resetPassword = (token: string, pass: string) =>
+  fetch('/api/password/reset', { method: 'POST', body: JSON.stringify({ token, password: pass }) }).then(json<{status: string}>)
+
+export const logout = () =>
+  fetch('/api/logout', { method: 'POST', headers: getHeaders() }).then(json<{status: string}>)
+
+const ownerParam = (owner?: number) => owner ? `owner=${owner}` : ''
+
+export const getPortfolio = (owner?: number) => 
+  authFetch(`/api/portfolio?${ownerParam(owner)}`).then(json<string[]>)
+
+export const addToPortfolio = (symbol: string, owner?: number) => 
+  authFetch(`/api/portfolio?${ownerParam(owner)}`, { method: 'POST', body: JSON.stringify({ symbol }) }).then(json<{status: string}>)
+
+export const removeFromPortfolio = (symbol: string, owner?: number) => 
+  authFetch(`/api/portfolio?symbol=${encodeURIComponent(symbol)}&${ownerParam(owner)}`, { method: 'DELETE' }).then(json<{status: string}>)
+
+export const getPortfolioMembers = () =>
+  authFetch('/api/portfolio/members').then(json<PortfolioMember[]>)
+
+export const sharePortfolio = (email: string, role: 'viewer' | 'editor') =>
+  authFetch('/api/portfolio/members', { method: 'POST', body: JSON.stringify({ email, role }) }).then(json<{status: string}>)
 
-export const getPortfolio = () => 
-  fetch('/api/portfolio', { headers: getHeaders() }).then(json<string[]>)
+export const unsharePortfolio = (userID: number) =>
+  authFetch(`/api/portfolio/members?user_id=${userID}`, { method: 'DELETE' }).then(json<{status: string}>)
 
-export const addToPortfolio = (symbol: string) => 
-  fetch('/api/portfolio', { method: 'POST', body: JSON.stringify({ symbol }), headers: getHeaders() }).then(json<{status: string}>)
+export const getSharedPortfolios = () =>
+  authFetch('/api/portfolio/shared').then(json<SharedPortfolio[]>)
 
-export const removeFromPortfolio = (symbol: string) => 
-  fetch(`/api/portfolio?symbol=${encodeURIComponent(symbol)}`, { method: 'DELETE', headers: getHeaders() }).then(json<{status: string}>)
+export const leavePortfolio = (owner: number) =>
+  authFetch(`/api/portfolio/shared?owner=${owner}`, { method: 'DELETE' }).then(json<{status: string}>)
@@ -27,5 +27,41 @@ export type User = {
 
 export type AuthResponse = {
   token: string
+  refresh_token: string
+  expires_in: number
   user: User
 }
+
+export type MFAChallenge = {
+  mfa_required: true
+  challenge_token: string
+  expires_in: number
+}
+
+export type OIDCConfig = {
+  enabled: boolean
+  name?: string
+  password_login: boolean
+}
+
+export type TokenResponse = {
+  token: string
+  refresh_token: string
+  expires_in: number
+}
+
+export type PortfolioRole = 'owner' | 'editor' | 'viewer'
+
+export type PortfolioMember = {
+  user_id: number
+  email: string
+  role: PortfolioRole
+  created_at: string
+}
+
+export type SharedPortfolio = {
+  owner_id: number
+  owner_email: string
+  role: PortfolioRole
+  created_at: string
+}